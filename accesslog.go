@@ -0,0 +1,266 @@
+package hfw
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hsyan2008/hfw/common"
+	"github.com/hsyan2008/hfw/configs"
+	"github.com/hsyan2008/hfw/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+//AccessLogFields 访问日志支持的字段，grpc请求的status是grpc的状态码
+var AccessLogFields = []string{
+	"time", "trace_id", "method", "route", "path", "status", "err_no",
+	"bytes_in", "bytes_out", "latency_ms", "client_ip", "user_agent", "controller", "action",
+}
+
+var accessLog struct {
+	isEnable   bool
+	fields     []string
+	sampleRate float64
+
+	mt sync.Mutex
+	w  io.Writer
+}
+
+func initAccessLog(conf configs.AccessLogConfig) (err error) {
+	if !conf.IsEnable {
+		return
+	}
+
+	accessLog.fields = AccessLogFields
+	if len(conf.Fields) > 0 {
+	FIELDS:
+		for _, field := range conf.Fields {
+			for _, v := range AccessLogFields {
+				if v == field {
+					continue FIELDS
+				}
+			}
+			return fmt.Errorf("undefined access log field: %s", field)
+		}
+		accessLog.fields = conf.Fields
+	}
+
+	accessLog.sampleRate = conf.SampleRate
+	if accessLog.sampleRate <= 0 || accessLog.sampleRate > 1 {
+		accessLog.sampleRate = 1
+	}
+
+	switch strings.ToLower(conf.Output) {
+	case "", "stdout":
+		accessLog.w = os.Stdout
+	case "stderr":
+		accessLog.w = os.Stderr
+	default:
+		accessLog.w, err = os.OpenFile(conf.Output, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return
+		}
+	}
+	accessLog.isEnable = true
+
+	return
+}
+
+//访问日志用到的请求结果，http的从accessResponseWriter取，grpc的在拦截器里设置
+type accessInfo struct {
+	err      error
+	bytesIn  int64
+	bytesOut int64
+}
+
+//grpc的unary请求，req和resp是proto.Message时记录大小
+func (httpCtx *HTTPContext) setGrpcAccessInfo(req, resp interface{}, err error) {
+	httpCtx.access.err = err
+	if m, ok := req.(proto.Message); ok {
+		httpCtx.access.bytesIn = int64(proto.Size(m))
+	}
+	if m, ok := resp.(proto.Message); ok && err == nil {
+		httpCtx.access.bytesOut = int64(proto.Size(m))
+	}
+}
+
+func writeAccessLog(httpCtx *HTTPContext, startTime time.Time) {
+	latency := time.Since(startTime)
+	statusCode, errNo := accessStatus(httpCtx)
+	if accessLog.sampleRate < 1 && statusCode < http.StatusInternalServerError && errNo == 0 &&
+		rand.Float64() >= accessLog.sampleRate {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, field := range accessLog.fields {
+		var v interface{}
+		switch field {
+		case "time":
+			v = startTime.Format(time.RFC3339Nano)
+		case "trace_id":
+			v = httpCtx.GetTraceID()
+		case "method":
+			_, v = httpCtx.pathAndMethod()
+		case "route":
+			v = httpCtx.Route
+		case "path":
+			v, _ = httpCtx.pathAndMethod()
+		case "status":
+			v = statusCode
+		case "err_no":
+			v = errNo
+		case "bytes_in":
+			v = httpCtx.access.bytesIn
+			if httpCtx.Request != nil && httpCtx.Request.ContentLength > 0 {
+				v = httpCtx.Request.ContentLength
+			}
+		case "bytes_out":
+			v = httpCtx.access.bytesOut
+			if aw, ok := httpCtx.ResponseWriter.(*accessResponseWriter); ok {
+				v = aw.bytes
+			}
+		case "latency_ms":
+			v = float64(latency.Microseconds()) / 1000
+		case "client_ip":
+			v = accessClientIP(httpCtx)
+		case "user_agent":
+			if httpCtx.Request != nil {
+				v = httpCtx.Request.UserAgent()
+			} else if md, ok := metadata.FromIncomingContext(httpCtx.Ctx); ok && len(md.Get("user-agent")) > 0 {
+				v = md.Get("user-agent")[0]
+			} else {
+				v = ""
+			}
+		case "controller":
+			v = httpCtx.Controller
+		case "action":
+			v = httpCtx.Action
+		}
+		b, err := encoding.JSON.Marshal(v)
+		if err != nil {
+			httpCtx.Warn("access log:", err)
+			return
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(buf, "%q:", field)
+		buf.Write(b)
+	}
+	buf.WriteString("}\n")
+
+	accessLog.mt.Lock()
+	defer accessLog.mt.Unlock()
+	_, _ = accessLog.w.Write(buf.Bytes())
+}
+
+//http返回状态码，grpc返回grpc的状态码
+func accessStatus(httpCtx *HTTPContext) (statusCode int, errNo int64) {
+	errNo = httpCtx.ErrNo
+	if httpCtx.Request != nil {
+		statusCode = httpCtx.HTTPStatus
+		if aw, ok := httpCtx.ResponseWriter.(*accessResponseWriter); ok && aw.status > 0 {
+			statusCode = aw.status
+		}
+		return
+	}
+
+	err := httpCtx.access.err
+	if err == nil {
+		err = httpCtx.abortErr
+	}
+	if e, ok := err.(*common.RespErr); ok && errNo == 0 {
+		errNo = e.ErrNo()
+	}
+
+	return int(status.Code(err)), errNo
+}
+
+func accessClientIP(httpCtx *HTTPContext) string {
+	if httpCtx.Request != nil {
+		return common.GetClientIP(httpCtx.Request)
+	}
+	if p, ok := peer.FromContext(httpCtx.Ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+
+	return ""
+}
+
+//accessResponseWriter 记录状态码和输出的字节数
+type accessResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (aw *accessResponseWriter) WriteHeader(status int) {
+	if aw.status == 0 {
+		aw.status = status
+	}
+	aw.ResponseWriter.WriteHeader(status)
+}
+
+func (aw *accessResponseWriter) Write(b []byte) (int, error) {
+	if aw.status == 0 {
+		aw.status = http.StatusOK
+	}
+	n, err := aw.ResponseWriter.Write(b)
+	aw.bytes += int64(n)
+
+	return n, err
+}
+
+//ReadFrom 保留http.ServeContent等使用sendfile的优化
+func (aw *accessResponseWriter) ReadFrom(r io.Reader) (n int64, err error) {
+	if aw.status == 0 {
+		aw.status = http.StatusOK
+	}
+	if rf, ok := aw.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(aw.ResponseWriter, r)
+	}
+	aw.bytes += n
+
+	return
+}
+
+func (aw *accessResponseWriter) Flush() {
+	if f, ok := aw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (aw *accessResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := aw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T doesn't support hijacking", aw.ResponseWriter)
+	}
+	if aw.status == 0 {
+		aw.status = http.StatusSwitchingProtocols
+	}
+
+	return hj.Hijack()
+}
+
+func (aw *accessResponseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := aw.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+
+	return http.ErrNotSupported
+}
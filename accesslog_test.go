@@ -0,0 +1,103 @@
+package hfw
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hsyan2008/hfw/configs"
+)
+
+type testAccessCtl struct {
+	Controller
+}
+
+func (ctl *testAccessCtl) Fail(httpCtx *HTTPContext) {
+	httpCtx.ThrowCheck(1001, "fail")
+}
+
+func (ctl *testAccessCtl) Panic(httpCtx *HTTPContext) {
+	panic("access panic")
+}
+
+//输出写到buf，结束后恢复
+func setupAccessLog(t *testing.T, conf configs.AccessLogConfig) *bytes.Buffer {
+	conf.IsEnable = true
+	if err := initAccessLog(conf); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	accessLog.w = buf
+	t.Cleanup(func() {
+		accessLog.isEnable = false
+		accessLog.fields = nil
+		accessLog.sampleRate = 0
+		accessLog.w = nil
+	})
+
+	return buf
+}
+
+func TestAccessLogFields(t *testing.T) {
+	buf := setupAccessLog(t, configs.AccessLogConfig{Fields: []string{"status", "method", "route", "bytes_out", "err_no", "controller", "action"}})
+	HandlerFunc("/test_access/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("hello"))
+	})
+	_ = Handler("/test_access_ctl", &testAccessCtl{})
+
+	serveTest("POST", "/test_access/1", nil)
+	serveTest("GET", "/test_access_ctl/fail", nil)
+	serveTest("GET", "/test_access_ctl/panic", nil)
+
+	//按配置的字段和顺序输出
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{
+		`{"status":201,"method":"POST","route":"/test_access/:id","bytes_out":5,"err_no":0,"controller":"","action":""}`,
+		`{"status":200,"method":"GET","route":"/test_access_ctl/fail","bytes_out":`,
+		`{"status":500,"method":"GET","route":"/test_access_ctl/panic","bytes_out":`,
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("access log = %s", buf.String())
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, want[i]) {
+			t.Fatalf("line %d = %s, want %s", i, line, want[i])
+		}
+	}
+	if !strings.HasSuffix(lines[1], `"err_no":1001,"controller":"testAccessCtl","action":"Fail"}`) ||
+		!strings.HasSuffix(lines[2], `"err_no":500,"controller":"testAccessCtl","action":"Panic"}`) {
+		t.Fatalf("controller lines = %s", buf.String())
+	}
+
+	if err := initAccessLog(configs.AccessLogConfig{IsEnable: true, Fields: []string{"status", "undefined"}}); err == nil {
+		t.Fatal("undefined field should fail")
+	}
+}
+
+func TestAccessLogSample(t *testing.T) {
+	buf := setupAccessLog(t, configs.AccessLogConfig{Fields: []string{"status", "err_no"}, SampleRate: 1e-9})
+	HandlerFunc("/test_access_sample", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	})
+	_ = Handler("/test_access_sample_ctl", &testAccessCtl{})
+
+	//正常的请求按采样率记录，5xx和err_no不是0的总会记录
+	for i := 0; i < 100; i++ {
+		serveTest("GET", "/test_access_sample", nil)
+	}
+	serveTest("GET", "/test_access_sample?fail=1", nil)
+	serveTest("GET", "/test_access_sample_ctl/fail", nil)
+	if got := buf.String(); got != "{\"status\":502,\"err_no\":0}\n{\"status\":200,\"err_no\":1001}\n" {
+		t.Fatalf("sampled access log = %q", got)
+	}
+
+	//不在0到1之间的按1
+	setupAccessLog(t, configs.AccessLogConfig{SampleRate: 2})
+	if accessLog.sampleRate != 1 || len(accessLog.fields) != len(AccessLogFields) {
+		t.Fatalf("sampleRate = %v, fields = %v", accessLog.sampleRate, accessLog.fields)
+	}
+}
@@ -17,6 +17,8 @@ import (
 	"github.com/hsyan2008/hfw/curl"
 	"github.com/hsyan2008/hfw/encoding"
 	"github.com/hsyan2008/hfw/service/discovery"
+	"github.com/hsyan2008/hfw/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 //内部第三方接口返回
@@ -95,6 +97,11 @@ func Call(httpCtxIn *hfw.HTTPContext, header map[string]string, addressParams in
 	}
 	httpCtx := hfw.NewHTTPContextWithCtx(httpCtxIn)
 	defer httpCtx.Cancel()
+	if tracing.IsEnable() {
+		var span trace.Span
+		httpCtx.Ctx, span = tracing.Start(httpCtx.Ctx, "Call:"+uri)
+		defer func() { tracing.End(span, err) }()
+	}
 
 	var (
 		cr        *discovery.ConsulResolver
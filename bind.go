@@ -0,0 +1,240 @@
+package hfw
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hsyan2008/hfw/common"
+	"github.com/hsyan2008/hfw/encoding"
+)
+
+//解析multipart时内存里最多保存的大小，超出的写入临时文件
+var defaultMultipartMemory int64 = 32 << 20
+
+var fileHeaderType = reflect.TypeOf((*multipart.FileHeader)(nil))
+
+//Bind 把请求数据解析到v，v必须是struct的指针，然后按validate标签校验
+//body按Content-Type解析，支持json、xml、表单和multipart
+//path标签取路由参数，query标签取url参数，form标签取表单参数(包含url参数)和上传的文件
+//如 ID int `path:"id"` 、Page int `query:"page" validate:"min=1"`、File *multipart.FileHeader `form:"file"`
+//错误返回*common.RespErr，校验失败时ThrowCheck会把各字段的错误放到Results
+func (httpCtx *HTTPContext) Bind(v interface{}) (err error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return common.NewRespErr(500, "Bind: v must be a pointer to struct")
+	}
+
+	err = httpCtx.bindBody(v)
+	if errors.Is(err, ErrUploadTooLarge) {
+		httpCtx.HTTPStatus = http.StatusRequestEntityTooLarge
+		return ErrUploadTooLarge
+	}
+	if err != nil {
+		return common.NewRespErr(400, err)
+	}
+
+	var errs common.ValidationErrors
+	httpCtx.bindValues(rv.Elem(), &errs)
+	if len(errs) == 0 {
+		if e := common.Validate(v); e != nil {
+			ve, ok := e.(common.ValidationErrors)
+			//validate标签错误
+			if !ok {
+				return common.NewRespErr(500, e)
+			}
+			errs = ve
+		}
+	}
+	if len(errs) > 0 {
+		httpCtx.Debugf("Bind %T failed: %s", v, errs.Error())
+		return common.NewRespErr(400, errs)
+	}
+
+	return nil
+}
+
+func (httpCtx *HTTPContext) bindBody(v interface{}) (err error) {
+	r := httpCtx.Request
+	if r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch contentType {
+	case "application/json":
+		err = encoding.JSONIO.Unmarshal(r.Body, v)
+	case "application/xml", "text/xml":
+		err = xml.NewDecoder(r.Body).Decode(v)
+	case "multipart/form-data":
+		if err = httpCtx.limitUploadBody(); err == nil {
+			err = r.ParseMultipartForm(defaultMultipartMemory)
+		}
+	default:
+		err = r.ParseForm()
+	}
+	if err == io.EOF {
+		err = nil
+	}
+
+	return
+}
+
+func (httpCtx *HTTPContext) bindValues(rv reflect.Value, errs *common.ValidationErrors) {
+	r := httpCtx.Request
+	if r.Form == nil {
+		_ = r.ParseForm()
+	}
+	query := r.URL.Query()
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		fv := rv.Field(i)
+		if sf.Anonymous && fv.Kind() == reflect.Struct {
+			httpCtx.bindValues(fv, errs)
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		var (
+			name   string
+			values []string
+		)
+		if name = sf.Tag.Get("path"); name != "" {
+			if value := httpCtx.Param(name); value != "" {
+				values = []string{value}
+			}
+		} else if name = sf.Tag.Get("query"); name != "" {
+			values = query[name]
+		} else if name = sf.Tag.Get("form"); name != "" {
+			name = strings.Split(name, ",")[0]
+			if name == "-" {
+				continue
+			}
+			if r.MultipartForm != nil && isFileField(sf.Type) {
+				setFileField(fv, r.MultipartForm.File[name])
+				continue
+			}
+			values = r.Form[name]
+		} else {
+			continue
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+		if err := setField(fv, values); err != nil {
+			*errs = append(*errs, common.FieldError{Field: name, Rule: "type", Msg: err.Error()})
+		}
+	}
+}
+
+func isFileField(t reflect.Type) bool {
+	return t == fileHeaderType || (t.Kind() == reflect.Slice && t.Elem() == fileHeaderType)
+}
+
+func setFileField(fv reflect.Value, files []*multipart.FileHeader) {
+	if len(files) == 0 {
+		return
+	}
+	if fv.Kind() == reflect.Slice {
+		fv.Set(reflect.ValueOf(files))
+	} else {
+		fv.Set(reflect.ValueOf(files[0]))
+	}
+}
+
+func setField(fv reflect.Value, values []string) error {
+	switch fv.Kind() {
+	case reflect.Ptr:
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return setField(fv.Elem(), values)
+	case reflect.Slice:
+		slice := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for k, v := range values {
+			if err := setValue(slice.Index(k), v); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+
+	return setValue(fv, values[0])
+}
+
+func setValue(fv reflect.Value, s string) (err error) {
+	s = strings.TrimSpace(s)
+	if fv.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		if s == "" {
+			return
+		}
+		var b bool
+		b, err = strconv.ParseBool(s)
+		if err == nil {
+			fv.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if s == "" {
+			return
+		}
+		var n int64
+		n, err = strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err == nil {
+			fv.SetInt(n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if s == "" {
+			return
+		}
+		var n uint64
+		n, err = strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err == nil {
+			fv.SetUint(n)
+		}
+	case reflect.Float32, reflect.Float64:
+		if s == "" {
+			return
+		}
+		var n float64
+		n, err = strconv.ParseFloat(s, fv.Type().Bits())
+		if err == nil {
+			fv.SetFloat(n)
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+
+	if err != nil {
+		var numErr *strconv.NumError
+		if errors.As(err, &numErr) {
+			err = fmt.Errorf("invalid value %q", s)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,82 @@
+package hfw
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hsyan2008/hfw/common"
+)
+
+type testBindReq struct {
+	ID   int    `path:"id"`
+	Page int    `query:"page" validate:"min=1"`
+	Name string `json:"name" form:"name" validate:"required"`
+}
+
+func newBindCtx(method, target, contentType, body string, params Params) *HTTPContext {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	httpCtx := initCtx(httptest.NewRecorder(), r)
+	httpCtx.params = params
+
+	return httpCtx
+}
+
+func TestBind(t *testing.T) {
+	cases := []struct {
+		contentType string
+		body        string
+	}{
+		{"application/json", `{"name":"hfw"}`},
+		{"application/x-www-form-urlencoded", "name=hfw"},
+	}
+	for _, c := range cases {
+		httpCtx := newBindCtx("POST", "/users/3?page=2", c.contentType, c.body, Params{{"id", "3"}})
+		var req testBindReq
+		if err := httpCtx.Bind(&req); err != nil {
+			t.Fatalf("%s Bind = %v", c.contentType, err)
+		}
+		if req.ID != 3 || req.Page != 2 || req.Name != "hfw" {
+			t.Fatalf("%s Bind = %+v", c.contentType, req)
+		}
+		httpCtx.Cancel()
+	}
+}
+
+func TestBindError(t *testing.T) {
+	httpCtx := newBindCtx("POST", "/users/3?page=-1", "application/json", `{}`, nil)
+	defer httpCtx.Cancel()
+	var req testBindReq
+	err := httpCtx.Bind(&req)
+	var respErr *common.RespErr
+	if !errors.As(err, &respErr) {
+		t.Fatalf("Bind = %v, want *common.RespErr", err)
+	}
+	if errs, ok := respErr.Err().(common.ValidationErrors); !ok || len(errs) != 2 || respErr.ErrNo() != 400 {
+		t.Fatalf("Bind = %v, want page and name errors", err)
+	}
+
+	if err = httpCtx.Bind(req); err == nil {
+		t.Fatal("Bind non pointer should fail")
+	}
+
+	httpCtx = newBindCtx("POST", "/users/3", "application/json", `{"name":`, nil)
+	defer httpCtx.Cancel()
+	if err = httpCtx.Bind(&req); err == nil {
+		t.Fatal("Bind invalid json should fail")
+	}
+
+	//validate标签错误返回500，不panic
+	httpCtx = newBindCtx("POST", "/users/3", "application/json", `{"name":"hfw"}`, nil)
+	defer httpCtx.Cancel()
+	var bad struct {
+		Name string `json:"name" validate:"min=a"`
+	}
+	if err = httpCtx.Bind(&bad); !errors.As(err, &respErr) || respErr.ErrNo() != 500 {
+		t.Fatalf("Bind with bad tag = %v, want 500", err)
+	}
+}
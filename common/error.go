@@ -54,6 +54,17 @@ func (respErr *RespErr) Err() error {
 	return respErr.err
 }
 
+//Details 错误的详细信息，如参数校验失败的各字段错误
+func (respErr *RespErr) Details() interface{} {
+	if respErr == nil {
+		return nil
+	}
+	if d, ok := respErr.err.(interface{ Details() interface{} }); ok {
+		return d.Details()
+	}
+	return nil
+}
+
 func (respErr *RespErr) Error() string {
 	return respErr.String()
 }
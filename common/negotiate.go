@@ -0,0 +1,122 @@
+package common
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//AcceptItem Accept类请求头里的一项
+type AcceptItem struct {
+	Value string
+	Q     float64
+}
+
+//ParseAccept 解析Accept、Accept-Encoding、Accept-Language之类的请求头
+//结果按q值从大到小排序，q值相同的保持原来的顺序
+func ParseAccept(header string) (list []AcceptItem) {
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		item := AcceptItem{Q: 1}
+		params := strings.Split(part, ";")
+		item.Value = strings.ToLower(strings.TrimSpace(params[0]))
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				q, err := strconv.ParseFloat(p[2:], 64)
+				if err == nil {
+					item.Q = q
+				}
+			}
+		}
+		list = append(list, item)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Q > list[j].Q
+	})
+
+	return
+}
+
+//NegotiateContentType 按Accept从offers里选出最合适的媒体类型
+//accept为空返回offers的第一个，都不匹配返回空
+//q值相同时，优先offers里靠前的
+func NegotiateContentType(accept string, offers []string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	items := ParseAccept(accept)
+
+	var (
+		best  string
+		bestQ float64
+	)
+	for _, offer := range offers {
+		q, specificity := -1.0, -1
+		for _, item := range items {
+			s := matchMediaRange(item.Value, strings.ToLower(offer))
+			if s > specificity {
+				q, specificity = item.Q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
+
+//返回匹配程度，-1表示不匹配
+func matchMediaRange(mediaRange, offer string) int {
+	if mediaRange == offer {
+		return 2
+	}
+	if mediaRange == "*/*" || mediaRange == "*" {
+		return 0
+	}
+	if strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(offer, mediaRange[:len(mediaRange)-1]) {
+		return 1
+	}
+
+	return -1
+}
+
+//NegotiateValue 用于Accept-Encoding、Accept-Language之类的协商，从offers里选出q值最大的
+//*匹配任意值，都不匹配返回空
+func NegotiateValue(header string, offers []string) string {
+	var (
+		best  string
+		bestQ float64
+	)
+	items := ParseAccept(header)
+	for _, offer := range offers {
+		lower := strings.ToLower(offer)
+		q, isMatch := 0.0, false
+		for _, item := range items {
+			if item.Value == lower {
+				q, isMatch = item.Q, true
+				break
+			}
+		}
+		if !isMatch {
+			for _, item := range items {
+				if item.Value == "*" {
+					q = item.Q
+					break
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
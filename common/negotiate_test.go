@@ -0,0 +1,40 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestNegotiateContentType(t *testing.T) {
+	offers := []string{"text/html", "application/json", "application/xml"}
+	cases := map[string]string{
+		"":                                   "text/html",
+		"*/*":                                "text/html",
+		"application/json":                   "application/json",
+		"application/xml;q=0.9, */*;q=0.8":   "application/xml",
+		"application/*;q=0.5, text/html;q=0": "application/json",
+		"image/png":                          "",
+		"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8": "text/html",
+	}
+	for accept, want := range cases {
+		if got := NegotiateContentType(accept, offers); got != want {
+			t.Fatalf("accept:%s want:%s got:%s", accept, want, got)
+		}
+	}
+}
+
+func TestNegotiateValue(t *testing.T) {
+	offers := []string{"br", "gzip", "deflate"}
+	cases := map[string]string{
+		"":                          "",
+		"gzip, deflate":             "gzip",
+		"gzip;q=0.5, br":            "br",
+		"*;q=0.1, gzip;q=0":         "br",
+		"identity":                  "",
+		"deflate;q=0.8, gzip;q=0.9": "gzip",
+	}
+	for header, want := range cases {
+		if got := NegotiateValue(header, offers); got != want {
+			t.Fatalf("header:%s want:%s got:%s", header, want, got)
+		}
+	}
+}
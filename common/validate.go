@@ -0,0 +1,267 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//FieldError 字段校验错误
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Msg   string `json:"msg"`
+}
+
+//ValidationErrors 校验错误列表
+type ValidationErrors []FieldError
+
+func (ve ValidationErrors) Error() string {
+	list := make([]string, len(ve))
+	for k, v := range ve {
+		list[k] = fmt.Sprintf("%s: %s", v.Field, v.Msg)
+	}
+
+	return strings.Join(list, "; ")
+}
+
+//Details 用于RespErr返回各字段的错误
+func (ve ValidationErrors) Details() interface{} {
+	return []FieldError(ve)
+}
+
+//Validate 按validate标签校验struct，支持required、min、max、regex、enum
+//多个规则用英文逗号分隔，regex需要放在最后，enum的值用|分隔
+//如`validate:"required,min=1,max=10"`、`validate:"enum=a|b|c"`、`validate:"required,regex=^\\d+$"`
+//min和max对数字比较大小，对字符串、slice、map比较长度
+//字段名优先取json标签，其次form标签
+//标签按类型解析一次并缓存，标签错误时返回的不是ValidationErrors
+func Validate(v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs ValidationErrors
+	if err := validateStruct(rv, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func validateStruct(rv reflect.Value, prefix string, errs *ValidationErrors) (err error) {
+	rt := rv.Type()
+	fields, err := structRules(rt)
+	if err != nil {
+		return
+	}
+	for i, field := range fields {
+		sf := rt.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		fv := rv.Field(i)
+		if sf.Anonymous {
+			if fv.Kind() == reflect.Ptr {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				if err = validateStruct(fv, prefix, errs); err != nil {
+					return
+				}
+			}
+			continue
+		}
+
+		name := prefix + field.name
+		validateField(fv, name, field.rules, errs)
+
+		//嵌套的struct
+		if fv.Kind() == reflect.Ptr {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct && fv.Type().PkgPath() != "time" {
+			if err = validateStruct(fv, name+".", errs); err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+//FieldName 取字段的名称，优先json标签，其次form标签
+func FieldName(sf reflect.StructField) string {
+	for _, key := range []string{"json", "form"} {
+		if name := strings.Split(sf.Tag.Get(key), ",")[0]; name != "" && name != "-" {
+			return name
+		}
+	}
+
+	return sf.Name
+}
+
+//validateRule 解析后的规则，min和max的值、regex和enum在解析时准备好
+type validateRule struct {
+	key   string
+	param string
+	limit float64
+	re    *regexp.Regexp
+	enum  []string
+}
+
+type fieldRules struct {
+	name  string
+	rules []validateRule
+}
+
+type structRulesCache struct {
+	fields []fieldRules
+	err    error
+}
+
+var rulesCache sync.Map
+
+//按字段的下标返回字段名和规则，同一个类型只解析一次
+func structRules(rt reflect.Type) ([]fieldRules, error) {
+	if v, ok := rulesCache.Load(rt); ok {
+		c := v.(structRulesCache)
+		return c.fields, c.err
+	}
+
+	var err error
+	fields := make([]fieldRules, rt.NumField())
+	for i := range fields {
+		sf := rt.Field(i)
+		fields[i].name = FieldName(sf)
+		if tag := sf.Tag.Get("validate"); tag != "" && tag != "-" {
+			if fields[i].rules, err = parseRules(tag); err != nil {
+				err = fmt.Errorf("validate: %s.%s: %v", rt.Name(), sf.Name, err)
+				break
+			}
+		}
+	}
+	rulesCache.Store(rt, structRulesCache{fields: fields, err: err})
+
+	return fields, err
+}
+
+func parseRules(tag string) (rules []validateRule, err error) {
+	for tag != "" {
+		var rule string
+		if strings.HasPrefix(tag, "regex=") {
+			rule, tag = tag, ""
+		} else {
+			idx := strings.Index(tag, ",")
+			if idx == -1 {
+				rule, tag = tag, ""
+			} else {
+				rule, tag = tag[:idx], tag[idx+1:]
+			}
+		}
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		r := validateRule{key: rule}
+		if idx := strings.Index(rule, "="); idx != -1 {
+			r.key, r.param = rule[:idx], rule[idx+1:]
+		}
+
+		switch r.key {
+		case "required":
+		case "min", "max":
+			if r.limit, err = strconv.ParseFloat(r.param, 64); err != nil {
+				return nil, fmt.Errorf("error %s=%s", r.key, r.param)
+			}
+		case "regex":
+			if r.re, err = regexp.Compile(r.param); err != nil {
+				return nil, err
+			}
+		case "enum":
+			r.enum = strings.Split(r.param, "|")
+		default:
+			return nil, errors.New("undefined rule " + r.key)
+		}
+		rules = append(rules, r)
+	}
+
+	return
+}
+
+func validateField(fv reflect.Value, name string, rules []validateRule, errs *ValidationErrors) {
+	isZero := fv.IsZero()
+	if fv.Kind() == reflect.Ptr {
+		fv = fv.Elem()
+	}
+	for _, r := range rules {
+		if r.key == "required" {
+			if isZero {
+				*errs = append(*errs, FieldError{Field: name, Rule: r.key, Msg: "is required"})
+				//没有值，不需要继续校验
+				return
+			}
+			continue
+		}
+		//非必填且没有值的，不校验
+		if isZero {
+			return
+		}
+
+		if msg := checkRule(fv, r); msg != "" {
+			*errs = append(*errs, FieldError{Field: name, Rule: r.key, Msg: msg})
+		}
+	}
+}
+
+func checkRule(fv reflect.Value, r validateRule) (msg string) {
+	switch r.key {
+	case "min", "max":
+		n, isLen := measure(fv)
+		if (r.key == "min" && n < r.limit) || (r.key == "max" && n > r.limit) {
+			if isLen {
+				return fmt.Sprintf("length must be %s %s", map[string]string{"min": ">=", "max": "<="}[r.key], r.param)
+			}
+			return fmt.Sprintf("must be %s %s", map[string]string{"min": ">=", "max": "<="}[r.key], r.param)
+		}
+	case "regex":
+		if !r.re.MatchString(fmt.Sprint(fv.Interface())) {
+			return "must match " + r.param
+		}
+	case "enum":
+		s := fmt.Sprint(fv.Interface())
+		for _, v := range r.enum {
+			if s == v {
+				return
+			}
+		}
+		return "must be one of " + strings.Join(r.enum, ",")
+	}
+
+	return
+}
+
+//数字返回值，字符串、slice、map返回长度
+func measure(fv reflect.Value) (n float64, isLen bool) {
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), false
+	case reflect.String:
+		return float64(len([]rune(fv.String()))), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(fv.Len()), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,71 @@
+package common
+
+import (
+	"testing"
+)
+
+type validateTestAddr struct {
+	City string `json:"city" validate:"required"`
+}
+
+type validateTestReq struct {
+	Name   string            `json:"name" validate:"required,min=2,max=5"`
+	Age    int               `form:"age" validate:"min=18,max=60"`
+	Code   string            `json:"code" validate:"regex=^[a-z]{2},\\d+$"`
+	Status string            `json:"status" validate:"enum=on|off"`
+	Tags   []string          `json:"tags" validate:"max=2"`
+	Addr   *validateTestAddr `json:"addr"`
+}
+
+func TestValidate(t *testing.T) {
+	ok := validateTestReq{Name: "tom", Age: 20, Code: "ab,12", Status: "on"}
+	if err := Validate(&ok); err != nil {
+		t.Fatalf("want nil got:%v", err)
+	}
+
+	bad := validateTestReq{Name: "t", Age: 10, Code: "x", Status: "no", Tags: []string{"a", "b", "c"},
+		Addr: &validateTestAddr{}}
+	err := Validate(bad)
+	ve, is := err.(ValidationErrors)
+	if !is {
+		t.Fatalf("want ValidationErrors got:%v", err)
+	}
+	want := map[string]string{"name": "min", "age": "min", "code": "regex", "status": "enum",
+		"tags": "max", "addr.city": "required"}
+	if len(ve) != len(want) {
+		t.Fatalf("want %d errors got:%v", len(want), ve)
+	}
+	for _, v := range ve {
+		if want[v.Field] != v.Rule {
+			t.Fatalf("field:%s want rule:%s got:%s", v.Field, want[v.Field], v.Rule)
+		}
+	}
+
+	respErr := NewRespErr(400, err)
+	if d, is := respErr.Details().([]FieldError); !is || len(d) != len(want) {
+		t.Fatalf("want details got:%v", respErr.Details())
+	}
+}
+
+func TestValidateBadTag(t *testing.T) {
+	cases := []interface{}{
+		struct {
+			Age int `validate:"min=a"`
+		}{Age: 1},
+		struct {
+			Code string `validate:"regex=[a-"`
+		}{Code: "a"},
+		struct {
+			Name string `validate:"required,unknown"`
+		}{Name: "a"},
+	}
+	for _, v := range cases {
+		//标签错误的返回error，即使字段没有值也一样，不在请求的时候panic
+		for i := 0; i < 2; i++ {
+			err := Validate(v)
+			if _, is := err.(ValidationErrors); err == nil || is {
+				t.Fatalf("%T want tag error got:%v", v, err)
+			}
+		}
+	}
+}
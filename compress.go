@@ -0,0 +1,245 @@
+package hfw
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/hsyan2008/hfw/common"
+	"github.com/klauspost/compress/zstd"
+)
+
+//Compressor 压缩器，需要支持Reset以便复用
+type Compressor interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+var compressors = struct {
+	sync.RWMutex
+	//按优先级排序，q值相同时选靠前的
+	names []string
+	pools map[string]*sync.Pool
+}{
+	pools: make(map[string]*sync.Pool),
+}
+
+func init() {
+	RegisterCompressor("br", func() Compressor {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	})
+	RegisterCompressor("zstd", func() Compressor {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return w
+	})
+	RegisterCompressor("gzip", func() Compressor {
+		return gzip.NewWriter(nil)
+	})
+	RegisterCompressor("deflate", func() Compressor {
+		w, _ := flate.NewWriter(nil, flate.DefaultCompression)
+		return w
+	})
+}
+
+//RegisterCompressor 注册压缩算法，name是Accept-Encoding里的名称，相同的会覆盖
+//是否启用由Server.Compress.Encodings配置
+func RegisterCompressor(name string, newCompressor func() Compressor) {
+	compressors.Lock()
+	defer compressors.Unlock()
+	if _, ok := compressors.pools[name]; !ok {
+		compressors.names = append(compressors.names, name)
+	}
+	compressors.pools[name] = &sync.Pool{
+		New: func() interface{} {
+			return newCompressor()
+		},
+	}
+}
+
+//默认不压缩的Content-Type，已经压缩过的
+var notCompressTypes = []string{
+	"image/*", "video/*", "audio/*", "font/woff", "font/woff2",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-bzip2",
+	"application/x-xz", "application/x-7z-compressed", "application/x-rar-compressed",
+	"application/zstd", "application/pdf", "application/wasm",
+}
+
+//按Accept-Encoding选择压缩算法，不压缩返回空
+func negotiateEncoding(r *http.Request) string {
+	if Config.Server.Compress.IsDisable {
+		return ""
+	}
+	acceptEncoding := r.Header.Get("Accept-Encoding")
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	compressors.RLock()
+	offers := Config.Server.Compress.Encodings
+	if len(offers) == 0 {
+		offers = compressors.names
+	}
+	list := make([]string, 0, len(offers))
+	for _, v := range offers {
+		if _, ok := compressors.pools[v]; ok {
+			list = append(list, v)
+		}
+	}
+	compressors.RUnlock()
+
+	return common.NegotiateValue(acceptEncoding, list)
+}
+
+func isCompressType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.TrimSpace(strings.Split(contentType, ";")[0])
+	}
+	mediaType = strings.ToLower(mediaType)
+	if mediaType == "image/svg+xml" {
+		return true
+	}
+	for _, list := range [][]string{notCompressTypes, Config.Server.Compress.ExcludeTypes} {
+		for _, v := range list {
+			if v == mediaType || (strings.HasSuffix(v, "/*") && strings.HasPrefix(mediaType, v[:len(v)-1])) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+//compressResponseWriter 先缓存MinSize大小的数据，超过后才决定是否压缩
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status      int
+	buf         bytes.Buffer
+	compressor  Compressor
+	wroteHeader bool
+}
+
+//返回的writer用于输出响应，需要先调用WriteHeader，最后调用closer
+func (httpCtx *HTTPContext) compressWriter() (w http.ResponseWriter, closer func()) {
+	header := httpCtx.ResponseWriter.Header()
+	if httpCtx.IsZip && header.Get("Content-Encoding") == "" {
+		if encoding := negotiateEncoding(httpCtx.Request); encoding != "" {
+			cw := &compressResponseWriter{
+				ResponseWriter: httpCtx.ResponseWriter,
+				encoding:       encoding,
+				minSize:        Config.Server.Compress.MinSize,
+				status:         http.StatusOK,
+			}
+			return cw, cw.close
+		}
+	}
+	if !Config.Server.Compress.IsDisable {
+		addVary(header, "Accept-Encoding")
+	}
+
+	return httpCtx.ResponseWriter, func() {}
+}
+
+//WriteHeader 延迟到确定是否压缩后
+func (cw *compressResponseWriter) WriteHeader(status int) {
+	cw.status = status
+}
+
+func (cw *compressResponseWriter) Write(b []byte) (int, error) {
+	if cw.compressor != nil {
+		return cw.compressor.Write(b)
+	}
+	if cw.wroteHeader {
+		return cw.ResponseWriter.Write(b)
+	}
+
+	cw.buf.Write(b)
+	if cw.buf.Len() >= cw.minSize {
+		if err := cw.flushBuf(true); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+//canCompress为false表示数据小于MinSize
+func (cw *compressResponseWriter) flushBuf(canCompress bool) (err error) {
+	header := cw.Header()
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", http.DetectContentType(cw.buf.Bytes()))
+	}
+	addVary(header, "Accept-Encoding")
+	if canCompress && cw.buf.Len() > 0 && header.Get("Content-Encoding") == "" &&
+		isCompressType(header.Get("Content-Type")) &&
+		cw.status != http.StatusNoContent && cw.status != http.StatusNotModified {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", cw.encoding)
+		compressors.RLock()
+		pool := compressors.pools[cw.encoding]
+		compressors.RUnlock()
+		cw.compressor = pool.Get().(Compressor)
+		cw.compressor.Reset(cw.ResponseWriter)
+	}
+	cw.wroteHeader = true
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if cw.compressor != nil {
+		_, err = cw.compressor.Write(cw.buf.Bytes())
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf.Bytes())
+	}
+	cw.buf.Reset()
+
+	return
+}
+
+//Flush 先输出已缓存的数据
+func (cw *compressResponseWriter) Flush() {
+	if !cw.wroteHeader {
+		_ = cw.flushBuf(cw.buf.Len() >= cw.minSize)
+	}
+	if f, ok := cw.compressor.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressResponseWriter) close() {
+	if !cw.wroteHeader {
+		_ = cw.flushBuf(cw.buf.Len() >= cw.minSize)
+	}
+	if cw.compressor == nil {
+		return
+	}
+	_ = cw.compressor.Close()
+	//不再持有ResponseWriter
+	cw.compressor.Reset(nil)
+	compressors.RLock()
+	pool := compressors.pools[cw.encoding]
+	compressors.RUnlock()
+	pool.Put(cw.compressor)
+	cw.compressor = nil
+}
+
+func addVary(header http.Header, value string) {
+	for _, v := range header.Values("Vary") {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), value) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", value)
+}
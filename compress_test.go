@@ -0,0 +1,203 @@
+package hfw
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+type testCompressCtl struct {
+	Controller
+}
+
+func (ctl *testCompressCtl) Index(httpCtx *HTTPContext) {
+	httpCtx.Results = strings.Repeat("hfw", 1000)
+}
+
+func decompress(t *testing.T, encoding string, b []byte) string {
+	var r io.Reader
+	var err error
+	switch encoding {
+	case "":
+		return string(b)
+	case "gzip":
+		r, err = gzip.NewReader(bytes.NewReader(b))
+	case "deflate":
+		r = flate.NewReader(bytes.NewReader(b))
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(b))
+	case "zstd":
+		var d *zstd.Decoder
+		d, err = zstd.NewReader(bytes.NewReader(b))
+		if err == nil {
+			defer d.Close()
+		}
+		r = d
+	}
+	if err != nil {
+		t.Fatalf("%s reader: %v", encoding, err)
+	}
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("%s decompress: %v", encoding, err)
+	}
+
+	return string(out)
+}
+
+func TestCompressNegotiate(t *testing.T) {
+	old := Config.Server.Compress
+	defer func() { Config.Server.Compress = old }()
+	_ = Handler("/test_compress", &testCompressCtl{})
+
+	cases := []struct {
+		encodings      []string
+		acceptEncoding string
+		want           string
+	}{
+		//q值相同时按注册的顺序
+		{nil, "gzip, deflate, br, zstd", "br"},
+		{nil, "gzip;q=1, br;q=0.5", "gzip"},
+		{nil, "zstd;q=0.9, gzip;q=0.8", "zstd"},
+		{nil, "deflate", "deflate"},
+		{nil, "br;q=0, gzip", "gzip"},
+		{nil, "identity", ""},
+		{nil, "", ""},
+		//只启用配置的
+		{[]string{"gzip", "deflate"}, "br, deflate, gzip", "gzip"},
+		{[]string{"deflate"}, "br, gzip", ""},
+	}
+	for _, c := range cases {
+		Config.Server.Compress.Encodings = c.encodings
+		w := serveTest("GET", "/test_compress", http.Header{"Accept-Encoding": {c.acceptEncoding}})
+		if got := w.Header().Get("Content-Encoding"); got != c.want {
+			t.Fatalf("%v %q = %q, want %q", c.encodings, c.acceptEncoding, got, c.want)
+		}
+		if body := decompress(t, c.want, w.Body.Bytes()); !strings.Contains(body, strings.Repeat("hfw", 1000)) {
+			t.Fatalf("%q body = %q", c.acceptEncoding, body)
+		}
+		if !strings.Contains(w.Header().Get("Vary"), "Accept-Encoding") {
+			t.Fatalf("%q Vary = %q", c.acceptEncoding, w.Header().Get("Vary"))
+		}
+	}
+
+	Config.Server.Compress = old
+	Config.Server.Compress.IsDisable = true
+	w := serveTest("GET", "/test_compress", http.Header{"Accept-Encoding": {"gzip"}})
+	if w.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("disabled compress = %v", w.Header())
+	}
+}
+
+func newCompressCtx(acceptEncoding string) (*HTTPContext, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", acceptEncoding)
+	httpCtx := initCtx(w, r)
+	httpCtx.IsZip = true
+
+	return httpCtx, w
+}
+
+func TestCompressMinSize(t *testing.T) {
+	old := Config.Server.Compress
+	Config.Server.Compress.MinSize = 100
+	defer func() { Config.Server.Compress = old }()
+
+	//小于MinSize的不压缩
+	httpCtx, w := newCompressCtx("gzip")
+	cw, closer := httpCtx.compressWriter()
+	cw.WriteHeader(http.StatusCreated)
+	_, _ = cw.Write([]byte("small"))
+	closer()
+	httpCtx.Cancel()
+	if w.Code != http.StatusCreated || w.Header().Get("Content-Encoding") != "" || w.Body.String() != "small" {
+		t.Fatalf("small = %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+
+	//分多次写入，超过MinSize之前先缓存
+	httpCtx, w = newCompressCtx("gzip")
+	cw, closer = httpCtx.compressWriter()
+	cw.WriteHeader(http.StatusOK)
+	_, _ = cw.Write([]byte(strings.Repeat("a", 60)))
+	if w.Body.Len() != 0 || w.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("buffered = %v %q", w.Header(), w.Body.String())
+	}
+	_, _ = cw.Write([]byte(strings.Repeat("b", 60)))
+	closer()
+	httpCtx.Cancel()
+	if w.Header().Get("Content-Encoding") != "gzip" || decompress(t, "gzip", w.Body.Bytes()) != strings.Repeat("a", 60)+strings.Repeat("b", 60) {
+		t.Fatalf("large = %v %q", w.Header(), w.Body.String())
+	}
+
+	//已经压缩过的类型不压缩
+	httpCtx, w = newCompressCtx("gzip")
+	httpCtx.ResponseWriter.Header().Set("Content-Type", "image/png")
+	cw, closer = httpCtx.compressWriter()
+	cw.WriteHeader(http.StatusOK)
+	_, _ = cw.Write(bytes.Repeat([]byte{1}, 200))
+	closer()
+	httpCtx.Cancel()
+	if w.Header().Get("Content-Encoding") != "" || w.Body.Len() != 200 {
+		t.Fatalf("image = %v %d", w.Header(), w.Body.Len())
+	}
+}
+
+//testCompressor 记录Reset的参数
+type testCompressor struct {
+	*gzip.Writer
+	resets *[]io.Writer
+}
+
+func (c *testCompressor) Reset(w io.Writer) {
+	*c.resets = append(*c.resets, w)
+	c.Writer.Reset(w)
+}
+
+func TestCompressPool(t *testing.T) {
+	old := Config.Server.Compress
+	Config.Server.Compress.MinSize = -1
+	Config.Server.Compress.Encodings = []string{"test-gzip"}
+	defer func() {
+		Config.Server.Compress = old
+		compressors.Lock()
+		compressors.names = compressors.names[:len(compressors.names)-1]
+		delete(compressors.pools, "test-gzip")
+		compressors.Unlock()
+	}()
+	var resets []io.Writer
+	var news int
+	RegisterCompressor("test-gzip", func() Compressor {
+		news++
+		return &testCompressor{Writer: gzip.NewWriter(nil), resets: &resets}
+	})
+
+	//复用的压缩器输出也要正确
+	for i := 0; i < 2; i++ {
+		httpCtx, w := newCompressCtx("test-gzip")
+		cw, closer := httpCtx.compressWriter()
+		cw.WriteHeader(http.StatusOK)
+		_, _ = cw.Write([]byte("hello"))
+		closer()
+		httpCtx.Cancel()
+		if w.Header().Get("Content-Encoding") != "test-gzip" || decompress(t, "gzip", w.Body.Bytes()) != "hello" {
+			t.Fatalf("%d = %v %q", i, w.Header(), w.Body.String())
+		}
+		//放回pool之前不再持有ResponseWriter
+		if n := len(resets); n == 0 || resets[n-1] != nil {
+			t.Fatalf("%d resets = %v", i, resets)
+		}
+	}
+	if news == 0 || news > 2 {
+		t.Fatalf("new compressor %d times", news)
+	}
+}
@@ -4,7 +4,17 @@ import (
 	"time"
 )
 
-var Config AllConfig
+//DefaultMaxBodySize Upload.MaxBodySize的默认值
+const DefaultMaxBodySize = 32 << 20
+
+var Config = NewConfig()
+
+//NewConfig 加载配置前的默认值，配置里没有写的保持默认，如MaxBodySize明确写0才表示不限制
+func NewConfig() AllConfig {
+	return AllConfig{
+		Upload: UploadConfig{MaxBodySize: DefaultMaxBodySize},
+	}
+}
 
 //Config 项目配置
 type AllConfig struct {
@@ -24,7 +34,67 @@ type AllConfig struct {
 	Session    SessionConfig
 	Prometheus PrometheusConfig
 	HotDeploy  HotDeployConfig
-	Custom     map[string]string
+	Shutdown   ShutdownConfig
+	Health     HealthConfig
+	Tracing    TracingConfig
+	Reporter   ReporterConfig
+	Cors       CorsConfig
+	Csrf       CsrfConfig
+	I18n       I18nConfig
+	Upload     UploadConfig
+	//按路由或者controller限流，toml里用[[Limits]]
+	Limits []LimitConfig
+	Custom map[string]string
+}
+
+//CorsConfig 跨域，分组可以用RouteGroup.Cors覆盖
+type CorsConfig struct {
+	IsEnable bool
+	//如https://a.com，*表示任意，https://*.a.com或者*.a.com表示子域名
+	AllowOrigins []string
+	//默认GET、HEAD、POST、PUT、PATCH、DELETE
+	AllowMethods []string
+	//为空表示允许请求的Access-Control-Request-Headers
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	//预检请求的缓存时间
+	MaxAge time.Duration
+}
+
+//CsrfConfig 开启session时token存在session里，否则用cookie(double submit)
+type CsrfConfig struct {
+	IsEnable bool
+	//表单字段，默认_csrf
+	FieldName string
+	//请求头，默认X-CSRF-Token
+	HeaderName string
+	//不开启session时存放token的cookie，默认csrf_token
+	CookieName string
+	//不校验的路由，如/api/*，也可以用CsrfExempt中间件
+	ExemptRoutes []string
+}
+
+//I18nConfig 多语言的错误信息和文案，见i18n包
+type I18nConfig struct {
+	//语言文件的目录，默认加载config/i18n和config/环境/i18n，后者覆盖前者
+	Path string
+	//协商不到语言时使用，默认zh-CN
+	DefaultLocale string
+	//指定语言的cookie，默认lang
+	CookieName string
+}
+
+//UploadConfig 上传文件，只用于multipart/form-data的请求
+type UploadConfig struct {
+	//请求体的最大字节数，Content-Length超出时直接返回413，默认32M，配置为0表示不限制
+	MaxBodySize int64
+	//单个文件的最大字节数，0表示不单独限制，仍受MaxBodySize限制
+	MaxFileSize int64
+	//允许的文件类型，按内容嗅探，如image/png、image/*，为空表示不限制
+	AllowTypes []string
+	//Upload写入临时文件的目录，默认是系统的临时目录
+	TempDir string
 }
 
 type RedisConfig struct {
@@ -90,6 +160,24 @@ type HTTPServerConfig struct {
 
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
+	//业务逻辑的超时时间，设置到httpCtx.Ctx，0表示不限制，可以用Timeout中间件覆盖，SSE不受限制
+	HandlerTimeout time.Duration
+	//没有证书时开启明文HTTP/2(h2c)，同一个端口可以同时提供HTTP/1.1、HTTP/2和grpc
+	IsH2C bool
+
+	Compress CompressConfig
+}
+
+//CompressConfig 响应压缩
+type CompressConfig struct {
+	//关闭压缩
+	IsDisable bool
+	//小于该大小(字节)的响应不压缩，默认1024，小于0表示都压缩
+	MinSize int
+	//启用的压缩算法，可选br、zstd、gzip、deflate，默认全部，q值相同时按这里的顺序
+	Encodings []string
+	//不压缩的Content-Type，支持image/*这样的写法，已经压缩过的图片、视频、压缩包等默认不压缩
+	ExcludeTypes []string
 }
 
 //GrpcServerConfig ..
@@ -107,6 +195,19 @@ type LoggerConfig struct {
 	LogMaxNum int32
 	LogSize   int64
 	LogUnit   string
+	//访问日志，每个http、grpc请求一条json
+	AccessLog AccessLogConfig
+}
+
+//AccessLogConfig 访问日志，开启后替换原来的Path、Method、CostTime日志
+type AccessLogConfig struct {
+	IsEnable bool
+	//输出的字段和顺序，默认全部，见hfw.AccessLogFields
+	Fields []string
+	//采样率，0到1，默认1，status>=500或者err_no不是0的总会记录
+	SampleRate float64
+	//stdout、stderr或者文件路径，默认stdout
+	Output string
 }
 
 //DbConfig ..
@@ -155,13 +256,119 @@ type TemplateConfig struct {
 	StaticPath  string
 	HTMLPath    string
 	WidgetsPath string
-	IsCache     bool
+	//默认的布局文件
+	Layout  string
+	IsCache bool
+	//监听模板文件的变化，删除对应的缓存，开发环境配合IsCache使用
+	IsWatch bool
+
+	//StaticHandler和StaticStripHandler响应的Cache-Control，如public, max-age=86400，为空不设置
+	StaticCacheControl string
+	//按后缀设置Cache-Control，优先于StaticCacheControl，如{".html"="no-cache", ".js"="public, max-age=31536000, immutable"}
+	StaticCacheControlExts map[string]string
+}
+
+//LimitConfig 限流配置，Route和Controller至少填一个
+type LimitConfig struct {
+	//匹配的路由规则，如/users/:id，以*结尾的按前缀匹配，如/admin/*，grpc是FullMethod
+	Route string
+	//匹配的controller名称，不区分大小写
+	Controller string
+
+	//同时处理的最大请求数，0表示不限制，超出返回503
+	MaxInFlight int
+	//超出MaxInFlight时最多排队的请求数，0表示直接拒绝
+	MaxWaiting int
+	//排队的最长时间，默认1s
+	WaitTimeout time.Duration
+
+	//令牌桶每秒生成的令牌数，0表示不限制，超出返回429
+	Rate float64
+	//令牌桶的容量，默认等于Rate
+	Burst int
+	//令牌桶的key，空表示整个路由共用，可选ip、user、header:X-Api-Key
+	KeyBy string
+
+	//local或者redis，redis用于多个实例共享限制，使用默认的redis实例
+	Store string
 }
 
 //RouteConfig ..
 type RouteConfig struct {
 	DefaultController string
 	DefaultAction     string
+	//url和Accept-Version都没有指定版本时使用的版本，如v1
+	DefaultVersion string
+	//不为空则开启路由表的查看，如/debug/routes
+	RoutesPath string
+	//不为空则输出OpenAPI 3的文档，如/openapi.json
+	OpenAPIPath string
+	//文档的标题，默认是程序名
+	OpenAPITitle string
+	//不为空则开启Swagger UI，如/swagger，需要同时设置OpenAPIPath
+	SwaggerUIPath string
+}
+
+//ShutdownConfig 退出配置
+type ShutdownConfig struct {
+	//分阶段退出，先标记未就绪并从注册中心摘除，等待PreStopDelay后再停止接收请求
+	//开启后http服务由hfw关闭，kill -TERM时先启动继承监听的新进程，旧进程再按阶段退出
+	IsEnable bool
+	//PreStopDelay之后，等待请求处理完、业务方退出和执行清理函数的总超时时间，默认30s
+	Timeout time.Duration
+	//摘除后等待负载均衡感知的时间，需要开启IsEnable
+	PreStopDelay time.Duration
+	//每个清理函数的默认超时时间，默认5s，见signal.AddShutdownHook
+	HookTimeout time.Duration
+}
+
+//HealthConfig 健康检查，开启后检查已初始化的db、redis、mongo和grpc客户端连接
+type HealthConfig struct {
+	IsEnable bool
+	//存活检查的路由，默认/healthz
+	LivenessPath string
+	//就绪检查的路由，默认/readyz
+	ReadinessPath string
+	//每个检查的超时时间，默认3s
+	Timeout time.Duration
+	//需要检查剩余空间的目录
+	DiskPaths []string
+	//磁盘剩余空间的最低百分比，默认5
+	DiskMinFreePercent float64
+}
+
+//ReporterConfig 错误上报，panic总会上报，另外可以按状态码和err_no上报
+type ReporterConfig struct {
+	IsEnable bool
+	//需要上报的http状态码，如5xx、503，默认5xx，grpc的状态码按对应的http状态码判断
+	Statuses []string
+	//需要上报的err_no
+	ErrNos []int64
+	//相同指纹的错误在Window内只上报第一次，结束时再上报累计的次数，默认1m
+	Window time.Duration
+	//需要脱敏的header，不区分大小写，默认Authorization、Cookie、Set-Cookie、X-Csrf-Token
+	RedactHeaders []string
+	//写入文件，每行一条json
+	File string
+	//POST json到Webhook
+	Webhook string
+	//Webhook的超时时间，默认5s
+	WebhookTimeout time.Duration
+}
+
+//TracingConfig 链路追踪，使用W3C的traceparent在http、grpc之间传递
+type TracingConfig struct {
+	IsEnable bool
+	//默认是AppName
+	ServiceName string
+	//支持stdout、file、otlp，可以同时多个，默认stdout
+	Exporters []string
+	//file导出时的文件路径
+	File string
+	//otlp的http地址，默认localhost:4318
+	OTLPEndpoint string
+	//根span的采样率，0到1，默认1，有上游时跟随上游
+	SampleRatio float64
 }
 
 type HotDeployConfig struct {
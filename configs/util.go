@@ -78,6 +78,11 @@ func LoadDefaultConfig() (err error) {
 	return initDefaultConfig()
 }
 
+//InitDefaultConfig 手动设置Config后补上默认值，如测试
+func InitDefaultConfig() error {
+	return initDefaultConfig()
+}
+
 func initDefaultConfig() error {
 
 	//错误码基数，如果小于10就认为是位数
@@ -100,10 +105,45 @@ func initDefaultConfig() error {
 		Config.Route.DefaultAction = strings.ToLower(Config.Route.DefaultAction)
 	}
 
+	//默认1k以下的响应不压缩
+	if Config.Server.Compress.MinSize == 0 {
+		Config.Server.Compress.MinSize = 1024
+	}
+
+	if Config.Csrf.FieldName == "" {
+		Config.Csrf.FieldName = "_csrf"
+	}
+	if Config.Csrf.HeaderName == "" {
+		Config.Csrf.HeaderName = "X-CSRF-Token"
+	}
+	if Config.Csrf.CookieName == "" {
+		Config.Csrf.CookieName = "csrf_token"
+	}
+
+	if Config.I18n.DefaultLocale == "" {
+		Config.I18n.DefaultLocale = "zh-CN"
+	}
+	if Config.I18n.CookieName == "" {
+		Config.I18n.CookieName = "lang"
+	}
+
+	if Config.Health.LivenessPath == "" {
+		Config.Health.LivenessPath = "/healthz"
+	}
+	if Config.Health.ReadinessPath == "" {
+		Config.Health.ReadinessPath = "/readyz"
+	}
+	if Config.Health.DiskMinFreePercent <= 0 {
+		Config.Health.DiskMinFreePercent = 5
+	}
+
 	//转为绝对路径
 	if !filepath.IsAbs(Config.Template.HTMLPath) {
 		Config.Template.HTMLPath = filepath.Join(common.GetAppPath(), Config.Template.HTMLPath)
 	}
+	if len(Config.I18n.Path) > 0 && !filepath.IsAbs(Config.I18n.Path) {
+		Config.I18n.Path = filepath.Join(common.GetAppPath(), Config.I18n.Path)
+	}
 	if len(Config.Template.WidgetsPath) > 0 {
 		if !filepath.IsAbs(Config.Template.WidgetsPath) {
 			Config.Template.WidgetsPath = filepath.Join(common.GetAppPath(), Config.Template.WidgetsPath)
@@ -20,26 +20,34 @@ import (
 //HTTPContext ..
 //渲染模板的数据放Data
 //Json里的数据放Response
-//Layout的功能未实现 TODO
 type HTTPContext struct {
 	Ctx        context.Context    `json:"-"`
 	cancel     context.CancelFunc `json:"-"`
 	isCanceled bool               `json:"-"`
+	//SetTimeout之前的Ctx
+	timeoutParent context.Context
+	timeoutCancel context.CancelFunc
+	//超时来自Server.HandlerTimeout配置，SSE等流式输出不受限制
+	isDefaultTimeout bool
 
 	HTTPStatus int `json:"-"`
 
 	ResponseWriter http.ResponseWriter `json:"-"`
 	Request        *http.Request       `json:"-"`
 	Session        *session.Session    `json:"-"`
-	Layout         string              `json:"-"`
+	//布局文件，页面通过define定义布局里的block，见LayoutContentBlock
+	Layout string `json:"-"`
 	//对应的struct名称，大小写一致
 	Controller string `json:"-"`
 	//对应的struct方法的名称，大小写一致
 	Action string `json:"-"`
 	Path   string `json:"-"`
+	//匹配到的路由规则，如/users/:id
+	Route  string `json:"-"`
+	params Params
 
-	IsZip bool `json:"-"`
-	//404和500页面被自动更改content-type，导致压缩后有问题，暂时不压缩
+	//是否压缩响应，设置为false则不压缩
+	IsZip   bool `json:"-"`
 	IsError bool `json:"-"`
 
 	//html文本
@@ -51,6 +59,8 @@ type HTTPContext struct {
 	FuncMap map[string]interface{} `json:"-"`
 
 	IsJSON bool `json:"-"`
+	//输出格式，如xml、msgpack，见RegisterRenderer
+	Format string `json:"-"`
 	//返回的json是否包含Header
 	HasHeader bool `json:"-"`
 	//是否只返回Response.Results里的数据
@@ -62,6 +72,28 @@ type HTTPContext struct {
 	IsCloseRender bool `json:"-"`
 
 	hijacked bool
+	//已经开始SSE之类的流式输出
+	isStreaming bool
+	//HandlerFunc或者中间件已经输出了状态码，panic时不再返回500
+	isWritten bool
+
+	//grpc请求的类型，GRPC或者Stream
+	method string
+	//被中间件中止的原因
+	abortErr error
+	//访问日志用到的请求结果
+	access accessInfo
+	//已经上报过错误
+	isReported bool
+	//当前请求的语言，见Locale
+	locale string
+	//Upload写入的临时文件，请求结束后删除
+	uploadFiles []string
+	//请求体已经按MaxBodySize限制
+	isUploadLimited bool
+
+	csrfToken  string
+	csrfExempt bool
 
 	*logger.Logger
 }
@@ -73,8 +105,13 @@ func initCtx(w http.ResponseWriter, r *http.Request) *HTTPContext {
 
 	httpCtx.HTTPStatus = http.StatusOK
 
+	//访问日志需要状态码和输出的字节数
+	if accessLog.isEnable {
+		w = &accessResponseWriter{ResponseWriter: w}
+	}
 	httpCtx.ResponseWriter = w
 	httpCtx.Request = r
+	httpCtx.params = GetParams(r)
 
 	httpCtx.Data = make(map[string]interface{})
 	httpCtx.FuncMap = make(map[string]interface{})
@@ -156,12 +193,26 @@ func (httpCtx *HTTPContext) Cancel() {
 		return
 	}
 	httpCtx.isCanceled = true
+	httpCtx.removeUploadFiles()
+	if httpCtx.timeoutCancel != nil {
+		httpCtx.timeoutCancel()
+	}
 	signal.GetSignalContext().WgDone()
 	httpCtx.cancel()
 	//不能赋值nil，否则导致打印log报错
 	// httpCtx.Logger = nil
 }
 
+//Param 获取路由参数，如/users/:id里的id
+func (httpCtx *HTTPContext) Param(name string) string {
+	return httpCtx.params.Get(name)
+}
+
+//Params 获取所有路由参数
+func (httpCtx *HTTPContext) Params() Params {
+	return httpCtx.params
+}
+
 //GetForm 优先post和put,然后get
 func (httpCtx *HTTPContext) GetForm(key string) string {
 	return strings.TrimSpace(httpCtx.Request.FormValue(key))
@@ -193,19 +244,40 @@ func (httpCtx *HTTPContext) ThrowCheck(errNo int64, i interface{}) {
 	if i == nil || errNo == 0 {
 		return
 	}
+	httpCtx.setErr(3, errNo, i)
+
+	httpCtx.StopRun()
+}
+
+//ThrowCheckArgs 同ThrowCheck，args是多语言错误信息的模板参数
+func (httpCtx *HTTPContext) ThrowCheckArgs(errNo int64, i interface{}, args ...interface{}) {
+	if i == nil || errNo == 0 {
+		return
+	}
+	httpCtx.setErr(3, errNo, i, args...)
+
+	httpCtx.StopRun()
+}
+
+//calldepth用于记录调用ThrowCheck的位置
+func (httpCtx *HTTPContext) setErr(calldepth int, errNo int64, i interface{}, args ...interface{}) {
 	var errMsg string
 	switch e := i.(type) {
 	case *common.RespErr:
 		errNo = e.ErrNo()
 		errMsg = e.ErrMsg()
-		httpCtx.Output(2, fmt.Sprintf("[ThrowCheck] %s", e.Error()))
+		httpCtx.Output(calldepth, fmt.Sprintf("[ThrowCheck] %s", e.Error()))
+		//如参数校验失败的各字段错误
+		if d := e.Details(); d != nil && httpCtx.Results == nil {
+			httpCtx.Results = d
+		}
 	default:
 		errMsg = fmt.Sprintf("%v", e)
-		httpCtx.Output(2, fmt.Sprintf("[ThrowCheck] No:%d Msg:%v", errNo, errMsg))
+		httpCtx.Output(calldepth, fmt.Sprintf("[ThrowCheck] No:%d Msg:%v", errNo, errMsg))
 	}
 
 	httpCtx.ErrNo = errNo
-	httpCtx.ErrMsg = common.GetErrorMap(errNo)
+	httpCtx.ErrMsg = httpCtx.errorMsg(errNo, args)
 	if len(httpCtx.ErrMsg) == 0 {
 		httpCtx.ErrMsg = errMsg
 	}
@@ -213,12 +285,20 @@ func (httpCtx *HTTPContext) ThrowCheck(errNo int64, i interface{}) {
 	if httpCtx.ErrNo < Config.ErrorBase && Config.AppID > 0 {
 		httpCtx.ErrNo = Config.AppID*Config.ErrorBase + httpCtx.ErrNo
 	}
-
-	httpCtx.StopRun()
 }
 
 //CheckErr
 func (httpCtx *HTTPContext) CheckErr(errNo int64, i interface{}) (int64, string) {
+	return httpCtx.checkErr(errNo, i, nil)
+}
+
+//CheckErrArgs 同CheckErr，args是多语言错误信息的模板参数
+func (httpCtx *HTTPContext) CheckErrArgs(errNo int64, i interface{}, args ...interface{}) (int64, string) {
+	return httpCtx.checkErr(errNo, i, args)
+}
+
+//calldepth固定，只能由CheckErr和CheckErrArgs调用
+func (httpCtx *HTTPContext) checkErr(errNo int64, i interface{}, args []interface{}) (int64, string) {
 	var errMsg string
 	if i == nil || errNo == 0 {
 		return 0, errMsg
@@ -227,13 +307,13 @@ func (httpCtx *HTTPContext) CheckErr(errNo int64, i interface{}) (int64, string)
 	case *common.RespErr:
 		errNo = e.ErrNo()
 		errMsg = e.ErrMsg()
-		httpCtx.Output(2, fmt.Sprintf("[CheckErr] %s", e.Error()))
+		httpCtx.Output(3, fmt.Sprintf("[CheckErr] %s", e.Error()))
 	default:
 		errMsg = fmt.Sprintf("%v", e)
-		httpCtx.Output(2, fmt.Sprintf("[CheckErr] No:%d Msg:%v", errNo, errMsg))
+		httpCtx.Output(3, fmt.Sprintf("[CheckErr] No:%d Msg:%v", errNo, errMsg))
 	}
 
-	httpCtx.ErrMsg = common.GetErrorMap(errNo)
+	httpCtx.ErrMsg = httpCtx.errorMsg(errNo, args)
 	if httpCtx.ErrMsg != "" {
 		errMsg = httpCtx.ErrMsg
 	}
@@ -3,7 +3,6 @@ package hfw
 //手动匹配路由
 import (
 	"net/http"
-	"strings"
 
 	"github.com/gorilla/websocket"
 	"github.com/hsyan2008/hfw/configs"
@@ -24,6 +23,12 @@ type ControllerInterface interface {
 	ServerError(*HTTPContext)
 }
 
+//LayoutInterface controller实现DefaultLayout方法，指定该controller默认的布局
+//布局文件的查找方式和模板文件一样，返回空表示不使用布局
+type LayoutInterface interface {
+	DefaultLayout() string
+}
+
 //确认Controller实现了接口 ControllerInterface
 var _ ControllerInterface = &Controller{}
 
@@ -38,18 +43,17 @@ func (ctl *Controller) Init(httpCtx *HTTPContext) {
 
 	// logger.Debug("Controller init")
 
-	if strings.Contains(httpCtx.Request.URL.RawQuery, "format=json") {
-		httpCtx.IsJSON = true
-	} else if strings.Contains(httpCtx.Request.Header.Get("Accept"), "application/json") {
+	//按format参数或者Accept选择输出格式
+	httpCtx.Format = negotiateFormat(httpCtx.Request)
+	if httpCtx.Format == FormatJSON {
 		httpCtx.IsJSON = true
 	}
 
-	if strings.Contains(httpCtx.Request.Header.Get("Accept-Encoding"), "gzip") {
+	//按Accept-Encoding选择压缩算法，见Server.Compress配置
+	if negotiateEncoding(httpCtx.Request) != "" {
 		httpCtx.IsZip = true
 	}
 
-	// _ = httpCtx.Request.ParseMultipartForm(2 * 1024 * 1024)
-
 	//开启session，暂时只支持redis
 	if configs.Config.EnableSession || configs.Config.Session.IsEnable {
 		if redis.DefaultIns != nil {
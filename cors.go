@@ -0,0 +1,152 @@
+package hfw
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hsyan2008/hfw/configs"
+)
+
+var defaultCorsMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+type corsGroup struct {
+	prefix string
+	conf   configs.CorsConfig
+}
+
+//按前缀长度倒序
+var corsGroups = struct {
+	sync.RWMutex
+	list []corsGroup
+}{}
+
+//Cors 分组的跨域配置，覆盖Cors配置，对子分组也生效
+//预检请求在路由分发前处理，如果注册了ForOPTIONS结尾的方法则交给该方法处理
+func (g *RouteGroup) Cors(conf configs.CorsConfig) {
+	prefix := strings.ToLower(g.prefix)
+	corsGroups.Lock()
+	defer corsGroups.Unlock()
+	for k, v := range corsGroups.list {
+		if v.prefix == prefix {
+			corsGroups.list[k].conf = conf
+			return
+		}
+	}
+	corsGroups.list = append(corsGroups.list, corsGroup{prefix: prefix, conf: conf})
+	sort.SliceStable(corsGroups.list, func(i, j int) bool {
+		return len(corsGroups.list[i].prefix) > len(corsGroups.list[j].prefix)
+	})
+}
+
+func corsConfig(path string) configs.CorsConfig {
+	path = strings.ToLower(path)
+	corsGroups.RLock()
+	defer corsGroups.RUnlock()
+	for _, v := range corsGroups.list {
+		if v.prefix == "" || path == v.prefix || strings.HasPrefix(path, v.prefix+"/") {
+			return v.conf
+		}
+	}
+
+	return Config.Cors
+}
+
+//设置跨域的响应头，返回true表示是预检请求并且已经响应
+func handleCors(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	conf := corsConfig(r.URL.Path)
+	if !conf.IsEnable {
+		return false
+	}
+
+	header := w.Header()
+	addVary(header, "Origin")
+	isPreflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+	if !matchOrigin(conf.AllowOrigins, origin) {
+		if isPreflight && !hasOptionsRoute(r) {
+			w.WriteHeader(http.StatusForbidden)
+			return true
+		}
+		return false
+	}
+
+	//允许cookie的时候不能用*
+	if !conf.AllowCredentials && len(conf.AllowOrigins) == 1 && conf.AllowOrigins[0] == "*" {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if conf.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !isPreflight {
+		if len(conf.ExposeHeaders) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(conf.ExposeHeaders, ", "))
+		}
+		return false
+	}
+
+	methods := conf.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultCorsMethods
+	}
+	header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if len(conf.AllowHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(conf.AllowHeaders, ", "))
+	} else if h := r.Header.Get("Access-Control-Request-Headers"); h != "" {
+		header.Set("Access-Control-Allow-Headers", h)
+		addVary(header, "Access-Control-Request-Headers")
+	}
+	if conf.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.FormatInt(int64(conf.MaxAge.Seconds()), 10))
+	}
+	//自定义的OPTIONS方法可以修改上面的响应头
+	if hasOptionsRoute(r) {
+		return false
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return true
+}
+
+func matchOrigin(allowOrigins []string, origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, v := range allowOrigins {
+		v = strings.ToLower(v)
+		switch {
+		case v == "*" || v == origin:
+			return true
+		case strings.Contains(v, "*."):
+			//https://*.a.com或者*.a.com
+			i := strings.Index(v, "*.")
+			scheme, suffix := v[:i], v[i+1:]
+			if strings.HasPrefix(origin, scheme) && strings.HasSuffix(origin, suffix) &&
+				len(origin) > len(scheme)+len(suffix) && (scheme != "" || strings.Contains(origin, "://")) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+//是否注册了OPTIONS方法的路由，如ForOPTIONS结尾的方法
+func hasOptionsRoute(r *http.Request) bool {
+	if rt, _ := routeTree.match(http.MethodOptions, splitPath(r.URL.Path), nil); rt != nil && rt.method == http.MethodOptions {
+		return true
+	}
+	controllerPath := completeURL(r.URL.Path)
+	if _, ok := routeMapMethod[fmt.Sprintf("%s/%sforOPTIONS", controllerPath, Config.Route.DefaultAction)]; ok {
+		return true
+	}
+	_, ok := routeMapMethod[controllerPath+"forOPTIONS"]
+
+	return ok
+}
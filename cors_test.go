@@ -0,0 +1,165 @@
+package hfw
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hsyan2008/hfw/configs"
+)
+
+type testCorsCtl struct {
+	Controller
+}
+
+func (ctl *testCorsCtl) Index(httpCtx *HTTPContext) {
+	httpCtx.Results = "index"
+}
+
+func (ctl *testCorsCtl) CustomForOPTIONS(httpCtx *HTTPContext) {
+	httpCtx.ResponseWriter.Header().Set("Access-Control-Allow-Methods", "PUT")
+	httpCtx.Results = "custom"
+}
+
+func corsHeader(origin, method string) http.Header {
+	header := http.Header{"Origin": {origin}}
+	if method != "" {
+		header.Set("Access-Control-Request-Method", method)
+		header.Set("Access-Control-Request-Headers", "X-Foo")
+	}
+
+	return header
+}
+
+func TestCorsPreflight(t *testing.T) {
+	old := Config.Cors
+	Config.Cors = configs.CorsConfig{IsEnable: true, AllowOrigins: []string{"https://a.com", "*.b.com"}, MaxAge: 10 * time.Minute}
+	defer func() { Config.Cors = old }()
+	HandlerFunc("/test_cors", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	w := serveTest("OPTIONS", "/test_cors", corsHeader("https://a.com", "POST"))
+	h := w.Header()
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 ||
+		h.Get("Access-Control-Allow-Origin") != "https://a.com" ||
+		h.Get("Access-Control-Allow-Methods") != strings.Join(defaultCorsMethods, ", ") ||
+		h.Get("Access-Control-Allow-Headers") != "X-Foo" ||
+		h.Get("Access-Control-Max-Age") != "600" ||
+		!strings.Contains(strings.Join(h["Vary"], ","), "Origin") {
+		t.Fatalf("preflight = %d %v", w.Code, h)
+	}
+
+	//不允许的域名
+	w = serveTest("OPTIONS", "/test_cors", corsHeader("https://c.com", "POST"))
+	if w.Code != http.StatusForbidden || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("preflight from other origin = %d %v", w.Code, w.Header())
+	}
+
+	//子域名的简单请求
+	w = serveTest("GET", "/test_cors", corsHeader("https://x.b.com", ""))
+	if w.Body.String() != "ok" || w.Header().Get("Access-Control-Allow-Origin") != "https://x.b.com" {
+		t.Fatalf("simple request = %q %v", w.Body.String(), w.Header())
+	}
+	w = serveTest("GET", "/test_cors", nil)
+	if w.Body.String() != "ok" || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("request without origin = %q %v", w.Body.String(), w.Header())
+	}
+}
+
+func TestCorsCredentials(t *testing.T) {
+	old := Config.Cors
+	defer func() { Config.Cors = old }()
+	HandlerFunc("/test_cors_credentials", func(w http.ResponseWriter, r *http.Request) {})
+
+	//允许cookie的时候不能用*
+	for _, credentials := range []bool{false, true} {
+		Config.Cors = configs.CorsConfig{IsEnable: true, AllowOrigins: []string{"*"}, AllowCredentials: credentials}
+		h := serveTest("GET", "/test_cors_credentials", corsHeader("https://a.com", "")).Header()
+		origin, allow := "*", ""
+		if credentials {
+			origin, allow = "https://a.com", "true"
+		}
+		if h.Get("Access-Control-Allow-Origin") != origin || h.Get("Access-Control-Allow-Credentials") != allow {
+			t.Fatalf("credentials %v = %v", credentials, h)
+		}
+	}
+}
+
+func TestCorsGroup(t *testing.T) {
+	old := Config.Cors
+	Config.Cors = configs.CorsConfig{IsEnable: true, AllowOrigins: []string{"https://a.com"}}
+	defer func() {
+		Config.Cors = old
+		corsGroups.list = nil
+	}()
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	g := Group("/test_cors_g")
+	g.Cors(configs.CorsConfig{IsEnable: true, AllowOrigins: []string{"https://g.com"}})
+	g.Group("sub").HandlerFunc("/x", handler)
+	HandlerFunc("/test_cors_other", handler)
+
+	cases := []struct {
+		path, origin, want string
+	}{
+		//子分组也使用分组的配置
+		{"/test_cors_g/sub/x", "https://g.com", "https://g.com"},
+		{"/test_cors_g/sub/x", "https://a.com", ""},
+		{"/test_cors_other", "https://a.com", "https://a.com"},
+		{"/test_cors_other", "https://g.com", ""},
+	}
+	for _, c := range cases {
+		h := serveTest("GET", c.path, corsHeader(c.origin, "")).Header()
+		if h.Get("Access-Control-Allow-Origin") != c.want {
+			t.Fatalf("%s from %s = %v, want %q", c.path, c.origin, h, c.want)
+		}
+	}
+}
+
+func TestCorsForOPTIONS(t *testing.T) {
+	old := Config.Cors
+	Config.Cors = configs.CorsConfig{IsEnable: true, AllowOrigins: []string{"https://a.com"}}
+	defer func() { Config.Cors = old }()
+	_ = Handler("/test_cors_ctl", &testCorsCtl{})
+
+	//注册了ForOPTIONS方法的交给该方法处理，可以修改响应头
+	w := serveTest("OPTIONS", "/test_cors_ctl/custom", corsHeader("https://a.com", "PUT"))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "custom") ||
+		w.Header().Get("Access-Control-Allow-Origin") != "https://a.com" ||
+		w.Header().Get("Access-Control-Allow-Methods") != "PUT" {
+		t.Fatalf("custom preflight = %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+
+	w = serveTest("OPTIONS", "/test_cors_ctl/index", corsHeader("https://a.com", "GET"))
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Fatalf("preflight = %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestCorsStatic(t *testing.T) {
+	old := Config.Cors
+	Config.Cors = configs.CorsConfig{IsEnable: true, AllowOrigins: []string{"https://a.com"}}
+	defer func() { Config.Cors = old }()
+	dir, err := ioutil.TempDir("", "hfw-cors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err = ioutil.WriteFile(filepath.Join(dir, "a.css"), []byte("body{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	StaticStripHandler("/test_cors_static", dir)
+
+	w := serveTest("GET", "/test_cors_static/a.css", corsHeader("https://a.com", ""))
+	if w.Body.String() != "body{}" || w.Header().Get("Access-Control-Allow-Origin") != "https://a.com" {
+		t.Fatalf("static = %q %v", w.Body.String(), w.Header())
+	}
+	w = serveTest("OPTIONS", "/test_cors_static/a.css", corsHeader("https://a.com", "GET"))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("static preflight = %d %v", w.Code, w.Header())
+	}
+}
@@ -39,7 +39,9 @@ func WrapCron(cmd func(httpCtx *HTTPContext) error) func() {
 		defer func(now time.Time) {
 			if err := recover(); err != nil {
 				if err != ErrStopRun {
-					httpCtx.Warn(err, string(common.GetStack()))
+					stack := common.GetStack()
+					httpCtx.Warn(err, string(stack))
+					reportPanic(httpCtx, "cron", err, stack)
 				}
 			}
 			httpCtx.Infof("CostTime: %s", time.Since(now))
@@ -48,6 +50,7 @@ func WrapCron(cmd func(httpCtx *HTTPContext) error) func() {
 		err := cmd(httpCtx)
 		if err != nil {
 			httpCtx.Warn(err)
+			reportCronError(httpCtx, err)
 		}
 	}
 }
@@ -0,0 +1,262 @@
+package hfw
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/hsyan2008/hfw/common"
+	"github.com/hsyan2008/hfw/configs"
+)
+
+//ErrCSRF csrf校验失败返回的错误，可以修改错误码和错误信息
+var ErrCSRF = common.NewRespErr(403, "invalid csrf token")
+
+//session里存放token的key
+const csrfSessionKey = "_csrf_token"
+
+func init() {
+	AddTemplateFuncs(template.FuncMap{
+		//如<meta name="csrf-token" content="{{csrfToken .}}">
+		"csrfToken": func(httpCtx *HTTPContext) string {
+			return httpCtx.CSRFToken()
+		},
+		//如<form method="post">{{csrfField .}}</form>，在range里请用$
+		"csrfField": func(httpCtx *HTTPContext) template.HTML {
+			return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(Config.Csrf.FieldName) +
+				`" value="` + template.HTMLEscapeString(httpCtx.CSRFToken()) + `">`)
+		},
+	})
+}
+
+//CsrfExempt 不校验csrf的中间件，用于路由、分组或者controller
+func CsrfExempt(httpCtx *HTTPContext, next func()) {
+	httpCtx.csrfExempt = true
+	next()
+}
+
+//CSRFToken 当前会话的csrf token，没有则生成
+func (httpCtx *HTTPContext) CSRFToken() string {
+	if httpCtx.csrfToken != "" || httpCtx.Request == nil {
+		return httpCtx.csrfToken
+	}
+
+	if httpCtx.isSessionCsrf() {
+		httpCtx.Session.Get(&httpCtx.csrfToken, csrfSessionKey)
+		if httpCtx.csrfToken == "" {
+			httpCtx.csrfToken = newCsrfToken()
+			httpCtx.Session.Set(csrfSessionKey, httpCtx.csrfToken)
+		}
+		return httpCtx.csrfToken
+	}
+
+	//double submit，需要在输出前写入cookie，见checkCsrf
+	httpCtx.csrfToken = httpCtx.GetCookie(Config.Csrf.CookieName)
+	if httpCtx.csrfToken == "" {
+		httpCtx.csrfToken = newCsrfToken()
+		http.SetCookie(httpCtx.ResponseWriter, &http.Cookie{
+			Name:     Config.Csrf.CookieName,
+			Value:    httpCtx.csrfToken,
+			Path:     "/",
+			Secure:   httpCtx.Request.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	return httpCtx.csrfToken
+}
+
+func (httpCtx *HTTPContext) isSessionCsrf() bool {
+	return (configs.Config.EnableSession || configs.Config.Session.IsEnable) && httpCtx.Session != nil
+}
+
+func newCsrfToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return common.GetPureUUID()
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+//校验不安全的请求，在Before之前执行，失败则ThrowCheck(ErrCSRF)
+func (httpCtx *HTTPContext) checkCsrf() {
+	if !Config.Csrf.IsEnable || httpCtx.Request == nil {
+		return
+	}
+	//cookie要在输出前写入
+	if !httpCtx.isSessionCsrf() {
+		httpCtx.CSRFToken()
+	}
+
+	switch httpCtx.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return
+	}
+	if httpCtx.csrfExempt || isCsrfExemptRoute(httpCtx) {
+		return
+	}
+
+	token := httpCtx.CSRFToken()
+
+	submitted := httpCtx.Request.Header.Get(Config.Csrf.HeaderName)
+	if submitted == "" {
+		if isMultipart(httpCtx.Request) {
+			var err error
+			submitted, err = httpCtx.csrfMultipartValue(Config.Csrf.FieldName)
+			if errors.Is(err, ErrUploadTooLarge) {
+				httpCtx.HTTPStatus = http.StatusRequestEntityTooLarge
+				httpCtx.ThrowCheck(ErrUploadTooLarge.ErrNo(), ErrUploadTooLarge)
+			}
+		} else {
+			submitted = httpCtx.Request.FormValue(Config.Csrf.FieldName)
+		}
+	}
+	if submitted == "" || subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+		httpCtx.HTTPStatus = http.StatusForbidden
+		httpCtx.ThrowCheck(ErrCSRF.ErrNo(), ErrCSRF)
+	}
+}
+
+//HandlerFunc注册的路由没有Finish，校验失败时直接输出
+func (httpCtx *HTTPContext) checkHandlerCsrf() (ok bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			if err != ErrStopRun {
+				panic(err)
+			}
+			httpCtx.RenderResponse()
+		}
+	}()
+	//和controller一样，先限制请求体大小
+	httpCtx.checkUploadBody()
+	httpCtx.checkCsrf()
+
+	return true
+}
+
+//multipart的请求按顺序读取字段直到找到token，不解析整个请求体，以便Upload流式读取
+//读过的内容放回请求体，超过defaultMultipartMemory的部分写入临时文件，所以csrfField最好放在文件之前
+func (httpCtx *HTTPContext) csrfMultipartValue(name string) (value string, err error) {
+	r := httpCtx.Request
+	if r.MultipartForm != nil {
+		return r.FormValue(name), nil
+	}
+	defer func() {
+		if value == "" {
+			value = r.URL.Query().Get(name)
+		}
+	}()
+	_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if params["boundary"] == "" || r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+
+	spool := &spoolBuffer{max: defaultMultipartMemory}
+	mr := multipart.NewReader(io.TeeReader(r.Body, spool), params["boundary"])
+	for {
+		var part *multipart.Part
+		part, err = mr.NextPart()
+		if err != nil {
+			break
+		}
+		if part.FormName() == name && part.FileName() == "" {
+			b, _ := io.ReadAll(io.LimitReader(part, 1024))
+			value = string(b)
+			break
+		}
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	if spool.err != nil {
+		err = spool.err
+	}
+	if spool.file != nil {
+		httpCtx.uploadFiles = append(httpCtx.uploadFiles, spool.file.Name())
+	}
+	body, e := spool.reader()
+	if e != nil {
+		return value, e
+	}
+	r.Body = &prefixBody{Reader: io.MultiReader(body, r.Body), Closer: &spoolCloser{spool: spool, body: r.Body}}
+
+	return
+}
+
+//spoolBuffer 先写内存，超过max后写入临时文件
+type spoolBuffer struct {
+	max  int64
+	mem  bytes.Buffer
+	file *os.File
+	err  error
+}
+
+func (b *spoolBuffer) Write(p []byte) (n int, err error) {
+	if b.file == nil && int64(b.mem.Len()+len(p)) <= b.max {
+		return b.mem.Write(p)
+	}
+	if b.file == nil {
+		if b.file, b.err = os.CreateTemp(Config.Upload.TempDir, "hfw-csrf-"); b.err != nil {
+			return 0, b.err
+		}
+	}
+	if n, err = b.file.Write(p); err != nil {
+		b.err = err
+	}
+
+	return
+}
+
+func (b *spoolBuffer) reader() (io.Reader, error) {
+	if b.file == nil {
+		return &b.mem, nil
+	}
+	if _, err := b.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return io.MultiReader(&b.mem, b.file), nil
+}
+
+type spoolCloser struct {
+	spool *spoolBuffer
+	body  io.Closer
+}
+
+func (c *spoolCloser) Close() error {
+	if c.spool.file != nil {
+		_ = c.spool.file.Close()
+	}
+
+	return c.body.Close()
+}
+
+func isCsrfExemptRoute(httpCtx *HTTPContext) bool {
+	route := httpCtx.Route
+	if route == "" {
+		route = httpCtx.Request.URL.Path
+	}
+	route = strings.ToLower(route)
+	for _, v := range Config.Csrf.ExemptRoutes {
+		v = strings.ToLower(v)
+		if strings.HasSuffix(v, "*") {
+			if strings.HasPrefix(route, strings.TrimSuffix(v, "*")) {
+				return true
+			}
+		} else if route == v {
+			return true
+		}
+	}
+
+	return false
+}
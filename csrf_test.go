@@ -0,0 +1,238 @@
+package hfw
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hsyan2008/hfw/configs"
+	"github.com/hsyan2008/hfw/session"
+)
+
+func setTestCsrf() (restore func()) {
+	old := Config
+	Config.Csrf = configs.CsrfConfig{
+		IsEnable:   true,
+		FieldName:  "_csrf",
+		HeaderName: "X-CSRF-Token",
+		CookieName: "csrf_token",
+	}
+
+	return func() { Config = old }
+}
+
+//返回checkCsrf之后的状态码
+func runCheckCsrf(httpCtx *HTTPContext) (status int) {
+	defer func() {
+		if err := recover(); err != nil && err != ErrStopRun {
+			panic(err)
+		}
+		status = httpCtx.HTTPStatus
+	}()
+	httpCtx.checkCsrf()
+
+	return
+}
+
+func newCsrfCtx(method, body string, cookie string, header string) (*HTTPContext, *httptest.ResponseRecorder) {
+	r := httptest.NewRequest(method, "/test_csrf", strings.NewReader(body))
+	if body != "" {
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if cookie != "" {
+		r.AddCookie(&http.Cookie{Name: "csrf_token", Value: cookie})
+	}
+	if header != "" {
+		r.Header.Set("X-CSRF-Token", header)
+	}
+	w := httptest.NewRecorder()
+
+	return initCtx(w, r), w
+}
+
+func TestCsrfSafeMethods(t *testing.T) {
+	defer setTestCsrf()()
+
+	for _, method := range []string{"GET", "HEAD", "OPTIONS", "TRACE"} {
+		httpCtx, w := newCsrfCtx(method, "", "", "")
+		if status := runCheckCsrf(httpCtx); status != http.StatusOK {
+			t.Fatalf("%s = %d", method, status)
+		}
+		//double submit的cookie在安全的请求里下发
+		if !strings.Contains(w.Header().Get("Set-Cookie"), "csrf_token="+httpCtx.CSRFToken()) {
+			t.Fatalf("%s Set-Cookie = %q", method, w.Header().Get("Set-Cookie"))
+		}
+		httpCtx.Cancel()
+	}
+}
+
+func TestCsrfDoubleSubmit(t *testing.T) {
+	defer setTestCsrf()()
+
+	cases := []struct {
+		name   string
+		body   string
+		cookie string
+		header string
+		status int
+	}{
+		{"no token", "", "tk", "", http.StatusForbidden},
+		{"header", "", "tk", "tk", http.StatusOK},
+		{"form", "_csrf=tk", "tk", "", http.StatusOK},
+		{"mismatch", "_csrf=other", "tk", "", http.StatusForbidden},
+		//没有cookie时生成新的token，提交旧的无效
+		{"no cookie", "_csrf=tk", "", "", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		httpCtx, w := newCsrfCtx("POST", c.body, c.cookie, c.header)
+		if status := runCheckCsrf(httpCtx); status != c.status {
+			t.Fatalf("%s = %d, want %d", c.name, status, c.status)
+		}
+		if c.cookie == "" && (httpCtx.CSRFToken() == "tk" || w.Header().Get("Set-Cookie") == "") {
+			t.Fatalf("%s should issue a new token", c.name)
+		}
+		httpCtx.Cancel()
+	}
+
+	//豁免的路由和中间件
+	Config.Csrf.ExemptRoutes = []string{"/test_csrf*"}
+	httpCtx, _ := newCsrfCtx("POST", "", "tk", "")
+	if status := runCheckCsrf(httpCtx); status != http.StatusOK {
+		t.Fatalf("exempt route = %d", status)
+	}
+	httpCtx.Cancel()
+	Config.Csrf.ExemptRoutes = nil
+	httpCtx, _ = newCsrfCtx("POST", "", "tk", "")
+	CsrfExempt(httpCtx, func() {})
+	if status := runCheckCsrf(httpCtx); status != http.StatusOK {
+		t.Fatalf("exempt middleware = %d", status)
+	}
+	httpCtx.Cancel()
+}
+
+type testSessionStore map[string]map[string]interface{}
+
+func (s testSessionStore) SetExpiration(int64) {}
+func (s testSessionStore) Put(id, k string, v interface{}) error {
+	if s[id] == nil {
+		s[id] = make(map[string]interface{})
+	}
+	s[id][k] = v
+	return nil
+}
+func (s testSessionStore) Get(v interface{}, id, k string) error {
+	if value, ok := s[id][k].(string); ok {
+		*v.(*string) = value
+	}
+	return nil
+}
+func (s testSessionStore) IsExist(id, k string) (bool, error) {
+	_, ok := s[id][k]
+	return ok, nil
+}
+func (s testSessionStore) Del(id, k string) error {
+	delete(s[id], k)
+	return nil
+}
+func (s testSessionStore) Destroy(id string) error {
+	delete(s, id)
+	return nil
+}
+func (s testSessionStore) Rename(id, newid string) error {
+	s[newid] = s[id]
+	delete(s, id)
+	return nil
+}
+
+func TestCsrfSession(t *testing.T) {
+	defer setTestCsrf()()
+	old := configs.Config.Session
+	configs.Config.Session = configs.SessionConfig{IsEnable: true, CookieName: "sess"}
+	defer func() { configs.Config.Session = old }()
+	store := make(testSessionStore)
+
+	newCtx := func(method, sessID, token string) *HTTPContext {
+		httpCtx, _ := newCsrfCtx(method, "", "", token)
+		httpCtx.Request.AddCookie(&http.Cookie{Name: "sess", Value: sessID})
+		httpCtx.Session = session.NewSession(httpCtx.Request, store, configs.Config.Session)
+		return httpCtx
+	}
+
+	httpCtx := newCtx("GET", "s1", "")
+	token := httpCtx.CSRFToken()
+	httpCtx.Cancel()
+	if token == "" || store["s1"][csrfSessionKey] != token {
+		t.Fatalf("token %q not stored in session: %v", token, store)
+	}
+
+	//同一个会话的token不变
+	httpCtx = newCtx("POST", "s1", token)
+	if status := runCheckCsrf(httpCtx); status != http.StatusOK || httpCtx.CSRFToken() != token {
+		t.Fatalf("same session = %d %q", status, httpCtx.CSRFToken())
+	}
+	httpCtx.Cancel()
+
+	//换了会话要用新的token
+	httpCtx = newCtx("POST", "s2", token)
+	if status := runCheckCsrf(httpCtx); status != http.StatusForbidden || httpCtx.CSRFToken() == token {
+		t.Fatalf("other session = %d", status)
+	}
+	httpCtx.Cancel()
+}
+
+//token在文件之后，并且读过的内容超过内存限制写入临时文件
+func TestCsrfMultipartTokenAfterFile(t *testing.T) {
+	defer setTestCsrf()()
+	oldMemory := defaultMultipartMemory
+	defaultMultipartMemory = 1 << 10
+	defer func() { defaultMultipartMemory = oldMemory }()
+	_ = Handler("/test_upload", &testUploadCtl{})
+
+	file := append(testPNG, bytes.Repeat([]byte{3}, 8<<10)...)
+	newRequest := func(token string) *http.Request {
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		_ = mw.WriteField("title", "hi")
+		fw, _ := mw.CreateFormFile("file", "a.png")
+		_, _ = fw.Write(file)
+		_ = mw.WriteField("_csrf", token)
+		_ = mw.Close()
+		r := httptest.NewRequest("POST", "/test_upload", body)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		r.AddCookie(&http.Cookie{Name: "csrf_token", Value: "tk"})
+		return r
+	}
+
+	w := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(w, newRequest("tk"))
+	want := `"results":{"IsParsed":false,"Files":"file:image/png","Title":"hi"}`
+	if w.Code != 200 || !strings.Contains(w.Body.String(), want) {
+		t.Fatalf("token after file = %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(w, newRequest("bad"))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("bad token after file = %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCsrfHandlerFunc(t *testing.T) {
+	defer setTestCsrf()()
+	var called int
+	HandlerFunc("/test_csrf_func/:id", func(w http.ResponseWriter, r *http.Request) {
+		called++
+	})
+
+	w := serveTest("POST", "/test_csrf_func/1", http.Header{"Cookie": {"csrf_token=tk"}})
+	if w.Code != http.StatusForbidden || called != 0 || !strings.Contains(w.Body.String(), `"err_no":403`) {
+		t.Fatalf("no token = %d %q, called %d", w.Code, w.Body.String(), called)
+	}
+	w = serveTest("POST", "/test_csrf_func/1", http.Header{"Cookie": {"csrf_token=tk"}, "X-Csrf-Token": {"tk"}})
+	if w.Code != http.StatusOK || called != 1 {
+		t.Fatalf("with token = %d %q, called %d", w.Code, w.Body.String(), called)
+	}
+}
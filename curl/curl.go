@@ -18,6 +18,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/hsyan2008/hfw/tracing"
 )
 
 type Response struct {
@@ -215,6 +217,17 @@ func (curls *Curl) Request() (rs *Response, err error) {
 	}
 
 	httpRequest = httpRequest.WithContext(curls.ctx)
+	if tracing.IsEnable() {
+		ctx, span := tracing.StartHTTPClient(curls.ctx, httpRequest)
+		httpRequest = httpRequest.WithContext(ctx)
+		defer func() {
+			var status int
+			if rs != nil && rs.Response != nil {
+				status = rs.StatusCode
+			}
+			tracing.EndHTTP(span, status, err)
+		}()
+	}
 
 	httpClient, err := curls.getHttpClient()
 	if err != nil {
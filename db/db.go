@@ -96,6 +96,8 @@ func getEngine(config configs.DbStdConfig) (engine *xorm.Engine, isNew bool, err
 		return engine, isNew, fmt.Errorf("NewEngine dbConfig: %v failed: %v", config, err)
 	}
 
+	engine.AddHook(tracingHook{driver: strings.ToLower(config.Driver)})
+
 	engineMap.Store(common.Md5(dbDsn), engine)
 	isNew = true
 
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hsyan2008/hfw/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"xorm.io/xorm/contexts"
+)
+
+type tracingSpanKey struct{}
+
+//tracingHook 通过XormDao.WithContext传入的ctx里有span时，每条sql创建子span
+type tracingHook struct {
+	driver string
+}
+
+func (h tracingHook) BeforeProcess(c *contexts.ContextHook) (context.Context, error) {
+	ctx, span, ok := tracing.StartChild(c.Ctx, "sql "+sqlOperation(c.SQL),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemKey.String(h.driver), semconv.DBStatementKey.String(c.SQL)))
+	if !ok {
+		return c.Ctx, nil
+	}
+
+	return context.WithValue(ctx, tracingSpanKey{}, span), nil
+}
+
+func (h tracingHook) AfterProcess(c *contexts.ContextHook) error {
+	if c.Ctx == nil {
+		return nil
+	}
+	if span, ok := c.Ctx.Value(tracingSpanKey{}).(trace.Span); ok {
+		tracing.End(span, c.Err)
+	}
+
+	return nil
+}
+
+//sql的第一个单词，如SELECT
+func sqlOperation(sql string) string {
+	sql = strings.TrimSpace(sql)
+	if i := strings.IndexAny(sql, " \t\n"); i > 0 {
+		sql = sql[:i]
+	}
+
+	return strings.ToUpper(sql)
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -47,6 +48,26 @@ type XormDao struct {
 	isCache bool
 	cacher  *caches.LRUCacher
 	sess    *xorm.Session
+	ctx     context.Context
+}
+
+//WithContext 返回使用ctx的副本，ctx取消或者超时后sql被中止，如dao.WithContext(httpCtx.Ctx)
+//副本不共享事务，需要事务的请在副本上NewSession
+func (d *XormDao) WithContext(ctx context.Context) *XormDao {
+	dao := *d
+	dao.ctx = ctx
+	dao.sess = nil
+
+	return &dao
+}
+
+func (d *XormDao) newSession() *xorm.Session {
+	sess := d.engine.NewSession()
+	if d.ctx != nil {
+		sess = sess.Context(d.ctx)
+	}
+
+	return sess
 }
 
 func (d *XormDao) GetConf() configs.DbConfig {
@@ -67,7 +88,7 @@ func (d *XormDao) UpdateByIds(t Model, params interface{},
 
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 	if len(cols) > 0 {
@@ -94,7 +115,7 @@ func (d *XormDao) UpdateByWhere(t Model, params Cond,
 
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 	sess, err = d.buildCond(t, sess, where, false, false)
@@ -113,7 +134,7 @@ func (d *XormDao) UpdateByWhere(t Model, params Cond,
 func (d *XormDao) Insert(m, t Model) (affected int64, err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 
@@ -128,7 +149,7 @@ func (d *XormDao) Insert(m, t Model) (affected int64, err error) {
 func (d *XormDao) InsertMulti(m Model, t interface{}) (affected int64, err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 
@@ -143,7 +164,7 @@ func (d *XormDao) InsertMulti(m Model, t interface{}) (affected int64, err error
 func (d *XormDao) SearchOne(t Model, cond Cond) (has bool, err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 	sess, err = d.buildCond(t, sess, cond, true, false)
@@ -327,7 +348,7 @@ FOR:
 func (d *XormDao) Search(t Model, ts interface{}, cond Cond) (err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 	sess, err = d.buildCond(t, sess, cond, true, true)
@@ -347,7 +368,7 @@ func (d *XormDao) Search(t Model, ts interface{}, cond Cond) (err error) {
 func (d *XormDao) SearchAndCount(t Model, ts interface{}, cond Cond) (total int64, err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 	sess, err = d.buildCond(t, sess, cond, true, true)
@@ -367,7 +388,7 @@ func (d *XormDao) SearchAndCount(t Model, ts interface{}, cond Cond) (total int6
 func (d *XormDao) Rows(t Model, cond Cond) (rows *xorm.Rows, err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 	sess, err = d.buildCond(t, sess, cond, true, true)
@@ -387,7 +408,7 @@ func (d *XormDao) Rows(t Model, cond Cond) (rows *xorm.Rows, err error) {
 func (d *XormDao) Iterate(t Model, cond Cond, f xorm.IterFunc) (err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 	sess, err = d.buildCond(t, sess, cond, true, true)
@@ -407,7 +428,7 @@ func (d *XormDao) Iterate(t Model, cond Cond, f xorm.IterFunc) (err error) {
 func (d *XormDao) GetByIds(t Model, ts interface{}, ids []interface{}, cols ...string) (err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 	if len(cols) > 0 {
@@ -426,7 +447,7 @@ func (d *XormDao) GetByIds(t Model, ts interface{}, ids []interface{}, cols ...s
 func (d *XormDao) Count(t Model, cond Cond) (total int64, err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 	sess, err = d.buildCond(t, sess, cond, false, false)
@@ -472,7 +493,7 @@ func (d *XormDao) Exec(sqlStr string, args ...interface{}) (rs sql.Result, err e
 
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 	rs, err = sess.Exec(tmp...)
@@ -487,7 +508,7 @@ func (d *XormDao) Exec(sqlStr string, args ...interface{}) (rs sql.Result, err e
 func (d *XormDao) Query(t Model, args ...interface{}) (rs []map[string][]byte, err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 	if len(args) > 0 {
@@ -513,7 +534,7 @@ func (d *XormDao) Query(t Model, args ...interface{}) (rs []map[string][]byte, e
 func (d *XormDao) QueryString(t Model, args ...interface{}) (rs []map[string]string, err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 	if len(args) > 0 {
@@ -539,7 +560,7 @@ func (d *XormDao) QueryString(t Model, args ...interface{}) (rs []map[string]str
 func (d *XormDao) QueryInterface(t Model, args ...interface{}) (rs []map[string]interface{}, err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 	if len(args) > 0 {
@@ -570,7 +591,7 @@ func (d *XormDao) DeleteByIds(t Model, ids interface{}) (affected int64, err err
 
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 
@@ -590,7 +611,7 @@ func (d *XormDao) DeleteByWhere(t Model, where Cond) (affected int64, err error)
 
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 	sess, err = d.buildCond(t, sess, where, false, false)
@@ -625,6 +646,14 @@ func (d *XormDao) ClearCache(t Model) {
 	}
 }
 
+//Ping 用于健康检查
+func (d *XormDao) Ping(ctx context.Context) error {
+	sess := d.engine.NewSession()
+	defer sess.Close()
+
+	return sess.PingContext(ctx)
+}
+
 //以下主要用于事务
 //用法
 //首先NewSession，然后defer Close
@@ -632,7 +661,7 @@ func (d *XormDao) ClearCache(t Model) {
 //Notice: 注意并发不安全，请勿在全局上使用
 func (d *XormDao) NewSession() {
 	if d.sess == nil {
-		d.sess = d.engine.NewSession()
+		d.sess = d.newSession()
 	}
 }
 
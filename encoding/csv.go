@@ -0,0 +1,120 @@
+package encoding
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+//CSVMarshal 把数据写成csv
+//支持[][]string，struct或者map的slice，单个struct或者map当成一行
+//struct的列名取json标签，map的列名按key排序
+func CSVMarshal(w io.Writer, data interface{}) (err error) {
+	cw := csv.NewWriter(w)
+	if rows, ok := data.([][]string); ok {
+		err = cw.WriteAll(rows)
+		return
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(data))
+	if !rv.IsValid() {
+		return
+	}
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		rv = reflect.ValueOf([]interface{}{rv.Interface()})
+	}
+
+	var columns []string
+	for i := 0; i < rv.Len(); i++ {
+		row := reflect.Indirect(rv.Index(i))
+		for row.Kind() == reflect.Interface || row.Kind() == reflect.Ptr {
+			row = row.Elem()
+		}
+		if i == 0 {
+			columns = csvColumns(row)
+			if len(columns) > 0 {
+				if err = cw.Write(columns); err != nil {
+					return
+				}
+			}
+		}
+		if err = cw.Write(csvRecord(row, columns)); err != nil {
+			return
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func csvColumns(row reflect.Value) (columns []string) {
+	switch row.Kind() {
+	case reflect.Struct:
+		for i := 0; i < row.NumField(); i++ {
+			sf := row.Type().Field(i)
+			if sf.PkgPath != "" {
+				continue
+			}
+			if name, _ := xmlFieldName(sf); name != "-" {
+				if name == "" {
+					name = sf.Name
+				}
+				columns = append(columns, name)
+			}
+		}
+	case reflect.Map:
+		for _, key := range row.MapKeys() {
+			columns = append(columns, fmt.Sprint(key.Interface()))
+		}
+		sort.Strings(columns)
+	}
+
+	return
+}
+
+func csvRecord(row reflect.Value, columns []string) (record []string) {
+	switch row.Kind() {
+	case reflect.Struct:
+		for i := 0; i < row.NumField(); i++ {
+			sf := row.Type().Field(i)
+			if sf.PkgPath != "" {
+				continue
+			}
+			if name, _ := xmlFieldName(sf); name != "-" {
+				record = append(record, csvCell(row.Field(i)))
+			}
+		}
+	case reflect.Map:
+		values := make(map[string]reflect.Value, row.Len())
+		for _, key := range row.MapKeys() {
+			values[fmt.Sprint(key.Interface())] = row.MapIndex(key)
+		}
+		for _, column := range columns {
+			record = append(record, csvCell(values[column]))
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < row.Len(); i++ {
+			record = append(record, csvCell(row.Index(i)))
+		}
+	default:
+		record = append(record, csvCell(row))
+	}
+
+	return
+}
+
+func csvCell(v reflect.Value) string {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return ""
+	}
+
+	return fmt.Sprint(v.Interface())
+}
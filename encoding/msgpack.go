@@ -0,0 +1,38 @@
+package encoding
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var (
+	//MsgPack 字段名和json一致
+	MsgPack   = Codec{msgpackMarshal, msgpackUnmarshal}
+	MsgPackIO = CodecIO{msgpackWriterMarshal, msgpackReaderUnmarshal}
+)
+
+func msgpackMarshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := msgpackWriterMarshal(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func msgpackUnmarshal(data []byte, v interface{}) error {
+	return msgpackReaderUnmarshal(bytes.NewReader(data), v)
+}
+
+func msgpackWriterMarshal(w io.Writer, data interface{}) (err error) {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(data)
+}
+
+func msgpackReaderUnmarshal(r io.Reader, data interface{}) (err error) {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	return dec.Decode(data)
+}
@@ -0,0 +1,218 @@
+package encoding
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+//XMLRootName 没有实现xml.Marshaler的数据，最外层的标签名
+var XMLRootName = "response"
+
+var (
+	//XML 支持map、slice和只有json标签的struct
+	//slice的每个元素用<item>包裹，struct的字段名优先取xml标签，其次json标签
+	XML   = Codec{xmlMarshal, xmlUnmarshal}
+	XMLIO = CodecIO{xmlWriterMarshal, xmlReaderUnmarshal}
+)
+
+func xmlMarshal(v interface{}) ([]byte, error) {
+	var b strings.Builder
+	if err := xmlWriterMarshal(&b, v); err != nil {
+		return nil, err
+	}
+	return []byte(b.String()), nil
+}
+
+func xmlUnmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+func xmlWriterMarshal(w io.Writer, data interface{}) (err error) {
+	_, err = io.WriteString(w, xml.Header)
+	if err != nil {
+		return
+	}
+	enc := xml.NewEncoder(w)
+	if m, ok := data.(xml.Marshaler); ok {
+		err = enc.Encode(m)
+	} else {
+		err = encodeXMLValue(enc, XMLRootName, reflect.ValueOf(data))
+	}
+	if err != nil {
+		return
+	}
+
+	return enc.Flush()
+}
+
+func xmlReaderUnmarshal(r io.Reader, data interface{}) (err error) {
+	return xml.NewDecoder(r).Decode(data)
+}
+
+var (
+	xmlMarshalerType  = reflect.TypeOf((*xml.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+func encodeXMLValue(enc *xml.Encoder, name string, rv reflect.Value) (err error) {
+	start := xml.StartElement{Name: xml.Name{Local: xmlName(name)}}
+	for rv.Kind() == reflect.Interface || rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return enc.EncodeElement("", start)
+		}
+		if rv.Type().Implements(xmlMarshalerType) || rv.Type().Implements(textMarshalerType) {
+			break
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return enc.EncodeElement("", start)
+	}
+
+	if rv.Type().Implements(xmlMarshalerType) {
+		return enc.EncodeElement(rv.Interface(), start)
+	}
+	if rv.Type() == timeType {
+		return enc.EncodeElement(rv.Interface().(time.Time).Format(time.RFC3339), start)
+	}
+	if rv.Type().Implements(textMarshalerType) {
+		b, err := rv.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		return enc.EncodeElement(string(b), start)
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		if err = enc.EncodeToken(start); err != nil {
+			return
+		}
+		if err = encodeXMLFields(enc, rv); err != nil {
+			return
+		}
+		return enc.EncodeToken(start.End())
+	case reflect.Map:
+		if err = enc.EncodeToken(start); err != nil {
+			return
+		}
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			if err = encodeXMLValue(enc, fmt.Sprint(key.Interface()), rv.MapIndex(key)); err != nil {
+				return
+			}
+		}
+		return enc.EncodeToken(start.End())
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			if rv.Kind() == reflect.Slice {
+				return enc.EncodeElement(base64.StdEncoding.EncodeToString(rv.Bytes()), start)
+			}
+		}
+		if err = enc.EncodeToken(start); err != nil {
+			return
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err = encodeXMLValue(enc, "item", rv.Index(i)); err != nil {
+				return
+			}
+		}
+		return enc.EncodeToken(start.End())
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return nil
+	}
+
+	return enc.EncodeElement(fmt.Sprint(rv.Interface()), start)
+}
+
+func encodeXMLFields(enc *xml.Encoder, rv reflect.Value) (err error) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		fv := rv.Field(i)
+		name, omitEmpty := xmlFieldName(sf)
+		if name == "-" {
+			continue
+		}
+		//没有标签的匿名struct，字段提升到上一层
+		if sf.Anonymous && name == "" {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				if err = encodeXMLFields(enc, fv); err != nil {
+					return
+				}
+				continue
+			}
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if err = encodeXMLValue(enc, name, fv); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func xmlFieldName(sf reflect.StructField) (name string, omitEmpty bool) {
+	for _, key := range []string{"xml", "json"} {
+		tag, ok := sf.Tag.Lookup(key)
+		if !ok {
+			continue
+		}
+		list := strings.Split(tag, ",")
+		for _, v := range list[1:] {
+			if v == "omitempty" {
+				omitEmpty = true
+			}
+		}
+		return list[0], omitEmpty
+	}
+
+	return
+}
+
+//把map的key之类的转为合法的标签名
+func xmlName(name string) string {
+	if name == "" {
+		return "_"
+	}
+	b := []rune(name)
+	for k, r := range b {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r > 0x7f {
+			continue
+		}
+		if k > 0 && (r == '-' || r == '.') {
+			continue
+		}
+		b[k] = '_'
+	}
+	if b[0] >= '0' && b[0] <= '9' {
+		return "_" + string(b)
+	}
+
+	return string(b)
+}
@@ -4,20 +4,24 @@ go 1.16
 
 require (
 	github.com/BurntSushi/toml v0.3.1
+	github.com/andybalholm/brotli v1.0.3
 	github.com/axgle/mahonia v0.0.0-20180208002826-3358181d7394
 	github.com/bippio/go-impala v2.1.0+incompatible // indirect
 	github.com/bradfitz/gomemcache v0.0.0-20190913173617-a41fca850d0b // indirect
 	github.com/coreos/etcd v3.3.25+incompatible // indirect
 	github.com/denisenkom/go-mssqldb v0.10.0 // indirect
 	github.com/fsnotify/fsnotify v1.4.9
+	github.com/globalsign/mgo v0.0.0-20181015135952-eeefdecb41b8
 	github.com/go-sql-driver/mysql v1.6.0
 	github.com/go-xorm/cachestore v0.0.0-20170409031804-adfa3466c8e4
+	github.com/golang/protobuf v1.5.2
 	github.com/google/uuid v1.2.0
 	github.com/gorilla/websocket v1.4.2
 	github.com/hashicorp/consul/api v1.8.1
 	github.com/hsyan2008/go-logger v0.0.0-20201030135914-f6dbda938bed
 	github.com/hsyan2008/gracehttp v0.0.0-20191130080041-8a1dc4ac8e6c
 	github.com/json-iterator/go v1.1.11
+	github.com/klauspost/compress v1.13.1
 	github.com/lib/pq v1.10.2 // indirect
 	github.com/mattn/go-sqlite3 v1.14.7 // indirect
 	github.com/mediocregopher/radix/v3 v3.7.0
@@ -25,9 +29,15 @@ require (
 	github.com/prometheus/client_golang v1.10.0
 	github.com/robfig/cron/v3 v3.0.1
 	github.com/shirou/gopsutil v3.21.4+incompatible
-	github.com/stretchr/testify v1.5.1 // indirect
+	github.com/stretchr/testify v1.7.0 // indirect
 	github.com/tklauser/go-sysconf v0.3.6 // indirect
-	golang.org/x/net v0.0.0-20210520170846-37e1c6afe023 // indirect
-	google.golang.org/grpc v1.38.0
+	github.com/vmihailenco/msgpack/v5 v5.3.4
+	go.opentelemetry.io/otel v1.0.1
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.0.1
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.0.1
+	go.opentelemetry.io/otel/sdk v1.0.1
+	go.opentelemetry.io/otel/trace v1.0.1
+	golang.org/x/net v0.0.0-20210520170846-37e1c6afe023
+	google.golang.org/grpc v1.41.0
 	xorm.io/xorm v1.1.0
 )
@@ -0,0 +1,178 @@
+package hfw
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+//RouteGroup 路由分组
+//通过分组注册的路由继承分组的前缀和中间件
+type RouteGroup struct {
+	prefix      string
+	middlewares []Middleware
+}
+
+//Group 创建路由分组，如Group("/admin", auth)
+func Group(prefix string, m ...Middleware) *RouteGroup {
+	return &RouteGroup{
+		prefix:      cleanPrefix(prefix),
+		middlewares: m,
+	}
+}
+
+//Version 创建版本分组，如Version("v1")，前缀是/v1
+//url上没有版本前缀的请求，按Accept-Version头或者Route.DefaultVersion配置选择版本
+func Version(version string, m ...Middleware) *RouteGroup {
+	version = strings.Trim(strings.ToLower(version), "/")
+	versions.Lock()
+	versions.list[version] = true
+	versions.Unlock()
+
+	return Group(version, m...)
+}
+
+//Group 创建子分组，继承前缀和中间件
+func (g *RouteGroup) Group(prefix string, m ...Middleware) *RouteGroup {
+	return &RouteGroup{
+		prefix:      g.prefix + cleanPrefix(prefix),
+		middlewares: append(g.copyMiddlewares(), m...),
+	}
+}
+
+//Use 添加分组的中间件，只对之后注册的路由生效
+func (g *RouteGroup) Use(m ...Middleware) {
+	g.middlewares = append(g.middlewares, m...)
+}
+
+//Prefix 分组的前缀
+func (g *RouteGroup) Prefix() string {
+	return g.prefix
+}
+
+//Handler 见hfw.Handler
+func (g *RouteGroup) Handler(pattern string, handler ControllerInterface, m ...Middleware) (err error) {
+	return Handler(g.join(pattern), handler, append(g.copyMiddlewares(), m...)...)
+}
+
+//Route 见hfw.Route
+func (g *RouteGroup) Route(method, pattern string, handler ControllerInterface, methodName string, m ...Middleware) {
+	Route(method, g.join(pattern), handler, methodName, append(g.copyMiddlewares(), m...)...)
+}
+
+//HandlerFunc 见hfw.HandlerFunc
+func (g *RouteGroup) HandlerFunc(pattern string, h http.HandlerFunc, m ...Middleware) {
+	HandlerFunc(g.join(pattern), h, append(g.copyMiddlewares(), m...)...)
+}
+
+//Handle 见hfw.Handle
+func (g *RouteGroup) Handle(pattern string, h http.Handler, m ...Middleware) {
+	g.HandlerFunc(pattern, h.ServeHTTP, m...)
+}
+
+//StaticHandler 见hfw.StaticHandler，查找文件时会去掉分组的前缀
+func (g *RouteGroup) StaticHandler(pattern string, dir string) {
+	staticHandler(g.prefix, pattern, dir, false, g.copyMiddlewares())
+}
+
+//StaticStripHandler 见hfw.StaticStripHandler
+func (g *RouteGroup) StaticStripHandler(pattern string, dir string) {
+	staticHandler(g.prefix, pattern, dir, true, g.copyMiddlewares())
+}
+
+//StaticFSHandler 见hfw.StaticFSHandler
+func (g *RouteGroup) StaticFSHandler(pattern string, fsys fs.FS) {
+	staticFileSystem(g.prefix, pattern, http.FS(fsys), fmt.Sprintf("%T", fsys), true, g.copyMiddlewares())
+}
+
+func (g *RouteGroup) join(pattern string) string {
+	pattern = strings.Trim(pattern, "/")
+	if pattern == "" {
+		if g.prefix == "" {
+			return "/"
+		}
+		return g.prefix
+	}
+
+	return g.prefix + "/" + pattern
+}
+
+//避免子分组和路由共用底层数组
+func (g *RouteGroup) copyMiddlewares() []Middleware {
+	return append([]Middleware{}, g.middlewares...)
+}
+
+func cleanPrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+
+	return "/" + prefix
+}
+
+var versions = struct {
+	sync.RWMutex
+	list map[string]bool
+}{
+	list: make(map[string]bool),
+}
+
+//url上没有版本前缀的请求，按Accept-Version或者默认版本补上前缀
+//只有补上后能匹配到注册的路由才修改，不影响/healthz、静态文件等没有版本的路由
+func negotiateVersion(r *http.Request) (ok bool) {
+	versions.RLock()
+	defer versions.RUnlock()
+	if len(versions.list) == 0 {
+		return
+	}
+
+	first := strings.ToLower(strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[0])
+	if versions.list[first] {
+		return
+	}
+
+	version := strings.ToLower(strings.TrimSpace(r.Header.Get("Accept-Version")))
+	if version == "" {
+		version = strings.ToLower(Config.Route.DefaultVersion)
+	}
+	if version == "" {
+		return
+	}
+	if !versions.list[version] {
+		//兼容Accept-Version: 1
+		if !versions.list["v"+version] {
+			return
+		}
+		version = "v" + version
+	}
+
+	path := "/" + version + r.URL.Path
+	if !isRouteExist(r, path) {
+		return
+	}
+	r.URL.Path = path
+	if r.URL.RawPath != "" {
+		r.URL.RawPath = "/" + version + r.URL.RawPath
+	}
+
+	return true
+}
+
+//path是否有注册的路由，包括带参数的路由、controller和HandlerFunc
+func isRouteExist(r *http.Request, path string) bool {
+	if !routeTree.isEmpty() {
+		if rt, _ := routeTree.match(r.Method, splitPath(path), nil); rt != nil {
+			return true
+		}
+	}
+	if _, _, ok := lookupInstance(path, r.Method); ok {
+		return true
+	}
+	_, pattern := http.DefaultServeMux.Handler(&http.Request{Method: r.Method, Host: r.Host, URL: &url.URL{Path: path}})
+
+	return pattern != "" && pattern != "/"
+}
@@ -0,0 +1,99 @@
+package hfw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testVersionCtl struct {
+	Controller
+}
+
+func (ctl *testVersionCtl) Index(httpCtx *HTTPContext) {
+	httpCtx.Results = "v1 user"
+}
+
+func serveTest(method, path string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestVersionNegotiate(t *testing.T) {
+	v1 := Version("v1")
+	v1.HandlerFunc("/test_vping", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("pong " + r.URL.Path))
+	})
+	_ = v1.Handler("/test_vuser", &testVersionCtl{})
+	HandlerFunc("/test_vhealth", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok " + r.URL.Path))
+	})
+	Config.Route.DefaultVersion = "v1"
+	defer func() { Config.Route.DefaultVersion = "" }()
+
+	accept := http.Header{"Accept-Version": {"1"}}
+	cases := []struct {
+		path   string
+		header http.Header
+		code   int
+		body   string
+	}{
+		{"/v1/test_vping", nil, 200, "pong /v1/test_vping"},
+		{"/test_vping", nil, 200, "pong /v1/test_vping"},
+		{"/test_vping", accept, 200, "pong /v1/test_vping"},
+		{"/test_vuser", accept, 200, "v1 user"},
+		//没有版本的路由不补前缀
+		{"/test_vhealth", nil, 200, "ok /test_vhealth"},
+		{"/test_vhealth", accept, 200, "ok /test_vhealth"},
+	}
+	for _, c := range cases {
+		w := serveTest("GET", c.path, c.header)
+		if w.Code != c.code || !strings.Contains(w.Body.String(), c.body) {
+			t.Fatalf("%s %v = %d %q, want %d %q", c.path, c.header, w.Code, w.Body.String(), c.code, c.body)
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	var calls []string
+	mark := func(name string) Middleware {
+		return func(httpCtx *HTTPContext, next func()) {
+			calls = append(calls, name)
+			next()
+		}
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path + " " + GetParams(r).Get("id")))
+	}
+	admin := Group("/test_admin/", mark("admin"))
+	admin.HandlerFunc("/before", handler)
+	users := admin.Group("users", mark("users"))
+	admin.Use(mark("late"))
+	users.HandlerFunc("/:id", handler, mark("route"))
+	admin.HandlerFunc("/", handler)
+
+	cases := []struct {
+		path  string
+		body  string
+		calls string
+	}{
+		{"/test_admin/before", "/test_admin/before ", "admin"},
+		//子分组继承创建时的中间件，之后Use的不影响
+		{"/test_admin/users/1", "/test_admin/users/1 1", "admin,users,route"},
+		{"/test_admin", "/test_admin ", "admin,late"},
+	}
+	for _, c := range cases {
+		calls = nil
+		w := serveTest("GET", c.path, nil)
+		if w.Code != 200 || w.Body.String() != c.body || strings.Join(calls, ",") != c.calls {
+			t.Fatalf("%s = %d %q %v, want %q %s", c.path, w.Code, w.Body.String(), calls, c.body, c.calls)
+		}
+	}
+}
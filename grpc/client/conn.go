@@ -17,6 +17,7 @@ import (
 	grpc "google.golang.org/grpc"
 	"google.golang.org/grpc/balancer/roundrobin"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/status"
 )
 
@@ -89,6 +90,20 @@ func GetConnWithAuth(ctx context.Context, c configs.GrpcConfig, authValue string
 	return
 }
 
+//ConnStates 所有连接的状态，key是ResolverScheme，用于健康检查
+func ConnStates() map[string]connectivity.State {
+	states := make(map[string]connectivity.State)
+	lock.Lock()
+	defer lock.Unlock()
+	for k, p := range connInstanceMap {
+		if p.c != nil {
+			states[k] = p.c.GetState()
+		}
+	}
+
+	return states
+}
+
 func removeClientConn(c configs.GrpcConfig, err error) {
 	code := status.Code(err)
 	if code != codes.Unavailable {
@@ -8,6 +8,8 @@ import (
 	"github.com/hsyan2008/hfw/common"
 	"github.com/hsyan2008/hfw/configs"
 	"github.com/hsyan2008/hfw/signal"
+	"github.com/hsyan2008/hfw/tracing"
+	"go.opentelemetry.io/otel/trace"
 	grpc "google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 )
@@ -56,7 +58,14 @@ func Do(httpCtx *hfw.HTTPContext, c configs.GrpcConfig,
 		return nil, common.NewRespErr(500, err)
 	}
 
-	ctx, cancel := context.WithTimeout(httpCtx.Ctx, timeout)
+	parent := httpCtx.Ctx
+	if tracing.IsEnable() {
+		var span trace.Span
+		parent, span = tracing.Start(parent, "Call Grpc:"+c.ServerName)
+		defer func() { tracing.End(span, err) }()
+	}
+
+	ctx, cancel := context.WithTimeout(parent, timeout)
 	defer cancel()
 
 	md, ok := metadata.FromOutgoingContext(httpCtx.Ctx)
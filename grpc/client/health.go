@@ -0,0 +1,35 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hsyan2008/hfw/configs"
+	"github.com/hsyan2008/hfw/health"
+	"google.golang.org/grpc/connectivity"
+)
+
+func init() {
+	if configs.Config.Health.IsEnable {
+		health.Register("grpc_client", health.Readiness, healthCheck)
+	}
+}
+
+//有连接处于TransientFailure或者Shutdown就失败
+func healthCheck(ctx context.Context) error {
+	var errs []string
+	for k, v := range ConnStates() {
+		if v == connectivity.TransientFailure || v == connectivity.Shutdown {
+			errs = append(errs, fmt.Sprintf("%s %s", k, v))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Strings(errs)
+
+	return errors.New(strings.Join(errs, ", "))
+}
@@ -2,11 +2,15 @@ package client
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/hsyan2008/hfw"
 	"github.com/hsyan2008/hfw/common"
+	"github.com/hsyan2008/hfw/reporter"
+	"github.com/hsyan2008/hfw/tracing"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
@@ -30,11 +34,20 @@ func UnaryClientInterceptor(ctx context.Context, method string, req, reply inter
 
 	defer func() {
 		if e := recover(); e != nil {
-			httpCtx.Fatal(e, string(common.GetStack()))
+			stack := common.GetStack()
+			httpCtx.Fatal(e, string(stack))
+			reportPanic(httpCtx, method, e, stack)
 		}
 	}()
 
-	return invoker(httpCtx, method, req, reply, cc, opts...)
+	if !tracing.IsEnable() {
+		return invoker(httpCtx, method, req, reply, cc, opts...)
+	}
+	//httpCtx可能是调用方的，不能修改它的Ctx
+	spanCtx, span := tracing.StartGrpcClient(httpCtx, method)
+	defer func() { tracing.EndGrpc(span, err) }()
+
+	return invoker(spanCtx, method, req, reply, cc, opts...)
 }
 
 func StreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
@@ -46,9 +59,33 @@ func StreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grp
 
 	defer func() {
 		if e := recover(); e != nil {
-			httpCtx.Fatal(e, string(common.GetStack()))
+			stack := common.GetStack()
+			httpCtx.Fatal(e, string(stack))
+			reportPanic(httpCtx, method, e, stack)
 		}
 	}()
 
-	return streamer(httpCtx, desc, cc, method, opts...)
+	if !tracing.IsEnable() {
+		return streamer(httpCtx, desc, cc, method, opts...)
+	}
+	//只记录建立stream的耗时
+	spanCtx, span := tracing.StartGrpcClient(httpCtx, method)
+	defer func() { tracing.EndGrpc(span, err) }()
+
+	return streamer(spanCtx, desc, cc, method, opts...)
+}
+
+func reportPanic(httpCtx *hfw.HTTPContext, method string, e interface{}, stack []byte) {
+	if !reporter.IsEnable() {
+		return
+	}
+	md, _ := metadata.FromOutgoingContext(httpCtx)
+	reporter.Report(&reporter.Event{
+		Source:  "grpc_client",
+		Kind:    reporter.KindPanic,
+		Message: fmt.Sprint(e),
+		Stack:   string(stack),
+		TraceID: httpCtx.GetTraceID(),
+		Request: &reporter.Request{Method: "GRPC", Path: method, Header: reporter.RedactHeader(md)},
+	})
 }
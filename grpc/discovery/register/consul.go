@@ -16,6 +16,7 @@ import (
 	"github.com/hsyan2008/go-logger"
 	utils "github.com/hsyan2008/hfw/common"
 	"github.com/hsyan2008/hfw/grpc/discovery/common"
+	"github.com/hsyan2008/hfw/health"
 	"github.com/hsyan2008/hfw/service/discovery/client"
 	"github.com/hsyan2008/hfw/signal"
 )
@@ -88,7 +89,12 @@ func (cr *ConsulRegister) Register(info common.RegisterInfo) (err error) {
 		ticker := time.NewTicker(time.Duration(info.UpdateInterval) * time.Second)
 		defer ticker.Stop()
 		for {
-			err = cr.client.Agent().UpdateTTL(cr.serviceID, "", check.Status)
+			//按Registry类型的检查更新状态，默认只有退出时是critical
+			status, output := consulapi.HealthPassing, ""
+			if report := health.Check(cr.ctx, health.Registry); !report.IsUp() {
+				status, output = consulapi.HealthCritical, report.Error()
+			}
+			err = cr.client.Agent().UpdateTTL(cr.serviceID, output, status)
 			if err != nil {
 				logger.Warn("update ttl of service error: ", err.Error())
 			}
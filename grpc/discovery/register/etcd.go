@@ -10,6 +10,7 @@ import (
 	"github.com/coreos/etcd/clientv3"
 	"github.com/hsyan2008/go-logger"
 	"github.com/hsyan2008/hfw/grpc/discovery/common"
+	"github.com/hsyan2008/hfw/health"
 	"github.com/hsyan2008/hfw/signal"
 )
 
@@ -69,10 +70,20 @@ func (er *EtcdRegister) Register(info common.RegisterInfo) (err error) {
 
 	go func() {
 		for {
+			report := health.Check(er.ctx, health.Registry)
 			getResp, err := er.client.Get(er.ctx, er.key)
 			logger.Debug(getResp, err)
 			if err != nil {
 				logger.Warn(er.key, err)
+			} else if !report.IsUp() {
+				//Registry检查失败时摘除，恢复后重新注册
+				if getResp.Count > 0 {
+					logger.Warn(er.key, "not ready:", report.Error())
+					_, err = er.client.Delete(er.ctx, er.key)
+					if err != nil {
+						logger.Warn(er.key, err)
+					}
+				}
 			} else if getResp.Count == 0 {
 				err = er.withAlive()
 				if err != nil {
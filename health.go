@@ -0,0 +1,54 @@
+package hfw
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/hsyan2008/hfw/configs"
+	"github.com/hsyan2008/hfw/db"
+	"github.com/hsyan2008/hfw/health"
+	"github.com/hsyan2008/hfw/nosql"
+	"github.com/hsyan2008/hfw/redis"
+)
+
+//注册内置的检查和路由，依赖的检查都是Readiness，不影响存活
+func initHealth(conf configs.HealthConfig) {
+	health.SetTimeout(conf.Timeout)
+
+	if Config.Db.Driver != "" {
+		health.Register("db", health.Readiness, func(ctx context.Context) error {
+			if p, ok := db.DefaultDao.(interface{ Ping(context.Context) error }); ok {
+				return p.Ping(ctx)
+			}
+			return nil
+		})
+	}
+	if len(Config.Redis.Addresses) > 0 {
+		health.Register("redis", health.Readiness, func(ctx context.Context) error {
+			return redis.DefaultIns.Ping()
+		})
+	}
+	if Config.Mongo.Address != "" {
+		health.Register("mongo", health.Readiness, func(ctx context.Context) error {
+			m, err := nosql.NewMongo(Config.Mongo.Address, Config.Mongo.Dbname)
+			if err != nil {
+				return err
+			}
+			defer m.Close()
+			return m.Ping()
+		})
+	}
+	//grpc客户端的检查在grpc/client里注册
+	for _, path := range conf.DiskPaths {
+		health.Register("disk:"+path, health.Readiness, health.DiskCheck(path, conf.DiskMinFreePercent))
+	}
+
+	//不经过中间件，避免被限流或者刷访问日志
+	for _, v := range []struct {
+		path string
+		kind health.Kind
+	}{{conf.LivenessPath, health.Liveness}, {conf.ReadinessPath, health.Readiness}} {
+		handlerFuncRoutes = append(handlerFuncRoutes, RouteInfo{Path: v.path, Kind: RouteKindHandlerFunc})
+		http.HandleFunc(v.path, health.Handler(v.kind))
+	}
+}
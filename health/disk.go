@@ -0,0 +1,30 @@
+// +build !windows
+
+package health
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+)
+
+//DiskCheck path所在磁盘的剩余空间低于minFreePercent(如5表示5%)时失败
+func DiskCheck(path string, minFreePercent float64) CheckFunc {
+	return func(ctx context.Context) error {
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(path, &st); err != nil {
+			return err
+		}
+		total := uint64(st.Blocks) * uint64(st.Bsize)
+		if total == 0 {
+			return nil
+		}
+		free := uint64(st.Bavail) * uint64(st.Bsize)
+		percent := float64(free) * 100 / float64(total)
+		if percent < minFreePercent {
+			return fmt.Errorf("%s free %.2f%% < %.2f%%", path, percent, minFreePercent)
+		}
+
+		return nil
+	}
+}
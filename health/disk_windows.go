@@ -0,0 +1,13 @@
+package health
+
+import (
+	"context"
+	"errors"
+)
+
+//DiskCheck windows暂不支持
+func DiskCheck(path string, minFreePercent float64) CheckFunc {
+	return func(ctx context.Context) error {
+		return errors.New("disk check is not supported on windows")
+	}
+}
@@ -0,0 +1,214 @@
+//Package health 健康检查，各组件注册检查函数，聚合后输出/healthz、/readyz
+//Liveness表示进程是否需要重启，Readiness表示是否可以接收请求
+//Registry用于服务注册的状态，默认只有退出的检查
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hsyan2008/hfw/encoding"
+	"github.com/hsyan2008/hfw/signal"
+)
+
+//Status ..
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+//Kind 检查的类型，可以用|组合
+type Kind int
+
+const (
+	Liveness Kind = 1 << iota
+	Readiness
+	//服务注册(consul、etcd)按这类检查摘除实例，依赖的检查需要显式加上
+	//否则依赖短暂故障时，所有实例会同时被摘除
+	Registry
+)
+
+//CheckFunc 返回nil表示正常，需要处理ctx的超时
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	kind Kind
+	f    CheckFunc
+}
+
+var (
+	mu      sync.RWMutex
+	checks  []check
+	timeout = 3 * time.Second
+)
+
+//ErrShuttingDown 开始退出后Readiness返回的错误
+var ErrShuttingDown = errors.New("shutting down")
+
+func init() {
+	Register("shutdown", Readiness|Registry, func(ctx context.Context) error {
+		if !signal.GetSignalContext().IsReady() {
+			return ErrShuttingDown
+		}
+		return nil
+	})
+}
+
+//Register 注册检查，同名的会被替换，按注册顺序输出
+func Register(name string, kind Kind, f CheckFunc) {
+	mu.Lock()
+	defer mu.Unlock()
+	for k, v := range checks {
+		if v.name == name {
+			checks[k] = check{name: name, kind: kind, f: f}
+			return
+		}
+	}
+	checks = append(checks, check{name: name, kind: kind, f: f})
+}
+
+//Unregister ..
+func Unregister(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for k, v := range checks {
+		if v.name == name {
+			checks = append(checks[:k], checks[k+1:]...)
+			return
+		}
+	}
+}
+
+//SetTimeout 每个检查的超时时间，默认3s
+func SetTimeout(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	mu.Lock()
+	timeout = d
+	mu.Unlock()
+}
+
+//CheckResult 单个检查的结果
+type CheckResult struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	//耗时，毫秒
+	Latency float64 `json:"latency_ms"`
+	Error   string  `json:"error,omitempty"`
+}
+
+//Report 聚合的结果，有一个失败就是down
+type Report struct {
+	Status Status        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+//IsUp ..
+func (r Report) IsUp() bool {
+	return r.Status == StatusUp
+}
+
+//Error 失败的检查，如db: timeout; redis: EOF
+func (r Report) Error() string {
+	var errs []string
+	for _, v := range r.Checks {
+		if v.Status != StatusUp {
+			errs = append(errs, v.Name+": "+v.Error)
+		}
+	}
+
+	return strings.Join(errs, "; ")
+}
+
+//Check 并发执行kind类型的检查
+func Check(ctx context.Context, kind Kind) Report {
+	mu.RLock()
+	list := make([]check, 0, len(checks))
+	for _, v := range checks {
+		if v.kind&kind != 0 {
+			list = append(list, v)
+		}
+	}
+	d := timeout
+	mu.RUnlock()
+
+	report := Report{Status: StatusUp, Checks: make([]CheckResult, len(list))}
+	var wg sync.WaitGroup
+	for k, v := range list {
+		wg.Add(1)
+		go func(k int, v check) {
+			defer wg.Done()
+			report.Checks[k] = run(ctx, v, d)
+		}(k, v)
+	}
+	wg.Wait()
+
+	for _, v := range report.Checks {
+		if v.Status != StatusUp {
+			report.Status = StatusDown
+			break
+		}
+	}
+
+	return report
+}
+
+func run(ctx context.Context, c check, d time.Duration) (result CheckResult) {
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if e := recover(); e != nil {
+				errCh <- fmt.Errorf("panic: %v", e)
+			}
+		}()
+		errCh <- c.f(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result = CheckResult{
+		Name:    c.name,
+		Status:  StatusUp,
+		Latency: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+
+	return
+}
+
+//Handler 输出kind类型的检查结果，失败时状态码是503
+func Handler(kind Kind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := Check(r.Context(), kind)
+		b, _ := encoding.JSON.Marshal(report)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		if report.IsUp() {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = w.Write(b)
+	}
+}
@@ -0,0 +1,57 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	SetTimeout(50 * time.Millisecond)
+	defer SetTimeout(3 * time.Second)
+	Register("test_up", Liveness|Readiness, func(ctx context.Context) error { return nil })
+	Register("test_down", Readiness, func(ctx context.Context) error { return errors.New("down") })
+	Register("test_slow", Readiness, func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(time.Second)
+		return nil
+	})
+	defer func() {
+		Unregister("test_up")
+		Unregister("test_down")
+		Unregister("test_slow")
+	}()
+
+	if r := Check(context.Background(), Liveness); !r.IsUp() || len(r.Checks) != 1 {
+		t.Fatalf("liveness = %+v", r)
+	}
+
+	start := time.Now()
+	r := Check(context.Background(), Readiness)
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("check not timeout, cost %s", time.Since(start))
+	}
+	if r.IsUp() {
+		t.Fatalf("readiness = %+v", r)
+	}
+	want := "test_down: down; test_slow: context deadline exceeded"
+	if r.Error() != want {
+		t.Fatalf("error = %q, want %q", r.Error(), want)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	Register("test_dep", Readiness, func(ctx context.Context) error { return errors.New("down") })
+	defer Unregister("test_dep")
+
+	//依赖的检查失败不影响服务注册
+	if r := Check(context.Background(), Registry); !r.IsUp() || len(r.Checks) != 1 || r.Checks[0].Name != "shutdown" {
+		t.Fatalf("registry = %+v", r)
+	}
+
+	Register("test_dep", Readiness|Registry, func(ctx context.Context) error { return errors.New("down") })
+	if r := Check(context.Background(), Registry); r.IsUp() {
+		t.Fatalf("registry = %+v", r)
+	}
+}
@@ -0,0 +1,200 @@
+package hfwtest
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/hsyan2008/hfw/configs"
+	"github.com/hsyan2008/hfw/db"
+)
+
+var _ db.Dao = &FakeDao{}
+
+//DaoCall 一次db.Dao方法的调用
+type DaoCall struct {
+	//方法名，如SearchOne
+	Method string
+	Model  db.Model
+	//除Model外的参数
+	Args []interface{}
+}
+
+//DaoHandler 返回的value按方法转为bool或者int64，没有第一个返回值的方法忽略value
+//Search之类的结果需要在handler里写入参数，见SetResult
+type DaoHandler func(call DaoCall) (value interface{}, err error)
+
+//FakeDao 实现db.Dao，记录所有调用，通过On设置返回
+type FakeDao struct {
+	mu       sync.Mutex
+	calls    []DaoCall
+	handlers map[string]DaoHandler
+
+	Conf configs.DbConfig
+}
+
+//NewFakeDao ..
+func NewFakeDao() *FakeDao {
+	return &FakeDao{handlers: make(map[string]DaoHandler)}
+}
+
+//On 设置方法的返回，如On("Count", func(call hfwtest.DaoCall) (interface{}, error) { return 10, nil })
+func (d *FakeDao) On(method string, h DaoHandler) *FakeDao {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers[method] = h
+
+	return d
+}
+
+//Calls 返回method的调用，method为空返回所有调用
+func (d *FakeDao) Calls(method string) (calls []DaoCall) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, v := range d.calls {
+		if method == "" || v.Method == method {
+			calls = append(calls, v)
+		}
+	}
+
+	return
+}
+
+//Reset 清空调用记录和设置的返回
+func (d *FakeDao) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = nil
+	d.handlers = make(map[string]DaoHandler)
+}
+
+func (d *FakeDao) call(method string, m db.Model, args ...interface{}) (interface{}, error) {
+	call := DaoCall{Method: method, Model: m, Args: args}
+	d.mu.Lock()
+	d.calls = append(d.calls, call)
+	h := d.handlers[method]
+	d.mu.Unlock()
+	if h == nil {
+		return nil, nil
+	}
+
+	return h(call)
+}
+
+func (d *FakeDao) callBool(method string, m db.Model, args ...interface{}) (bool, error) {
+	v, err := d.call(method, m, args...)
+	b, _ := v.(bool)
+
+	return b, err
+}
+
+func (d *FakeDao) callInt64(method string, m db.Model, args ...interface{}) (int64, error) {
+	v, err := d.call(method, m, args...)
+	if v == nil {
+		return 0, err
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), err
+	}
+
+	return 0, err
+}
+
+//SetResult 把src赋值给dst指向的变量，用于在DaoHandler里写入查询结果
+//如SetResult(call.Model, &User{Name: "a"})、SetResult(call.Args[0], []*User{...})
+func SetResult(dst, src interface{}) {
+	dv := reflect.ValueOf(dst)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		panic("SetResult: dst must be a non-nil pointer")
+	}
+	sv := reflect.ValueOf(src)
+	if sv.Kind() == reflect.Ptr && sv.Type() == dv.Type() {
+		sv = sv.Elem()
+	}
+	dv.Elem().Set(sv)
+}
+
+//GetConf ..
+func (d *FakeDao) GetConf() configs.DbConfig {
+	return d.Conf
+}
+
+//IsTableExist ..
+func (d *FakeDao) IsTableExist(beanOrTableName interface{}) (bool, error) {
+	return d.callBool("IsTableExist", nil, beanOrTableName)
+}
+
+//UpdateByIds ..
+func (d *FakeDao) UpdateByIds(t db.Model, params interface{}, ids []interface{}, cols ...string) (int64, error) {
+	return d.callInt64("UpdateByIds", t, params, ids, cols)
+}
+
+//UpdateByWhere ..
+func (d *FakeDao) UpdateByWhere(t db.Model, params db.Cond, where db.Cond) (int64, error) {
+	return d.callInt64("UpdateByWhere", t, params, where)
+}
+
+//Insert ..
+func (d *FakeDao) Insert(m, t db.Model) (int64, error) {
+	return d.callInt64("Insert", m, t)
+}
+
+//InsertMulti ..
+func (d *FakeDao) InsertMulti(m db.Model, t interface{}) (int64, error) {
+	return d.callInt64("InsertMulti", m, t)
+}
+
+//SearchOne ..
+func (d *FakeDao) SearchOne(t db.Model, cond db.Cond) (bool, error) {
+	return d.callBool("SearchOne", t, cond)
+}
+
+//Search ..
+func (d *FakeDao) Search(t db.Model, ts interface{}, cond db.Cond) error {
+	_, err := d.call("Search", t, ts, cond)
+	return err
+}
+
+//SearchAndCount ..
+func (d *FakeDao) SearchAndCount(t db.Model, ts interface{}, cond db.Cond) (int64, error) {
+	return d.callInt64("SearchAndCount", t, ts, cond)
+}
+
+//GetByIds ..
+func (d *FakeDao) GetByIds(t db.Model, ts interface{}, ids []interface{}, cols ...string) error {
+	_, err := d.call("GetByIds", t, ts, ids, cols)
+	return err
+}
+
+//Count ..
+func (d *FakeDao) Count(t db.Model, cond db.Cond) (int64, error) {
+	return d.callInt64("Count", t, cond)
+}
+
+//DeleteByIds ..
+func (d *FakeDao) DeleteByIds(t db.Model, ids interface{}) (int64, error) {
+	return d.callInt64("DeleteByIds", t, ids)
+}
+
+//DeleteByWhere ..
+func (d *FakeDao) DeleteByWhere(t db.Model, where db.Cond) (int64, error) {
+	return d.callInt64("DeleteByWhere", t, where)
+}
+
+//EnableCache ..
+func (d *FakeDao) EnableCache(t db.Model) {
+	_, _ = d.call("EnableCache", t)
+}
+
+//DisableCache ..
+func (d *FakeDao) DisableCache(t db.Model) {
+	_, _ = d.call("DisableCache", t)
+}
+
+//ClearCache ..
+func (d *FakeDao) ClearCache(t db.Model) {
+	_, _ = d.call("ClearCache", t)
+}
@@ -0,0 +1,143 @@
+//Package hfwtest 在进程内测试controller，不需要启动服务，也不需要真实的db和redis
+//如
+//
+//	func TestUser(t *testing.T) {
+//		s := hfwtest.Setup(t, func(c *configs.AllConfig) { c.Session.IsEnable = true })
+//		_ = hfw.Handler("/user", &User{})
+//		s.Dao.On("SearchOne", func(call hfwtest.DaoCall) (interface{}, error) {
+//			hfwtest.SetResult(call.Model, &User{Name: "a"})
+//			return true, nil
+//		})
+//		s.GET("/user/info").WithSession("uid", 1).Do().
+//			AssertStatus(200).AssertErrNo(0).AssertResults(map[string]interface{}{"name": "a"})
+//	}
+package hfwtest
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hsyan2008/hfw"
+	"github.com/hsyan2008/hfw/configs"
+	"github.com/hsyan2008/hfw/db"
+	"github.com/hsyan2008/hfw/redis"
+)
+
+//Server 见Setup
+type Server struct {
+	t testing.TB
+	//替换了db.DefaultDao
+	Dao *FakeDao
+	//替换了redis.DefaultIns
+	Redis *FakeRedis
+}
+
+//Setup 使用独立的配置，并用FakeDao和FakeRedis替换db.DefaultDao和redis.DefaultIns
+//opts可以修改配置，测试结束后恢复原来的配置和实例
+//配置是全局的，使用Setup的测试不能并行执行
+func Setup(t testing.TB, opts ...func(c *configs.AllConfig)) *Server {
+	t.Helper()
+
+	oldConfig, oldHfwConfig := configs.Config, hfw.Config
+	oldRedis, oldDao := redis.DefaultIns, db.DefaultDao
+	t.Cleanup(func() {
+		configs.Config, hfw.Config = oldConfig, oldHfwConfig
+		redis.DefaultIns, db.DefaultDao = oldRedis, oldDao
+	})
+
+	configs.Config = configs.NewConfig()
+	configs.Config.Redis.Addresses = []string{"hfwtest:6379"}
+	for _, opt := range opts {
+		opt(&configs.Config)
+	}
+	if err := configs.InitDefaultConfig(); err != nil {
+		t.Fatalf("hfwtest: init config failed: %v", err)
+	}
+	hfw.Config = configs.Config
+
+	s := &Server{
+		t:     t,
+		Dao:   NewFakeDao(),
+		Redis: GetFakeRedis(),
+	}
+	s.Redis.FlushAll()
+	redis.DefaultIns = s.Redis.Client()
+	db.DefaultDao = s.Dao
+	captureOnce.Do(func() {
+		hfw.Use(captureMiddleware)
+	})
+
+	return s
+}
+
+//GET ..
+func (s *Server) GET(path string) *Request {
+	return s.NewRequest(http.MethodGet, path)
+}
+
+//POST ..
+func (s *Server) POST(path string) *Request {
+	return s.NewRequest(http.MethodPost, path)
+}
+
+//PUT ..
+func (s *Server) PUT(path string) *Request {
+	return s.NewRequest(http.MethodPut, path)
+}
+
+//PATCH ..
+func (s *Server) PATCH(path string) *Request {
+	return s.NewRequest(http.MethodPatch, path)
+}
+
+//DELETE ..
+func (s *Server) DELETE(path string) *Request {
+	return s.NewRequest(http.MethodDelete, path)
+}
+
+//NewRequest path可以带url参数
+func (s *Server) NewRequest(method, path string) *Request {
+	return &Request{
+		t:      s.t,
+		method: method,
+		path:   path,
+		header: make(http.Header),
+	}
+}
+
+//请求头里的id，用于找到请求对应的HTTPContext
+const captureHeader = "Hfwtest-Id"
+
+var (
+	captureOnce sync.Once
+	captureID   uint64
+	captured    sync.Map
+)
+
+func captureMiddleware(httpCtx *hfw.HTTPContext, next func()) {
+	if httpCtx.Request != nil {
+		if id := httpCtx.Request.Header.Get(captureHeader); id != "" {
+			captured.Store(id, httpCtx)
+		}
+	}
+
+	next()
+}
+
+func nextCaptureID() string {
+	return strconv.FormatUint(atomic.AddUint64(&captureID, 1), 10)
+}
+
+//取出后删除
+func loadCaptured(id string) *hfw.HTTPContext {
+	v, ok := captured.Load(id)
+	if !ok {
+		return nil
+	}
+	captured.Delete(id)
+
+	return v.(*hfw.HTTPContext)
+}
@@ -0,0 +1,99 @@
+package hfwtest
+
+import (
+	"testing"
+
+	"github.com/hsyan2008/hfw"
+	"github.com/hsyan2008/hfw/configs"
+	"github.com/hsyan2008/hfw/db"
+	"github.com/hsyan2008/hfw/redis"
+)
+
+type testUser struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func (u *testUser) TableName() string       { return "user" }
+func (u *testUser) AutoIncrColName() string { return "id" }
+func (u *testUser) AutoIncrColValue() int64 { return u.ID }
+
+type testUserCtl struct {
+	hfw.Controller
+}
+
+func (ctl *testUserCtl) Info(httpCtx *hfw.HTTPContext) {
+	var uid int64
+	httpCtx.Session.Get(&uid, "uid")
+	u := &testUser{ID: uid}
+	has, err := db.DefaultDao.SearchOne(u, db.Cond{"id": uid})
+	httpCtx.ThrowCheck(500, err)
+	if !has {
+		httpCtx.ThrowCheck(404, "not found")
+	}
+	httpCtx.Results = u
+}
+
+func (ctl *testUserCtl) Save(httpCtx *hfw.HTTPContext) {
+	var u testUser
+	httpCtx.ThrowCheck(400, httpCtx.Bind(&u))
+	_, err := redis.DefaultIns.Set("user_name", u.Name)
+	httpCtx.ThrowCheck(500, err)
+	httpCtx.Results = u
+}
+
+func (ctl *testUserCtl) Upload(httpCtx *hfw.HTTPContext) {
+	f, h, err := httpCtx.Request.FormFile("file")
+	httpCtx.ThrowCheck(400, err)
+	defer f.Close()
+	httpCtx.Results = httpCtx.Request.FormValue("dir") + "/" + h.Filename
+}
+
+var registerOnce bool
+
+func setup(t *testing.T) *Server {
+	s := Setup(t, func(c *configs.AllConfig) {
+		c.Session.IsEnable = true
+	})
+	if !registerOnce {
+		registerOnce = true
+		_ = hfw.Handler("/hfwtest_user", &testUserCtl{})
+	}
+
+	return s
+}
+
+func TestSessionAndDao(t *testing.T) {
+	s := setup(t)
+	s.Dao.On("SearchOne", func(call DaoCall) (interface{}, error) {
+		SetResult(call.Model, &testUser{ID: 7, Name: "a"})
+		return true, nil
+	})
+
+	s.GET("/hfwtest_user/info").WithSession("uid", 7).Do().
+		AssertStatus(200).
+		AssertErrNo(0).
+		AssertResults(testUser{ID: 7, Name: "a"})
+	if calls := s.Dao.Calls("SearchOne"); len(calls) != 1 {
+		t.Fatalf("SearchOne calls = %d, want 1", len(calls))
+	}
+
+	s.Dao.Reset()
+	s.GET("/hfwtest_user/info").WithSession("uid", 8).Do().AssertErrNo(404)
+}
+
+func TestJSONAndForm(t *testing.T) {
+	s := setup(t)
+
+	s.POST("/hfwtest_user/save").WithJSON(testUser{ID: 1, Name: "b"}).Do().
+		AssertErrNo(0).
+		AssertResults(testUser{ID: 1, Name: "b"})
+	var name string
+	if _, err := s.Redis.Client().Get(&name, "user_name"); err != nil || name != "b" {
+		t.Fatalf("redis user_name = %q, %v", name, err)
+	}
+
+	s.POST("/hfwtest_user/upload").WithForm("dir", "tmp").WithFile("file", "a.txt", []byte("hello")).Do().
+		AssertErrNo(0).
+		AssertResults("tmp/a.txt")
+}
@@ -0,0 +1,483 @@
+package hfwtest
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hsyan2008/hfw/configs"
+	"github.com/hsyan2008/hfw/redis"
+	radix "github.com/mediocregopher/radix/v3"
+)
+
+//FakeRedis 内存里的redis，支持常用的string、hash、list和过期命令
+//不支持lua脚本，所以redis存储的限流不生效
+type FakeRedis struct {
+	mu      sync.Mutex
+	strings map[string]string
+	hashes  map[string]map[string]string
+	lists   map[string][]string
+	expires map[string]time.Time
+
+	conn   radix.Conn
+	client *redis.Client
+}
+
+var (
+	fakeRedis     *FakeRedis
+	fakeRedisOnce sync.Once
+)
+
+//GetFakeRedis 返回进程内唯一的FakeRedis，session的store只会初始化一次，所以不能每次新建
+func GetFakeRedis() *FakeRedis {
+	fakeRedisOnce.Do(func() {
+		fakeRedis = &FakeRedis{}
+		fakeRedis.FlushAll()
+		fakeRedis.conn = radix.Stub("tcp", "hfwtest:6379", fakeRedis.do)
+		fakeRedis.client = redis.NewWithClient(fakeRedis, configs.RedisConfig{Addresses: []string{"hfwtest:6379"}})
+	})
+
+	return fakeRedis
+}
+
+//Client 用于替换redis.DefaultIns
+func (r *FakeRedis) Client() *redis.Client {
+	return r.client
+}
+
+//FlushAll 清空数据
+func (r *FakeRedis) FlushAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reset()
+}
+
+func (r *FakeRedis) reset() {
+	r.strings = make(map[string]string)
+	r.hashes = make(map[string]map[string]string)
+	r.lists = make(map[string][]string)
+	r.expires = make(map[string]time.Time)
+}
+
+//Do 实现radix.Client，stub不是并发安全的
+func (r *FakeRedis) Do(a radix.Action) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return a.Run(r.conn)
+}
+
+//Close 实现radix.Client
+func (r *FakeRedis) Close() error {
+	return nil
+}
+
+var (
+	errWrongType  = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	errNotInteger = errors.New("ERR value is not an integer or out of range")
+)
+
+func (r *FakeRedis) exists(key string) bool {
+	if t, ok := r.expires[key]; ok && !time.Now().Before(t) {
+		r.del(key)
+		return false
+	}
+	_, ok1 := r.strings[key]
+	_, ok2 := r.hashes[key]
+	_, ok3 := r.lists[key]
+
+	return ok1 || ok2 || ok3
+}
+
+func (r *FakeRedis) del(key string) {
+	delete(r.strings, key)
+	delete(r.hashes, key)
+	delete(r.lists, key)
+	delete(r.expires, key)
+}
+
+func (r *FakeRedis) incrBy(key string, delta int64) interface{} {
+	var n int64
+	if r.exists(key) {
+		v, ok := r.strings[key]
+		if !ok {
+			return errWrongType
+		}
+		var err error
+		if n, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return errNotInteger
+		}
+	}
+	n += delta
+	r.strings[key] = strconv.FormatInt(n, 10)
+
+	return n
+}
+
+func (r *FakeRedis) ttl(key string, unit time.Duration) int64 {
+	if !r.exists(key) {
+		return -2
+	}
+	t, ok := r.expires[key]
+	if !ok {
+		return -1
+	}
+
+	return int64(time.Until(t) / unit)
+}
+
+func (r *FakeRedis) expire(key string, d time.Duration) int64 {
+	if !r.exists(key) {
+		return 0
+	}
+	r.expires[key] = time.Now().Add(d)
+
+	return 1
+}
+
+func (r *FakeRedis) rename(oldKey, newKey string) {
+	s, isString := r.strings[oldKey]
+	h, isHash := r.hashes[oldKey]
+	l, isList := r.lists[oldKey]
+	t, hasExpire := r.expires[oldKey]
+	r.del(oldKey)
+	r.del(newKey)
+	switch {
+	case isString:
+		r.strings[newKey] = s
+	case isHash:
+		r.hashes[newKey] = h
+	case isList:
+		r.lists[newKey] = l
+	}
+	if hasExpire {
+		r.expires[newKey] = t
+	}
+}
+
+//由Do加锁
+func (r *FakeRedis) do(args []string) interface{} {
+	if len(args) == 0 {
+		return errors.New("ERR empty command")
+	}
+	cmd, args := strings.ToUpper(args[0]), args[1:]
+	argsErr := errors.New("ERR wrong number of arguments for '" + strings.ToLower(cmd) + "' command")
+	switch cmd {
+	case "PING":
+		return "PONG"
+	case "FLUSHDB", "FLUSHALL":
+		r.reset()
+		return "OK"
+	case "GET":
+		if len(args) != 1 {
+			return argsErr
+		}
+		if !r.exists(args[0]) {
+			return nil
+		}
+		if s, ok := r.strings[args[0]]; ok {
+			return s
+		}
+		return errWrongType
+	case "SET":
+		if len(args) < 2 {
+			return argsErr
+		}
+		key, value := args[0], args[1]
+		var nx, xx bool
+		var ex time.Duration
+		for i := 2; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "XX":
+				xx = true
+			case "EX", "PX":
+				if i+1 >= len(args) {
+					return errors.New("ERR syntax error")
+				}
+				n, err := strconv.ParseInt(args[i+1], 10, 64)
+				if err != nil {
+					return errNotInteger
+				}
+				if strings.ToUpper(args[i]) == "EX" {
+					ex = time.Duration(n) * time.Second
+				} else {
+					ex = time.Duration(n) * time.Millisecond
+				}
+				i++
+			default:
+				return errors.New("ERR syntax error")
+			}
+		}
+		exists := r.exists(key)
+		if (nx && exists) || (xx && !exists) {
+			return nil
+		}
+		r.del(key)
+		r.strings[key] = value
+		if ex > 0 {
+			r.expires[key] = time.Now().Add(ex)
+		}
+		return "OK"
+	case "SETNX":
+		if len(args) != 2 {
+			return argsErr
+		}
+		if r.exists(args[0]) {
+			return int64(0)
+		}
+		r.strings[args[0]] = args[1]
+		return int64(1)
+	case "SETEX":
+		if len(args) != 3 {
+			return argsErr
+		}
+		n, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return errNotInteger
+		}
+		r.del(args[0])
+		r.strings[args[0]] = args[2]
+		r.expires[args[0]] = time.Now().Add(time.Duration(n) * time.Second)
+		return "OK"
+	case "MGET":
+		list := make([]interface{}, len(args))
+		for k, key := range args {
+			if s, ok := r.strings[key]; ok && r.exists(key) {
+				list[k] = s
+			}
+		}
+		return list
+	case "MSET":
+		if len(args) == 0 || len(args)%2 != 0 {
+			return argsErr
+		}
+		for i := 0; i < len(args); i += 2 {
+			r.del(args[i])
+			r.strings[args[i]] = args[i+1]
+		}
+		return "OK"
+	case "DEL", "UNLINK":
+		var n int64
+		for _, key := range args {
+			if r.exists(key) {
+				n++
+			}
+			r.del(key)
+		}
+		return n
+	case "EXISTS":
+		var n int64
+		for _, key := range args {
+			if r.exists(key) {
+				n++
+			}
+		}
+		return n
+	case "INCR", "DECR":
+		if len(args) != 1 {
+			return argsErr
+		}
+		if cmd == "INCR" {
+			return r.incrBy(args[0], 1)
+		}
+		return r.incrBy(args[0], -1)
+	case "INCRBY", "DECRBY":
+		if len(args) != 2 {
+			return argsErr
+		}
+		n, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return errNotInteger
+		}
+		if cmd == "DECRBY" {
+			n = -n
+		}
+		return r.incrBy(args[0], n)
+	case "EXPIRE", "PEXPIRE":
+		if len(args) != 2 {
+			return argsErr
+		}
+		n, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return errNotInteger
+		}
+		if cmd == "EXPIRE" {
+			return r.expire(args[0], time.Duration(n)*time.Second)
+		}
+		return r.expire(args[0], time.Duration(n)*time.Millisecond)
+	case "TTL":
+		if len(args) != 1 {
+			return argsErr
+		}
+		return r.ttl(args[0], time.Second)
+	case "PTTL":
+		if len(args) != 1 {
+			return argsErr
+		}
+		return r.ttl(args[0], time.Millisecond)
+	case "RENAME", "RENAMENX":
+		if len(args) != 2 {
+			return argsErr
+		}
+		if !r.exists(args[0]) {
+			return errors.New("ERR no such key")
+		}
+		if cmd == "RENAMENX" {
+			if r.exists(args[1]) {
+				return int64(0)
+			}
+			r.rename(args[0], args[1])
+			return int64(1)
+		}
+		r.rename(args[0], args[1])
+		return "OK"
+	case "HSET", "HMSET":
+		if len(args) < 3 || len(args)%2 != 1 {
+			return argsErr
+		}
+		r.exists(args[0])
+		if _, ok := r.strings[args[0]]; ok {
+			return errWrongType
+		}
+		h, ok := r.hashes[args[0]]
+		if !ok {
+			h = make(map[string]string)
+			r.hashes[args[0]] = h
+		}
+		var n int64
+		for i := 1; i < len(args); i += 2 {
+			if _, ok := h[args[i]]; !ok {
+				n++
+			}
+			h[args[i]] = args[i+1]
+		}
+		if cmd == "HMSET" {
+			return "OK"
+		}
+		return n
+	case "HGET":
+		if len(args) != 2 {
+			return argsErr
+		}
+		r.exists(args[0])
+		if v, ok := r.hashes[args[0]][args[1]]; ok {
+			return v
+		}
+		return nil
+	case "HMGET":
+		if len(args) < 2 {
+			return argsErr
+		}
+		r.exists(args[0])
+		list := make([]interface{}, len(args)-1)
+		for k, field := range args[1:] {
+			if v, ok := r.hashes[args[0]][field]; ok {
+				list[k] = v
+			}
+		}
+		return list
+	case "HGETALL":
+		if len(args) != 1 {
+			return argsErr
+		}
+		r.exists(args[0])
+		list := []interface{}{}
+		for k, v := range r.hashes[args[0]] {
+			list = append(list, k, v)
+		}
+		return list
+	case "HEXISTS":
+		if len(args) != 2 {
+			return argsErr
+		}
+		r.exists(args[0])
+		if _, ok := r.hashes[args[0]][args[1]]; ok {
+			return int64(1)
+		}
+		return int64(0)
+	case "HDEL":
+		if len(args) < 2 {
+			return argsErr
+		}
+		r.exists(args[0])
+		var n int64
+		for _, field := range args[1:] {
+			if _, ok := r.hashes[args[0]][field]; ok {
+				delete(r.hashes[args[0]], field)
+				n++
+			}
+		}
+		if h, ok := r.hashes[args[0]]; ok && len(h) == 0 {
+			r.del(args[0])
+		}
+		return n
+	case "HINCRBY":
+		if len(args) != 3 {
+			return argsErr
+		}
+		delta, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return errNotInteger
+		}
+		r.exists(args[0])
+		h, ok := r.hashes[args[0]]
+		if !ok {
+			h = make(map[string]string)
+			r.hashes[args[0]] = h
+		}
+		n, _ := strconv.ParseInt(h[args[1]], 10, 64)
+		n += delta
+		h[args[1]] = strconv.FormatInt(n, 10)
+		return n
+	case "LPUSH", "RPUSH":
+		if len(args) < 2 {
+			return argsErr
+		}
+		r.exists(args[0])
+		l := r.lists[args[0]]
+		for _, v := range args[1:] {
+			if cmd == "LPUSH" {
+				l = append([]string{v}, l...)
+			} else {
+				l = append(l, v)
+			}
+		}
+		r.lists[args[0]] = l
+		return int64(len(l))
+	case "LPOP", "RPOP":
+		if len(args) != 1 {
+			return argsErr
+		}
+		r.exists(args[0])
+		l := r.lists[args[0]]
+		if len(l) == 0 {
+			return nil
+		}
+		var v string
+		if cmd == "LPOP" {
+			v, l = l[0], l[1:]
+		} else {
+			v, l = l[len(l)-1], l[:len(l)-1]
+		}
+		if len(l) == 0 {
+			r.del(args[0])
+		} else {
+			r.lists[args[0]] = l
+		}
+		return v
+	case "LLEN":
+		if len(args) != 1 {
+			return argsErr
+		}
+		r.exists(args[0])
+		return int64(len(r.lists[args[0]]))
+	case "EVAL", "EVALSHA", "SCRIPT":
+		return errors.New("ERR scripts are not supported by hfwtest")
+	}
+
+	return errors.New("ERR unknown command '" + strings.ToLower(cmd) + "'")
+}
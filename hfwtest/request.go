@@ -0,0 +1,200 @@
+package hfwtest
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hsyan2008/hfw/common"
+	"github.com/hsyan2008/hfw/configs"
+	"github.com/hsyan2008/hfw/encoding"
+	"github.com/hsyan2008/hfw/redis"
+)
+
+//Request 请求构造器，最后调用Do
+type Request struct {
+	t      testing.TB
+	method string
+	path   string
+
+	header  http.Header
+	query   url.Values
+	cookies []*http.Cookie
+	session []sessionValue
+
+	body        io.Reader
+	contentType string
+	form        url.Values
+	files       []formFile
+}
+
+type sessionValue struct {
+	key   string
+	value interface{}
+}
+
+type formFile struct {
+	field    string
+	filename string
+	content  []byte
+}
+
+//WithHeader ..
+func (r *Request) WithHeader(key, value string) *Request {
+	r.header.Set(key, value)
+	return r
+}
+
+//WithQuery 添加url参数
+func (r *Request) WithQuery(key, value string) *Request {
+	if r.query == nil {
+		r.query = make(url.Values)
+	}
+	r.query.Add(key, value)
+	return r
+}
+
+//WithCookie ..
+func (r *Request) WithCookie(name, value string) *Request {
+	r.cookies = append(r.cookies, &http.Cookie{Name: name, Value: value})
+	return r
+}
+
+//WithSession 请求前写入session，需要开启Session.IsEnable
+func (r *Request) WithSession(key string, value interface{}) *Request {
+	r.session = append(r.session, sessionValue{key: key, value: value})
+	return r
+}
+
+//WithJSON body为v的json
+func (r *Request) WithJSON(v interface{}) *Request {
+	r.t.Helper()
+	b, err := encoding.JSON.Marshal(v)
+	if err != nil {
+		r.t.Fatalf("hfwtest: marshal json failed: %v", err)
+	}
+	return r.WithBody("application/json", b)
+}
+
+//WithBody ..
+func (r *Request) WithBody(contentType string, body []byte) *Request {
+	r.contentType = contentType
+	r.body = bytes.NewReader(body)
+	return r
+}
+
+//WithForm 添加表单字段，有文件时用multipart提交
+func (r *Request) WithForm(key, value string) *Request {
+	if r.form == nil {
+		r.form = make(url.Values)
+	}
+	r.form.Add(key, value)
+	return r
+}
+
+//WithFile 添加上传的文件，用multipart提交
+func (r *Request) WithFile(field, filename string, content []byte) *Request {
+	r.files = append(r.files, formFile{field: field, filename: filename, content: content})
+	return r
+}
+
+//Do 执行请求，和http服务一样经过所有中间件
+func (r *Request) Do() *Response {
+	r.t.Helper()
+
+	target := r.path
+	if len(r.query) > 0 {
+		if strings.Contains(target, "?") {
+			target += "&" + r.query.Encode()
+		} else {
+			target += "?" + r.query.Encode()
+		}
+	}
+	body, contentType := r.buildBody()
+	req := httptest.NewRequest(r.method, target, body)
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	if contentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for _, c := range r.cookies {
+		req.AddCookie(c)
+	}
+	r.writeSession(req)
+
+	id := nextCaptureID()
+	req.Header.Set(captureHeader, id)
+	w := httptest.NewRecorder()
+	//和服务一样由DefaultServeMux分发，HandlerFunc注册的路由也可以测试
+	http.DefaultServeMux.ServeHTTP(w, req)
+
+	return &Response{
+		t:        r.t,
+		Recorder: w,
+		Ctx:      loadCaptured(id),
+	}
+}
+
+func (r *Request) buildBody() (io.Reader, string) {
+	if len(r.files) == 0 {
+		if r.body != nil {
+			return r.body, r.contentType
+		}
+		if len(r.form) > 0 {
+			return strings.NewReader(r.form.Encode()), "application/x-www-form-urlencoded"
+		}
+		return nil, ""
+	}
+
+	buf := new(bytes.Buffer)
+	mw := multipart.NewWriter(buf)
+	for k, list := range r.form {
+		for _, v := range list {
+			_ = mw.WriteField(k, v)
+		}
+	}
+	for _, f := range r.files {
+		fw, err := mw.CreateFormFile(f.field, f.filename)
+		if err != nil {
+			r.t.Fatalf("hfwtest: create form file failed: %v", err)
+		}
+		_, _ = fw.Write(f.content)
+	}
+	_ = mw.Close()
+
+	return buf, mw.FormDataContentType()
+}
+
+//和session包的存储方式一样，写入redis后带上session的cookie
+func (r *Request) writeSession(req *http.Request) {
+	r.t.Helper()
+	if len(r.session) == 0 {
+		return
+	}
+	if !configs.Config.Session.IsEnable && !configs.Config.EnableSession {
+		r.t.Fatalf("hfwtest: WithSession need Session.IsEnable")
+	}
+
+	cookieName := configs.Config.Session.CookieName
+	if cookieName == "" {
+		cookieName = "sess_name"
+	}
+	sessID := ""
+	if c, err := req.Cookie(cookieName); err == nil {
+		sessID = c.Value
+	} else {
+		sessID = common.Uuid()
+		req.AddCookie(&http.Cookie{Name: cookieName, Value: sessID})
+	}
+	for _, v := range r.session {
+		if err := redis.DefaultIns.HSet("sess_"+sessID, v.key, v.value); err != nil {
+			r.t.Fatalf("hfwtest: write session failed: %v", err)
+		}
+	}
+}
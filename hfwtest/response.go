@@ -0,0 +1,190 @@
+package hfwtest
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hsyan2008/hfw"
+)
+
+//Response 请求的结果，Assert开头的方法可以链式调用
+type Response struct {
+	t        testing.TB
+	Recorder *httptest.ResponseRecorder
+	//请求对应的HTTPContext，没有经过Router时为nil
+	Ctx *hfw.HTTPContext
+
+	decoded bool
+	body    responseBody
+}
+
+type responseBody struct {
+	ErrNo   int64           `json:"err_no"`
+	ErrMsg  string          `json:"err_msg"`
+	Results json.RawMessage `json:"results"`
+}
+
+//HasHeader为true时的格式
+type responseWithHeader struct {
+	Response *responseBody `json:"response"`
+}
+
+//Body ..
+func (resp *Response) Body() string {
+	return resp.Recorder.Body.String()
+}
+
+func (resp *Response) decode() *responseBody {
+	resp.t.Helper()
+	if resp.decoded {
+		return &resp.body
+	}
+	resp.decoded = true
+
+	b := resp.Recorder.Body.Bytes()
+	var wrap responseWithHeader
+	if err := json.Unmarshal(b, &wrap); err == nil && wrap.Response != nil {
+		resp.body = *wrap.Response
+		return &resp.body
+	}
+	if err := json.Unmarshal(b, &resp.body); err != nil {
+		resp.t.Fatalf("hfwtest: response is not json: %v, body: %s", err, b)
+	}
+
+	return &resp.body
+}
+
+//ErrNo 响应json里的err_no
+func (resp *Response) ErrNo() int64 {
+	resp.t.Helper()
+	return resp.decode().ErrNo
+}
+
+//ErrMsg 响应json里的err_msg
+func (resp *Response) ErrMsg() string {
+	resp.t.Helper()
+	return resp.decode().ErrMsg
+}
+
+//Results 把响应json里的results解析到v
+func (resp *Response) Results(v interface{}) {
+	resp.t.Helper()
+	if err := json.Unmarshal(resp.decode().Results, v); err != nil {
+		resp.t.Fatalf("hfwtest: unmarshal results failed: %v", err)
+	}
+}
+
+//Session 读取请求结束后session里key的值
+func (resp *Response) Session(recv interface{}, key string) {
+	resp.t.Helper()
+	if resp.Ctx == nil || resp.Ctx.Session == nil {
+		resp.t.Fatalf("hfwtest: session is not enable")
+	}
+	resp.Ctx.Session.Get(recv, key)
+}
+
+//AssertStatus ..
+func (resp *Response) AssertStatus(status int) *Response {
+	resp.t.Helper()
+	if resp.Recorder.Code != status {
+		resp.t.Errorf("hfwtest: status = %d, want %d, body: %s", resp.Recorder.Code, status, resp.Body())
+	}
+	return resp
+}
+
+//AssertErrNo ..
+func (resp *Response) AssertErrNo(errNo int64) *Response {
+	resp.t.Helper()
+	if got := resp.ErrNo(); got != errNo {
+		resp.t.Errorf("hfwtest: err_no = %d, want %d, err_msg: %s", got, errNo, resp.ErrMsg())
+	}
+	return resp
+}
+
+//AssertErrMsg ..
+func (resp *Response) AssertErrMsg(errMsg string) *Response {
+	resp.t.Helper()
+	if got := resp.ErrMsg(); got != errMsg {
+		resp.t.Errorf("hfwtest: err_msg = %q, want %q", got, errMsg)
+	}
+	return resp
+}
+
+//AssertResults 比较results和want的json是否一致，不要求类型相同
+func (resp *Response) AssertResults(want interface{}) *Response {
+	resp.t.Helper()
+	var got interface{}
+	resp.Results(&got)
+	b, err := json.Marshal(want)
+	if err != nil {
+		resp.t.Fatalf("hfwtest: marshal want failed: %v", err)
+	}
+	var w interface{}
+	if err = json.Unmarshal(b, &w); err != nil {
+		resp.t.Fatalf("hfwtest: unmarshal want failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, w) {
+		resp.t.Errorf("hfwtest: results = %s, want %s", resp.decode().Results, b)
+	}
+	return resp
+}
+
+//AssertHeader ..
+func (resp *Response) AssertHeader(key, value string) *Response {
+	resp.t.Helper()
+	if got := resp.Recorder.Header().Get(key); got != value {
+		resp.t.Errorf("hfwtest: header %s = %q, want %q", key, got, value)
+	}
+	return resp
+}
+
+//AssertCookie 响应里设置的cookie
+func (resp *Response) AssertCookie(name, value string) *Response {
+	resp.t.Helper()
+	for _, c := range resp.Recorder.Result().Cookies() {
+		if c.Name == name {
+			if c.Value != value {
+				resp.t.Errorf("hfwtest: cookie %s = %q, want %q", name, c.Value, value)
+			}
+			return resp
+		}
+	}
+	resp.t.Errorf("hfwtest: cookie %s not found", name)
+	return resp
+}
+
+//AssertBodyContains ..
+func (resp *Response) AssertBodyContains(s string) *Response {
+	resp.t.Helper()
+	if !strings.Contains(resp.Body(), s) {
+		resp.t.Errorf("hfwtest: body not contains %q, body: %s", s, resp.Body())
+	}
+	return resp
+}
+
+//AssertTemplate 渲染的模板文件
+func (resp *Response) AssertTemplate(templateFile string) *Response {
+	resp.t.Helper()
+	if resp.Ctx == nil {
+		resp.t.Fatalf("hfwtest: request not through router")
+	}
+	if resp.Ctx.TemplateFile != templateFile {
+		resp.t.Errorf("hfwtest: template = %q, want %q", resp.Ctx.TemplateFile, templateFile)
+	}
+	return resp
+}
+
+//AssertData 模板数据Data里key的值
+func (resp *Response) AssertData(key string, want interface{}) *Response {
+	resp.t.Helper()
+	if resp.Ctx == nil {
+		resp.t.Fatalf("hfwtest: request not through router")
+	}
+	if got := resp.Ctx.Data[key]; !reflect.DeepEqual(got, want) {
+		resp.t.Errorf("hfwtest: data %s = %#v, want %#v", key, got, want)
+	}
+	return resp
+}
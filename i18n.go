@@ -0,0 +1,89 @@
+package hfw
+
+import (
+	"html/template"
+	"path/filepath"
+
+	"github.com/hsyan2008/hfw/common"
+	"github.com/hsyan2008/hfw/configs"
+	"github.com/hsyan2008/hfw/i18n"
+	"google.golang.org/grpc/metadata"
+)
+
+func init() {
+	AddTemplateFuncs(template.FuncMap{
+		//如{{t . "hello" "name" .Data.name}}，在range里请用$
+		"t": func(httpCtx *HTTPContext, key string, args ...interface{}) string {
+			return httpCtx.T(key, args...)
+		},
+		//如<html lang="{{locale .}}">
+		"locale": func(httpCtx *HTTPContext) string {
+			return httpCtx.Locale()
+		},
+	})
+}
+
+func initI18n(conf configs.I18nConfig) (err error) {
+	i18n.SetDefaultLocale(conf.DefaultLocale)
+
+	paths := []string{conf.Path}
+	if conf.Path == "" {
+		configPath := filepath.Join(common.GetAppPath(), "config")
+		paths = []string{filepath.Join(configPath, "i18n")}
+		if len(common.GetEnv()) > 0 {
+			paths = append(paths, filepath.Join(configPath, common.GetEnv(), "i18n"))
+		}
+	}
+	for _, path := range paths {
+		if !common.IsExist(path) {
+			continue
+		}
+		if err = i18n.Load(path); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+//SetLocale 指定语言，优先于cookie和Accept-Language
+func (httpCtx *HTTPContext) SetLocale(locale string) {
+	httpCtx.locale = locale
+}
+
+//Locale 当前请求的语言，依次是SetLocale、cookie、Accept-Language，grpc取metadata里的accept-language
+func (httpCtx *HTTPContext) Locale() string {
+	if httpCtx.locale != "" {
+		return httpCtx.locale
+	}
+
+	var acceptLanguage string
+	if httpCtx.Request != nil {
+		if locale, ok := i18n.Match(httpCtx.GetCookie(Config.I18n.CookieName)); ok {
+			httpCtx.locale = locale
+			return locale
+		}
+		acceptLanguage = httpCtx.Request.Header.Get("Accept-Language")
+	} else if httpCtx.Ctx != nil {
+		if md, ok := metadata.FromIncomingContext(httpCtx.Ctx); ok && len(md.Get("accept-language")) > 0 {
+			acceptLanguage = md.Get("accept-language")[0]
+		}
+	}
+	httpCtx.locale = i18n.Negotiate(acceptLanguage)
+
+	return httpCtx.locale
+}
+
+//T 当前语言的文案，args见i18n.T
+func (httpCtx *HTTPContext) T(key string, args ...interface{}) string {
+	return i18n.T(httpCtx.Locale(), key, args...)
+}
+
+//错误码对应的错误信息，先找当前语言的，再找ErrorMap
+func (httpCtx *HTTPContext) errorMsg(errNo int64, args []interface{}) string {
+	if msg := i18n.Error(httpCtx.Locale(), errNo, args...); msg != "" {
+		return msg
+	}
+
+	return common.GetErrorMap(errNo)
+}
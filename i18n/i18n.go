@@ -0,0 +1,224 @@
+//Package i18n 多语言的错误信息和文案
+//每个语言一个toml文件，文件名是语言，如zh-CN.toml、en.toml：
+//
+//	[errors]
+//	1001 = "用户{{.name}}不存在"
+//	[messages]
+//	hello = "你好，{{.name}}"
+//
+//文案是text/template，参数可以是一个map或struct，也可以是成对的key、value
+package i18n
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/BurntSushi/toml"
+	"github.com/hsyan2008/hfw/common"
+)
+
+type catalog struct {
+	Errors   map[string]string
+	Messages map[string]string
+}
+
+var (
+	mt            sync.RWMutex
+	catalogs      = make(map[string]*catalog)
+	locales       []string
+	defaultLocale string
+	templates     sync.Map
+)
+
+//Load 加载dir下的*.toml，文件名是语言，重复加载会合并
+func Load(dir string) (err error) {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.toml"))
+	for _, file := range files {
+		var c catalog
+		if _, err = toml.DecodeFile(file, &c); err != nil {
+			return fmt.Errorf("load i18n file %s: %v", file, err)
+		}
+		locale := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		AddMessages(locale, c.Messages)
+		for k, v := range c.Errors {
+			errNo, err := strconv.ParseInt(k, 10, 64)
+			if err != nil {
+				return fmt.Errorf("load i18n file %s: error errNo %s", file, k)
+			}
+			AddErrors(locale, map[int64]string{errNo: v})
+		}
+	}
+
+	return
+}
+
+func getCatalog(locale string) *catalog {
+	c, ok := catalogs[locale]
+	if !ok {
+		c = &catalog{Errors: make(map[string]string), Messages: make(map[string]string)}
+		catalogs[locale] = c
+		locales = append(locales, locale)
+	}
+
+	return c
+}
+
+//AddErrors 添加错误码对应的文案
+func AddErrors(locale string, m map[int64]string) {
+	mt.Lock()
+	defer mt.Unlock()
+	c := getCatalog(locale)
+	for k, v := range m {
+		c.Errors[strconv.FormatInt(k, 10)] = v
+	}
+}
+
+//AddMessages 添加文案
+func AddMessages(locale string, m map[string]string) {
+	mt.Lock()
+	defer mt.Unlock()
+	c := getCatalog(locale)
+	for k, v := range m {
+		c.Messages[k] = v
+	}
+}
+
+//SetDefaultLocale 协商不到语言时使用，也是找不到文案时的兜底
+func SetDefaultLocale(locale string) {
+	mt.Lock()
+	defer mt.Unlock()
+	defaultLocale = locale
+}
+
+//DefaultLocale ..
+func DefaultLocale() string {
+	mt.RLock()
+	defer mt.RUnlock()
+	return defaultLocale
+}
+
+//Locales 已加载的语言
+func Locales() []string {
+	mt.RLock()
+	defer mt.RUnlock()
+	return append([]string(nil), locales...)
+}
+
+//Negotiate 按Accept-Language从已加载的语言里选，如en-US可以匹配en，都不匹配返回默认语言
+func Negotiate(acceptLanguage string) string {
+	offers := Locales()
+	if locale := common.NegotiateValue(acceptLanguage, offers); locale != "" {
+		return locale
+	}
+	for _, item := range common.ParseAccept(acceptLanguage) {
+		if item.Q <= 0 {
+			continue
+		}
+		for _, offer := range offers {
+			if baseLanguage(offer) == baseLanguage(item.Value) {
+				return offer
+			}
+		}
+	}
+
+	return DefaultLocale()
+}
+
+//Match locale是否已加载，忽略大小写，返回加载时的名称
+func Match(locale string) (string, bool) {
+	for _, v := range Locales() {
+		if strings.EqualFold(v, locale) {
+			return v, true
+		}
+	}
+
+	return "", false
+}
+
+//如zh-CN返回zh
+func baseLanguage(locale string) string {
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		locale = locale[:i]
+	}
+
+	return strings.ToLower(locale)
+}
+
+//Error 错误码对应的文案，依次找locale、locale的基础语言、默认语言，都没有返回空
+func Error(locale string, errNo int64, args ...interface{}) string {
+	return lookup(locale, "errors", strconv.FormatInt(errNo, 10), args)
+}
+
+//T 文案，找不到时返回key
+func T(locale, key string, args ...interface{}) string {
+	if s := lookup(locale, "messages", key, args); s != "" {
+		return s
+	}
+
+	return key
+}
+
+func lookup(locale, kind, key string, args []interface{}) string {
+	mt.RLock()
+	var text string
+	for _, l := range []string{locale, baseLanguage(locale), defaultLocale} {
+		c, ok := catalogs[l]
+		if !ok {
+			continue
+		}
+		m := c.Messages
+		if kind == "errors" {
+			m = c.Errors
+		}
+		if s, ok := m[key]; ok {
+			locale, text = l, s
+			break
+		}
+	}
+	mt.RUnlock()
+
+	if text == "" || !strings.Contains(text, "{{") {
+		return text
+	}
+
+	return execute(locale+"/"+kind+"/"+key, text, args)
+}
+
+//模板按文案缓存，只有解析失败时返回原文，缺少的参数输出为空
+func execute(name, text string, args []interface{}) string {
+	var t *template.Template
+	if v, ok := templates.Load(name + "\x00" + text); ok {
+		t = v.(*template.Template)
+	} else {
+		var err error
+		t, err = template.New(name).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return text
+		}
+		templates.Store(name+"\x00"+text, t)
+	}
+
+	buf := &bytes.Buffer{}
+	_ = t.Execute(buf, argsData(args))
+
+	//map[string]interface{}缺少的key，missingkey=zero时text/template输出<no value>
+	return strings.ReplaceAll(buf.String(), "<no value>", "")
+}
+
+//一个参数直接作为模板的数据，多个按key、value组成map，没有参数时是空的map
+func argsData(args []interface{}) interface{} {
+	if len(args) == 1 && args[0] != nil {
+		return args[0]
+	}
+	data := make(map[string]interface{}, len(args)/2)
+	for i := 0; i+1 < len(args); i += 2 {
+		data[fmt.Sprint(args[i])] = args[i+1]
+	}
+
+	return data
+}
@@ -0,0 +1,41 @@
+package i18n
+
+import "testing"
+
+func TestT(t *testing.T) {
+	AddMessages("zh-CN", map[string]string{
+		"hello": "你好，{{.name}}",
+		"bad":   "{{.name",
+		"plain": "没有参数",
+	})
+	AddErrors("zh-CN", map[int64]string{1001: "用户{{.name}}不存在"})
+
+	cases := []struct {
+		key  string
+		args []interface{}
+		want string
+	}{
+		{"hello", []interface{}{"name", "hfw"}, "你好，hfw"},
+		{"hello", []interface{}{map[string]interface{}{"name": "hfw"}}, "你好，hfw"},
+		//没有参数或者缺少参数时为空，不返回原文
+		{"hello", nil, "你好，"},
+		{"hello", []interface{}{nil}, "你好，"},
+		{"hello", []interface{}{"other", 1}, "你好，"},
+		//解析失败返回原文
+		{"bad", nil, "{{.name"},
+		{"plain", nil, "没有参数"},
+		{"missing", nil, "missing"},
+	}
+	for _, c := range cases {
+		if got := T("zh-CN", c.key, c.args...); got != c.want {
+			t.Fatalf("T(%s, %v) = %q, want %q", c.key, c.args, got, c.want)
+		}
+	}
+
+	if got := Error("zh-CN", 1001); got != "用户不存在" {
+		t.Fatalf("Error(1001) = %q", got)
+	}
+	if got := Error("zh-CN", 1002); got != "" {
+		t.Fatalf("Error(1002) = %q, want empty", got)
+	}
+}
@@ -13,6 +13,9 @@ import (
 	"github.com/hsyan2008/hfw/db"
 	"github.com/hsyan2008/hfw/prometheus"
 	"github.com/hsyan2008/hfw/redis"
+	"github.com/hsyan2008/hfw/reporter"
+	"github.com/hsyan2008/hfw/signal"
+	"github.com/hsyan2008/hfw/tracing"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -50,6 +53,32 @@ func Init() (err error) {
 		return err
 	}
 
+	//链路追踪，需要在redis、db之前，已退出时导出剩余的span
+	if Config.Tracing.IsEnable {
+		err = tracing.Init(Config.Tracing)
+		if err != nil {
+			logger.Warn("init tracing faild:", err)
+			return err
+		}
+		signal.GetSignalContext().AddShutdownHook("tracing", 0, tracing.Shutdown)
+	}
+
+	//错误上报
+	if Config.Reporter.IsEnable {
+		err = reporter.Init(Config.Reporter)
+		if err != nil {
+			logger.Warn("init reporter faild:", err)
+			return err
+		}
+	}
+
+	//多语言的错误信息和文案
+	err = initI18n(Config.I18n)
+	if err != nil {
+		logger.Warn("init i18n faild:", err)
+		return err
+	}
+
 	//初始化redis
 	if len(Config.Redis.Addresses) > 0 {
 		logger.Info("begin to connect default REDIS server:", Config.Redis.Addresses)
@@ -72,6 +101,24 @@ func Init() (err error) {
 		logger.Info("connect to default MYSQL server success")
 	}
 
+	//查看路由表
+	if Config.Route.RoutesPath != "" {
+		HandlerFunc(Config.Route.RoutesPath, routesHandler)
+	}
+
+	//接口文档
+	if Config.Route.OpenAPIPath != "" {
+		HandlerFunc(Config.Route.OpenAPIPath, openAPIHandler)
+		if Config.Route.SwaggerUIPath != "" {
+			registerSwaggerUI(Config.Route.SwaggerUIPath)
+		}
+	}
+
+	//健康检查
+	if Config.Health.IsEnable {
+		initHealth(Config.Health)
+	}
+
 	//初始化prometheus
 	if Config.Prometheus.IsEnable {
 		prometheus.Init(Config.Prometheus)
@@ -121,5 +168,5 @@ func initLog() error {
 	// logger.SetPrefix(filepath.Join(common.GetAppName(), common.GetEnv(), common.GetHostName(), common.GetVersion()))
 	logger.SetPrefix(filepath.Join(common.GetAppName(), common.GetEnv(), common.GetHostName()))
 
-	return nil
+	return initAccessLog(lc.AccessLog)
 }
@@ -0,0 +1,354 @@
+package hfw
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hsyan2008/hfw/common"
+	"github.com/hsyan2008/hfw/configs"
+	"github.com/hsyan2008/hfw/prometheus"
+	"github.com/hsyan2008/hfw/redis"
+	"github.com/hsyan2008/hfw/signal"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+//限流的错误
+var (
+	ErrRateLimited      = errors.New("limit: too many requests")
+	ErrTooManyFlight    = errors.New("limit: too many requests in flight")
+	ErrLimitWaitTimeout = errors.New("limit: wait timeout")
+)
+
+//LimitUserKey KeyBy=user时获取用户标识，默认取session的cookie，没有则用ip
+var LimitUserKey = func(httpCtx *HTTPContext) string {
+	if httpCtx.Request != nil {
+		if v := httpCtx.GetCookie(Config.Session.CookieName); v != "" {
+			return v
+		}
+	}
+
+	return limitClientIP(httpCtx)
+}
+
+//Limiter 限流器，限制同时处理的请求数和请求速率
+type Limiter struct {
+	conf configs.LimitConfig
+	//用于prometheus和redis的key
+	name string
+
+	inFlight chan struct{}
+	waiting  int32
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+//NewLimiter 创建限流器，可以通过Middleware用于指定的路由
+func NewLimiter(conf configs.LimitConfig) *Limiter {
+	if conf.WaitTimeout <= 0 {
+		conf.WaitTimeout = time.Second
+	}
+	if conf.Rate > 0 && conf.Burst <= 0 {
+		conf.Burst = int(math.Ceil(conf.Rate))
+	}
+	l := &Limiter{
+		conf:    conf,
+		name:    conf.Route,
+		buckets: make(map[string]*tokenBucket),
+	}
+	if l.name == "" {
+		l.name = "controller:" + strings.ToLower(conf.Controller)
+	}
+	if conf.MaxInFlight > 0 {
+		l.inFlight = make(chan struct{}, conf.MaxInFlight)
+	}
+	if conf.Rate > 0 && !l.isRedis() {
+		go l.cleanBuckets()
+	}
+
+	return l
+}
+
+//LimitMiddleware 按conf限流的中间件，如Route("GET", "/export", ctl, "Export", LimitMiddleware(conf))
+func LimitMiddleware(conf configs.LimitConfig) Middleware {
+	return NewLimiter(conf).Middleware()
+}
+
+//Middleware 返回中间件
+func (l *Limiter) Middleware() Middleware {
+	return func(httpCtx *HTTPContext, next func()) {
+		if l.conf.Rate > 0 {
+			ok, err := l.takeToken(l.key(httpCtx))
+			if err != nil {
+				//redis出错的时候不限制
+				httpCtx.Warn("limit:", err)
+			} else if !ok {
+				prometheus.LimitRejected(l.name, "rate")
+				httpCtx.Warn(ErrRateLimited, l.name)
+				httpCtx.abort(http.StatusTooManyRequests, ErrRateLimited)
+				return
+			}
+		}
+		if l.conf.MaxInFlight > 0 {
+			release, err := l.acquire(httpCtx)
+			if err != nil {
+				httpCtx.Warn(err, l.name)
+				httpCtx.abort(http.StatusServiceUnavailable, err)
+				return
+			}
+			defer release()
+		}
+
+		next()
+	}
+}
+
+func (l *Limiter) isRedis() bool {
+	return strings.ToLower(l.conf.Store) == "redis"
+}
+
+func (l *Limiter) key(httpCtx *HTTPContext) string {
+	keyBy := l.conf.KeyBy
+	switch {
+	case keyBy == "":
+		return ""
+	case keyBy == "ip":
+		return limitClientIP(httpCtx)
+	case keyBy == "user":
+		return LimitUserKey(httpCtx)
+	case strings.HasPrefix(keyBy, "header:"):
+		name := strings.TrimSpace(keyBy[len("header:"):])
+		var v string
+		if httpCtx.Request != nil {
+			v = httpCtx.Request.Header.Get(name)
+		} else if md, ok := metadata.FromIncomingContext(httpCtx.Ctx); ok {
+			if list := md.Get(name); len(list) > 0 {
+				v = list[0]
+			}
+		}
+		if v != "" {
+			return v
+		}
+		return limitClientIP(httpCtx)
+	}
+
+	return ""
+}
+
+func limitClientIP(httpCtx *HTTPContext) (ip string) {
+	if httpCtx.Request != nil {
+		ip = strings.TrimSpace(strings.Split(common.GetClientIP(httpCtx.Request), ",")[0])
+	} else if p, ok := peer.FromContext(httpCtx.Ctx); ok {
+		ip = p.Addr.String()
+	}
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		return host
+	}
+
+	return ip
+}
+
+func (l *Limiter) takeToken(key string) (bool, error) {
+	if l.isRedis() {
+		return redis.TakeToken("limit:rate:"+l.name+":"+key, l.conf.Rate, l.conf.Burst)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(l.conf.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.conf.Burst), b.tokens+now.Sub(b.last).Seconds()*l.conf.Rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, nil
+	}
+	b.tokens--
+
+	return true, nil
+}
+
+//定时清理已经满了的令牌桶，删除后再请求会新建满的桶，结果一样
+func (l *Limiter) cleanBuckets() {
+	full := time.Duration(float64(l.conf.Burst) / l.conf.Rate * float64(time.Second))
+	interval := full
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-signal.GetSignalContext().Ctx.Done():
+			return
+		case now := <-ticker.C:
+			l.mu.Lock()
+			for k, b := range l.buckets {
+				if now.Sub(b.last) >= full {
+					delete(l.buckets, k)
+				}
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+//占用一个并发数，满了则排队等待，返回的release用于释放
+func (l *Limiter) acquire(httpCtx *HTTPContext) (release func(), err error) {
+	//redis里区分各个请求的占用
+	var holder string
+	if l.isRedis() {
+		holder = common.GetPureUUID()
+	}
+	ok, err := l.tryAcquire(holder)
+	if err != nil {
+		//redis出错的时候不限制
+		httpCtx.Warn("limit:", err)
+		return func() {}, nil
+	}
+	if !ok {
+		if l.conf.MaxWaiting <= 0 {
+			prometheus.LimitRejected(l.name, "in_flight")
+			return nil, ErrTooManyFlight
+		}
+		if atomic.AddInt32(&l.waiting, 1) > int32(l.conf.MaxWaiting) {
+			atomic.AddInt32(&l.waiting, -1)
+			prometheus.LimitRejected(l.name, "in_flight")
+			return nil, ErrTooManyFlight
+		}
+		prometheus.LimitWaiting(l.name, 1)
+		err = l.wait(httpCtx, holder)
+		atomic.AddInt32(&l.waiting, -1)
+		prometheus.LimitWaiting(l.name, -1)
+		if err != nil {
+			prometheus.LimitRejected(l.name, "timeout")
+			return nil, err
+		}
+	}
+
+	prometheus.LimitInFlight(l.name, 1)
+	return func() {
+		prometheus.LimitInFlight(l.name, -1)
+		if l.isRedis() {
+			if err := redis.Release(l.redisInFlightKey(), holder); err != nil {
+				httpCtx.Warn("limit:", err)
+			}
+			return
+		}
+		<-l.inFlight
+	}, nil
+}
+
+func (l *Limiter) redisInFlightKey() string {
+	return "limit:inflight:" + l.name
+}
+
+func (l *Limiter) tryAcquire(holder string) (bool, error) {
+	if l.isRedis() {
+		//过期时间要比请求的处理时间长，进程退出后没有释放的名额过期后回收
+		return redis.Acquire(l.redisInFlightKey(), holder, int64(l.conf.MaxInFlight), 300)
+	}
+	select {
+	case l.inFlight <- struct{}{}:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+func (l *Limiter) wait(httpCtx *HTTPContext, holder string) error {
+	timer := time.NewTimer(l.conf.WaitTimeout)
+	defer timer.Stop()
+
+	if l.isRedis() {
+		//redis只能轮询
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ok, err := l.tryAcquire(holder)
+				if err != nil {
+					//和acquire一样，redis出错的时候不限制
+					httpCtx.Warn("limit:", err)
+					return nil
+				}
+				if ok {
+					return nil
+				}
+			case <-timer.C:
+				return ErrLimitWaitTimeout
+			case <-httpCtx.Ctx.Done():
+				return httpCtx.Ctx.Err()
+			}
+		}
+	}
+
+	select {
+	case l.inFlight <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrLimitWaitTimeout
+	case <-httpCtx.Ctx.Done():
+		return httpCtx.Ctx.Err()
+	}
+}
+
+var configLimiters struct {
+	once sync.Once
+	list []*Limiter
+}
+
+//Limits配置的限流，按Route或者Controller匹配，第一个匹配的生效
+func limitMiddleware(httpCtx *HTTPContext, next func()) {
+	configLimiters.once.Do(func() {
+		for _, v := range Config.Limits {
+			if v.Route == "" && v.Controller == "" {
+				continue
+			}
+			configLimiters.list = append(configLimiters.list, NewLimiter(v))
+		}
+	})
+
+	for _, l := range configLimiters.list {
+		if l.match(httpCtx) {
+			l.Middleware()(httpCtx, next)
+			return
+		}
+	}
+
+	next()
+}
+
+func (l *Limiter) match(httpCtx *HTTPContext) bool {
+	if l.conf.Controller != "" && !strings.EqualFold(l.conf.Controller, httpCtx.Controller) {
+		return false
+	}
+	if l.conf.Route == "" {
+		return true
+	}
+	route := httpCtx.Route
+	if route == "" {
+		route, _ = httpCtx.pathAndMethod()
+	}
+	if strings.HasSuffix(l.conf.Route, "*") {
+		return strings.HasPrefix(route, strings.TrimSuffix(l.conf.Route, "*"))
+	}
+
+	return route == l.conf.Route
+}
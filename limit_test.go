@@ -0,0 +1,56 @@
+package hfw
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hsyan2008/hfw/configs"
+	"github.com/hsyan2008/hfw/redis"
+	radix "github.com/mediocregopher/radix/v3"
+)
+
+func TestLimiterTakeToken(t *testing.T) {
+	l := NewLimiter(configs.LimitConfig{Route: "/test_limit", Rate: 1, Burst: 2})
+	for i, want := range []bool{true, true, false} {
+		if ok, err := l.takeToken("a"); ok != want || err != nil {
+			t.Fatalf("take %d = %v, %v, want %v", i, ok, err, want)
+		}
+	}
+	if ok, _ := l.takeToken("b"); !ok {
+		t.Fatal("key b should have its own bucket")
+	}
+}
+
+//redis出错时，排队中的请求和第一次占用一样不限制
+func TestLimiterRedisFailOpen(t *testing.T) {
+	var evals int
+	conn := radix.Stub("tcp", "test_limit:6379", func(args []string) interface{} {
+		switch strings.ToUpper(args[0]) {
+		case "EVALSHA", "EVAL":
+			evals++
+			if evals == 1 {
+				return 0
+			}
+			return errors.New("ERR redis down")
+		}
+		return 0
+	})
+	old := redis.DefaultIns
+	redis.DefaultIns = redis.NewWithClient(conn, configs.RedisConfig{})
+	defer func() { redis.DefaultIns = old }()
+
+	l := NewLimiter(configs.LimitConfig{Route: "/test_limit_redis", MaxInFlight: 1, MaxWaiting: 1, Store: "redis"})
+	httpCtx := initCtx(httptest.NewRecorder(), httptest.NewRequest("GET", "/test_limit_redis", nil))
+	defer httpCtx.Cancel()
+
+	release, err := l.acquire(httpCtx)
+	if err != nil || release == nil {
+		t.Fatalf("acquire = %v, want fail open", err)
+	}
+	release()
+	if evals < 2 {
+		t.Fatalf("evals = %d, want wait to retry", evals)
+	}
+}
@@ -0,0 +1,288 @@
+package hfw
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hsyan2008/hfw/common"
+	"github.com/hsyan2008/hfw/prometheus"
+)
+
+//Middleware 中间件
+//调用next执行后续的中间件和业务逻辑，不调用则中止请求
+type Middleware func(httpCtx *HTTPContext, next func())
+
+//内置中间件的名称，可以通过SetDefaultMiddlewares调整顺序或者禁用
+const (
+	MiddlewareRecover     = "recover"
+	MiddlewarePrometheus  = "prometheus"
+	MiddlewareAccessLog   = "accesslog"
+	MiddlewareOnline      = "online"
+	MiddlewareConcurrence = "concurrence"
+	MiddlewareLimit       = "limit"
+	MiddlewareTimeout     = "timeout"
+)
+
+var (
+	builtinMiddlewares = map[string]Middleware{
+		MiddlewareRecover:     recoverMiddleware,
+		MiddlewarePrometheus:  prometheusMiddleware,
+		MiddlewareAccessLog:   accessLogMiddleware,
+		MiddlewareOnline:      onlineMiddleware,
+		MiddlewareConcurrence: concurrenceMiddleware,
+		MiddlewareLimit:       limitMiddleware,
+		MiddlewareTimeout:     timeoutMiddleware,
+	}
+	defaultMiddlewareNames = []string{
+		MiddlewareRecover,
+		MiddlewarePrometheus,
+		MiddlewareAccessLog,
+		MiddlewareOnline,
+		MiddlewareConcurrence,
+		MiddlewareLimit,
+		MiddlewareTimeout,
+	}
+
+	//Use注册的全局中间件，在内置中间件之后执行
+	globalMiddlewares []Middleware
+	//UsePrefix注册的中间件
+	prefixMiddlewares []prefixMiddleware
+)
+
+type prefixMiddleware struct {
+	prefix      string
+	middlewares []Middleware
+}
+
+//SetDefaultMiddlewares 按names的顺序启用内置中间件，不在names里的被禁用
+//HandlerFunc的panic只能由recover中间件处理，所以recover不能禁用
+func SetDefaultMiddlewares(names ...string) {
+	var hasRecover bool
+	for _, name := range names {
+		if _, ok := builtinMiddlewares[name]; !ok {
+			panic("undefined middleware: " + name)
+		}
+		if name == MiddlewareRecover {
+			hasRecover = true
+		}
+	}
+	if !hasRecover {
+		panic("middleware " + MiddlewareRecover + " can't be disabled")
+	}
+	defaultMiddlewareNames = names
+}
+
+//DefaultMiddlewares 返回已启用的内置中间件，可用于组装grpc的拦截器
+func DefaultMiddlewares() (mws []Middleware) {
+	for _, name := range defaultMiddlewareNames {
+		mws = append(mws, builtinMiddlewares[name])
+	}
+
+	return
+}
+
+//Use 注册全局中间件，对所有http请求生效
+func Use(m ...Middleware) {
+	globalMiddlewares = append(globalMiddlewares, m...)
+}
+
+//UsePrefix 注册中间件，只对path以prefix开头的http请求生效
+func UsePrefix(prefix string, m ...Middleware) {
+	prefix = "/" + strings.Trim(strings.ToLower(prefix), "/")
+	prefixMiddlewares = append(prefixMiddlewares, prefixMiddleware{
+		prefix:      prefix,
+		middlewares: m,
+	})
+}
+
+//依次是内置、全局、前缀、路由的中间件
+func buildMiddlewares(path string, routeMiddlewares []Middleware) (mws []Middleware) {
+	mws = append(DefaultMiddlewares(), globalMiddlewares...)
+	path = strings.ToLower(path)
+	for _, v := range prefixMiddlewares {
+		if v.prefix == "/" || path == v.prefix || strings.HasPrefix(path, v.prefix+"/") {
+			mws = append(mws, v.middlewares...)
+		}
+	}
+
+	return append(mws, routeMiddlewares...)
+}
+
+func runMiddlewares(httpCtx *HTTPContext, mws []Middleware, h func()) {
+	var i int
+	var next func()
+	next = func() {
+		if i < len(mws) {
+			m := mws[i]
+			i++
+			m(httpCtx, next)
+			return
+		}
+		h()
+	}
+	next()
+}
+
+//中止请求，grpc的拦截器会返回err
+func (httpCtx *HTTPContext) abort(status int, err error) {
+	httpCtx.abortErr = err
+	if status > 0 && httpCtx.ResponseWriter != nil {
+		httpCtx.isWritten = true
+		httpCtx.ResponseWriter.WriteHeader(status)
+	}
+}
+
+//http请求返回path和method，grpc请求返回FullMethod和GRPC/Stream
+func (httpCtx *HTTPContext) pathAndMethod() (string, string) {
+	if httpCtx.Request != nil {
+		return httpCtx.Request.URL.Path, httpCtx.Request.Method
+	}
+
+	return httpCtx.Path, httpCtx.method
+}
+
+func recoverMiddleware(httpCtx *HTTPContext, next func()) {
+	defer func() {
+		if err := recover(); err != nil {
+			//用户触发的
+			if err == ErrStopRun {
+				return
+			}
+			stack := common.GetStack()
+			httpCtx.Fatal(err, string(stack))
+			if httpCtx.Request != nil {
+				reportPanic(httpCtx, "http", err, stack)
+				//和recoverPanic一样返回500，已经开始输出的不再修改
+				if !httpCtx.hijacked && !httpCtx.isStreaming && !httpCtx.isWritten {
+					(&Controller{}).ServerError(httpCtx)
+					httpCtx.RenderResponse()
+				}
+			} else {
+				reportPanic(httpCtx, "grpc", err, stack)
+			}
+			httpCtx.abort(0, errors.New("panic"))
+		}
+		//按状态码和err_no上报
+		reportError(httpCtx)
+	}()
+
+	next()
+}
+
+//handlerResponseWriter 传给HandlerFunc，记录是否已经输出
+type handlerResponseWriter struct {
+	http.ResponseWriter
+	httpCtx *HTTPContext
+}
+
+func (hw *handlerResponseWriter) WriteHeader(status int) {
+	hw.httpCtx.isWritten = true
+	hw.ResponseWriter.WriteHeader(status)
+}
+
+func (hw *handlerResponseWriter) Write(b []byte) (int, error) {
+	hw.httpCtx.isWritten = true
+
+	return hw.ResponseWriter.Write(b)
+}
+
+//ReadFrom 保留http.ServeContent等使用sendfile的优化
+func (hw *handlerResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	hw.httpCtx.isWritten = true
+	if rf, ok := hw.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+
+	return io.Copy(hw.ResponseWriter, r)
+}
+
+func (hw *handlerResponseWriter) Flush() {
+	if f, ok := hw.ResponseWriter.(http.Flusher); ok {
+		hw.httpCtx.isWritten = true
+		f.Flush()
+	}
+}
+
+func (hw *handlerResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := hw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T doesn't support hijacking", hw.ResponseWriter)
+	}
+	hw.httpCtx.isWritten = true
+
+	return hj.Hijack()
+}
+
+func (hw *handlerResponseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := hw.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+
+	return http.ErrNotSupported
+}
+
+func prometheusMiddleware(httpCtx *HTTPContext, next func()) {
+	path, method := httpCtx.pathAndMethod()
+	prometheus.RequestsTotal(path, method)
+	defer func(startTime time.Time) {
+		prometheus.RequestsCosttime(path, method, time.Since(startTime))
+	}(time.Now())
+
+	next()
+}
+
+func accessLogMiddleware(httpCtx *HTTPContext, next func()) {
+	path, method := httpCtx.pathAndMethod()
+	defer func(startTime time.Time) {
+		if accessLog.isEnable {
+			writeAccessLog(httpCtx, startTime)
+			return
+		}
+		httpCtx.Mixf("Path:%s Method:%s CostTime:%s", path, method, time.Since(startTime))
+	}(time.Now())
+
+	next()
+}
+
+var online uint32
+
+func onlineMiddleware(httpCtx *HTTPContext, next func()) {
+	onlineNum := atomic.AddUint32(&online, 1)
+	defer atomic.AddUint32(&online, ^uint32(0))
+	if httpCtx.Request != nil {
+		httpCtx.Mixf("From:%s Path:%s Online:%d", httpCtx.Request.RemoteAddr, httpCtx.Request.URL.String(), onlineNum)
+	} else {
+		httpCtx.Mixf("Online:%d", onlineNum)
+	}
+
+	next()
+}
+
+func concurrenceMiddleware(httpCtx *HTTPContext, next func()) {
+	err := checkConcurrence(atomic.LoadUint32(&online))
+	if err != nil {
+		httpCtx.Warn(err)
+		httpCtx.abort(http.StatusServiceUnavailable, err)
+		return
+	}
+
+	next()
+}
+
+func checkConcurrence(onlineNum uint32) (err error) {
+	if common.IsGoTest() || Config.Server.Concurrence <= 0 {
+		return nil
+	}
+
+	if onlineNum > uint32(Config.Server.Concurrence) {
+		return errors.New("checkConcurrence: too many concurrence")
+	}
+	return nil
+}
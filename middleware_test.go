@@ -0,0 +1,74 @@
+package hfw
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMiddlewareOrder(t *testing.T) {
+	var calls []string
+	mark := func(name string) Middleware {
+		return func(httpCtx *HTTPContext, next func()) {
+			calls = append(calls, name)
+			next()
+			calls = append(calls, name+" after")
+		}
+	}
+	UsePrefix("/test_mw", mark("prefix"))
+	HandlerFunc("/test_mw/order", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	}, mark("route"))
+
+	serveTest("GET", "/test_mw/order", nil)
+	want := "prefix,route,handler,route after,prefix after"
+	if got := strings.Join(calls, ","); got != want {
+		t.Fatalf("calls = %s, want %s", got, want)
+	}
+}
+
+func TestMiddlewarePanic(t *testing.T) {
+	UsePrefix("/test_panic", func(httpCtx *HTTPContext, next func()) {
+		if httpCtx.Request.URL.Query().Get("before") != "" {
+			panic("middleware panic")
+		}
+		next()
+	})
+	HandlerFunc("/test_panic/written", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("handler panic")
+	})
+
+	//中间件panic时和controller一样返回500
+	w := serveTest("GET", "/test_panic/written?before=1", nil)
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), `"err_no":500`) {
+		t.Fatalf("panic in middleware = %d %q", w.Code, w.Body.String())
+	}
+
+	//已经输出的不再修改
+	w = serveTest("GET", "/test_panic/written", nil)
+	if w.Code != http.StatusAccepted || w.Body.Len() != 0 {
+		t.Fatalf("panic after write = %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestSetDefaultMiddlewares(t *testing.T) {
+	old := defaultMiddlewareNames
+	defer func() { defaultMiddlewareNames = old }()
+
+	SetDefaultMiddlewares(MiddlewareAccessLog, MiddlewareRecover)
+	if got := strings.Join(defaultMiddlewareNames, ","); got != "accesslog,recover" {
+		t.Fatalf("names = %s", got)
+	}
+
+	for _, names := range [][]string{{MiddlewareAccessLog}, {MiddlewareRecover, "undefined"}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("SetDefaultMiddlewares(%v) should panic", names)
+				}
+			}()
+			SetDefaultMiddlewares(names...)
+		}()
+	}
+}
@@ -77,6 +77,11 @@ func (m *Mongo) Exec(colName string, colFunc func(collection *mgo.Collection) er
 	return err
 }
 
+//Ping 用于健康检查
+func (m *Mongo) Ping() error {
+	return m.db.Session.Ping()
+}
+
 func (m *Mongo) CollectionNames() (names []string, err error) {
 	return m.db.CollectionNames()
 }
@@ -0,0 +1,570 @@
+package hfw
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hsyan2008/hfw/common"
+	"github.com/hsyan2008/hfw/encoding"
+)
+
+//APIDoc action的接口文档，用于生成OpenAPI
+type APIDoc struct {
+	Summary     string
+	Description string
+	//默认是controller名称
+	Tags []string
+	//请求参数的struct，和Bind一样，path、query、form标签的作为参数，其他的作为json body
+	Request interface{}
+	//响应里results的数据
+	Results interface{}
+}
+
+//APIDocInterface controller实现APIDocs方法，提供各action的接口文档，key是方法名，如ListForGET
+type APIDocInterface interface {
+	APIDocs() map[string]APIDoc
+}
+
+//SwaggerUIAssets Swagger UI的静态文件地址，为空时使用内置的，在SwaggerUIPath下
+var SwaggerUIAssets = ""
+
+//内置的swagger-ui-dist 5.18.2，Apache-2.0，升级时替换swaggerui目录下的文件
+//
+//go:embed swaggerui/*.css swaggerui/*.js swaggerui/*.png swaggerui/*.html
+var swaggerUIFiles embed.FS
+
+type openAPIDoc struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*openAPISchema `json:"schemas"`
+}
+
+type openAPIOperation struct {
+	Tags        []string                   `json:"tags,omitempty"`
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	OperationID string                     `json:"operationId"`
+	Parameters  []*openAPIParameter        `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Content map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	AllOf                []*openAPISchema          `json:"allOf,omitempty"`
+	Enum                 []string                  `json:"enum,omitempty"`
+	Pattern              string                    `json:"pattern,omitempty"`
+	Minimum              *float64                  `json:"minimum,omitempty"`
+	Maximum              *float64                  `json:"maximum,omitempty"`
+	MinLength            *float64                  `json:"minLength,omitempty"`
+	MaxLength            *float64                  `json:"maxLength,omitempty"`
+	MinItems             *float64                  `json:"minItems,omitempty"`
+	MaxItems             *float64                  `json:"maxItems,omitempty"`
+}
+
+//标准的响应，见common.Response
+const openAPIResponseSchema = "Response"
+
+//OpenAPI 按已注册的controller路由生成OpenAPI 3的文档(json)
+//HandlerFunc和静态文件的路由不包含在内
+func OpenAPI() ([]byte, error) {
+	return encoding.JSON.Marshal(buildOpenAPI())
+}
+
+func buildOpenAPI() *openAPIDoc {
+	title := Config.Route.OpenAPITitle
+	if title == "" {
+		title = common.GetAppName()
+	}
+	doc := &openAPIDoc{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title:   title,
+			Version: common.GetVersion(),
+		},
+		Paths: make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{
+			Schemas: map[string]*openAPISchema{
+				openAPIResponseSchema: {
+					Type: "object",
+					Properties: map[string]*openAPISchema{
+						"err_no":  {Type: "integer", Format: "int64"},
+						"err_msg": {Type: "string"},
+						"results": {},
+					},
+					Required: []string{"err_no", "err_msg", "results"},
+				},
+			},
+		},
+	}
+	g := &openAPISchemaGen{schemas: doc.Components.Schemas, names: make(map[reflect.Type]string)}
+	operationIDs := make(map[string]bool)
+
+	for _, v := range apiRoutes() {
+		docs := map[string]APIDoc{}
+		if d, ok := v.instance.reflectVal.Interface().(APIDocInterface); ok {
+			docs = d.APIDocs()
+		}
+		apiDoc := docs[v.instance.methodName]
+		if len(apiDoc.Tags) == 0 {
+			apiDoc.Tags = []string{v.instance.controllerName}
+		}
+
+		path, pathParams := openAPIPath(v.Path)
+		methods := []string{strings.ToLower(v.Method)}
+		if v.Method == "" {
+			methods = []string{"get", "post"}
+		}
+		for _, method := range methods {
+			op := &openAPIOperation{
+				Tags:        apiDoc.Tags,
+				Summary:     apiDoc.Summary,
+				Description: apiDoc.Description,
+				OperationID: openAPIOperationID(operationIDs, v.instance.controllerName+"."+v.instance.methodName, method),
+				Responses: map[string]openAPIResponse{
+					"200": {
+						Description: "OK",
+						Content: map[string]openAPIMediaType{
+							"application/json": {Schema: g.response(apiDoc.Results)},
+						},
+					},
+				},
+			}
+			g.request(op, method, apiDoc.Request, pathParams)
+			//路由上的参数，Request里没有的
+			for _, name := range pathParams {
+				if !hasOpenAPIParam(op, name, "path") {
+					op.Parameters = append(op.Parameters, &openAPIParameter{
+						Name: name, In: "path", Required: true, Schema: &openAPISchema{Type: "string"},
+					})
+				}
+			}
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = make(map[string]*openAPIOperation)
+			}
+			doc.Paths[path][method] = op
+		}
+	}
+
+	return doc
+}
+
+//Routes里controller的路由，已经按path排序，保证operationId稳定
+func apiRoutes() (list []RouteInfo) {
+	for _, v := range Routes() {
+		if v.instance != nil {
+			list = append(list, v)
+		}
+	}
+
+	return
+}
+
+//:id和*path改为{id}、{path}
+func openAPIPath(path string) (string, []string) {
+	var params []string
+	segments := strings.Split(path, "/")
+	for k, seg := range segments {
+		if seg != "" && (seg[0] == ':' || seg[0] == '*') {
+			params = append(params, seg[1:])
+			segments[k] = "{" + seg[1:] + "}"
+		}
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+func openAPIOperationID(ids map[string]bool, id, method string) string {
+	if ids[id] {
+		id += "_" + method
+	}
+	for i := 2; ids[id]; i++ {
+		id = strings.TrimSuffix(id, "_"+strconv.Itoa(i-1)) + "_" + strconv.Itoa(i)
+	}
+	ids[id] = true
+
+	return id
+}
+
+func hasOpenAPIParam(op *openAPIOperation, name, in string) bool {
+	for _, v := range op.Parameters {
+		if v.Name == name && v.In == in {
+			return true
+		}
+	}
+
+	return false
+}
+
+type openAPISchemaGen struct {
+	schemas map[string]*openAPISchema
+	names   map[reflect.Type]string
+}
+
+//标准响应，results是Results的结构
+func (g *openAPISchemaGen) response(results interface{}) *openAPISchema {
+	ref := &openAPISchema{Ref: "#/components/schemas/" + openAPIResponseSchema}
+	if results == nil {
+		return ref
+	}
+
+	return &openAPISchema{
+		AllOf: []*openAPISchema{ref, {
+			Type:       "object",
+			Properties: map[string]*openAPISchema{"results": g.schema(reflect.TypeOf(results))},
+		}},
+	}
+}
+
+//和Bind一样，path、query、form标签的字段作为参数，其他的作为json body
+//GET、HEAD、DELETE请求form标签的字段作为query参数，忽略json body
+func (g *openAPISchemaGen) request(op *openAPIOperation, method string, req interface{}, pathParams []string) {
+	if req == nil {
+		return
+	}
+	rt := reflect.TypeOf(req)
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt.Kind() != reflect.Struct {
+		return
+	}
+
+	hasBody := method != "get" && method != "head" && method != "delete"
+	form := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+	body := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+	var hasFile bool
+	eachOpenAPIField(rt, func(sf reflect.StructField) {
+		required := hasValidateRule(sf.Tag.Get("validate"), "required")
+		addParam := func(name, in string) {
+			op.Parameters = append(op.Parameters, &openAPIParameter{
+				Name:     name,
+				In:       in,
+				Required: required || in == "path",
+				Schema:   g.field(sf),
+			})
+		}
+		if name := sf.Tag.Get("path"); name != "" {
+			//路由上没有的忽略
+			for _, v := range pathParams {
+				if v == name {
+					addParam(name, "path")
+				}
+			}
+		} else if name = sf.Tag.Get("query"); name != "" {
+			addParam(name, "query")
+		} else if name = strings.Split(sf.Tag.Get("form"), ",")[0]; name != "" {
+			if name == "-" {
+				return
+			}
+			if !hasBody {
+				addParam(name, "query")
+				return
+			}
+			if isFileField(sf.Type) {
+				hasFile = true
+			}
+			form.Properties[name] = g.field(sf)
+			if required {
+				form.Required = append(form.Required, name)
+			}
+		} else if hasBody {
+			name, ok := openAPIJSONName(sf)
+			if !ok {
+				return
+			}
+			body.Properties[name] = g.field(sf)
+			if required {
+				body.Required = append(body.Required, name)
+			}
+		}
+	})
+
+	content := make(map[string]openAPIMediaType)
+	if len(form.Properties) > 0 {
+		if hasFile {
+			content["multipart/form-data"] = openAPIMediaType{Schema: form}
+		} else {
+			content["application/x-www-form-urlencoded"] = openAPIMediaType{Schema: form}
+		}
+	}
+	if len(body.Properties) > 0 {
+		content["application/json"] = openAPIMediaType{Schema: body}
+	}
+	if len(content) > 0 {
+		op.RequestBody = &openAPIRequestBody{Content: content}
+	}
+}
+
+//嵌入的struct展开
+func eachOpenAPIField(rt reflect.Type, f func(sf reflect.StructField)) {
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct && sf.Tag.Get("json") == "" {
+			eachOpenAPIField(sf.Type, f)
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		f(sf)
+	}
+}
+
+func openAPIJSONName(sf reflect.StructField) (string, bool) {
+	name := strings.Split(sf.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = sf.Name
+	}
+
+	return name, true
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	fileValueType = reflect.TypeOf(multipart.FileHeader{})
+)
+
+//字段的schema，加上validate里的规则
+func (g *openAPISchemaGen) field(sf reflect.StructField) *openAPISchema {
+	s := g.schema(sf.Type)
+	if s.Ref != "" {
+		return s
+	}
+	tag := sf.Tag.Get("validate")
+	for _, rule := range strings.Split(tag, ",") {
+		if strings.HasPrefix(rule, "regex=") {
+			//正则里可能有逗号
+			s.Pattern = tag[strings.Index(tag, "regex=")+len("regex="):]
+			break
+		}
+		idx := strings.Index(rule, "=")
+		if idx == -1 {
+			continue
+		}
+		key, param := strings.TrimSpace(rule[:idx]), rule[idx+1:]
+		switch key {
+		case "enum":
+			s.Enum = strings.Split(param, "|")
+		case "min", "max":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			switch s.Type {
+			case "string":
+				s.MinLength, s.MaxLength = pickLimit(key, n, s.MinLength, s.MaxLength)
+			case "array":
+				s.MinItems, s.MaxItems = pickLimit(key, n, s.MinItems, s.MaxItems)
+			default:
+				s.Minimum, s.Maximum = pickLimit(key, n, s.Minimum, s.Maximum)
+			}
+		}
+	}
+
+	return s
+}
+
+func pickLimit(key string, n float64, min, max *float64) (*float64, *float64) {
+	if key == "min" {
+		return &n, max
+	}
+
+	return min, &n
+}
+
+func hasValidateRule(tag, rule string) bool {
+	for _, v := range strings.Split(tag, ",") {
+		if strings.TrimSpace(v) == rule {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (g *openAPISchemaGen) schema(rt reflect.Type) *openAPISchema {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	switch rt {
+	case timeType:
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	case fileValueType:
+		return &openAPISchema{Type: "string", Format: "binary"}
+	case reflect.TypeOf(time.Duration(0)):
+		return &openAPISchema{Type: "string"}
+	}
+
+	switch rt.Kind() {
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &openAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &openAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &openAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if rt.Elem().Kind() == reflect.Uint8 {
+			return &openAPISchema{Type: "string", Format: "byte"}
+		}
+		return &openAPISchema{Type: "array", Items: g.schema(rt.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: g.schema(rt.Elem())}
+	case reflect.Struct:
+		return g.structSchema(rt)
+	}
+
+	//interface{}等任意类型
+	return &openAPISchema{}
+}
+
+//有名字的struct放到components里
+func (g *openAPISchemaGen) structSchema(rt reflect.Type) *openAPISchema {
+	name, ok := g.names[rt]
+	if ok {
+		return &openAPISchema{Ref: "#/components/schemas/" + name}
+	}
+
+	s := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+	if rt.Name() != "" {
+		name = rt.Name()
+		if _, ok := g.schemas[name]; ok {
+			//不同包里的同名struct
+			name = strings.ReplaceAll(rt.PkgPath(), "/", ".") + "." + name
+		}
+		g.names[rt] = name
+		g.schemas[name] = s
+	}
+	eachOpenAPIField(rt, func(sf reflect.StructField) {
+		fieldName, ok := openAPIJSONName(sf)
+		if !ok {
+			return
+		}
+		s.Properties[fieldName] = g.field(sf)
+		if hasValidateRule(sf.Tag.Get("validate"), "required") {
+			s.Required = append(s.Required, fieldName)
+		}
+	})
+
+	if name != "" {
+		return &openAPISchema{Ref: "#/components/schemas/" + name}
+	}
+
+	return s
+}
+
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	b, err := OpenAPI()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write(b)
+}
+
+var swaggerUITemplate = template.Must(template.New("swagger").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="{{.Assets}}/swagger-ui.css">
+<link rel="icon" type="image/png" href="{{.Assets}}/favicon-32x32.png" sizes="32x32">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="{{.Assets}}/swagger-ui-bundle.js"></script>
+<script>
+window.ui = SwaggerUIBundle({url: "{{.URL}}", dom_id: "#swagger-ui"});
+</script>
+</body>
+</html>
+`))
+
+//pattern是页面，pattern/下是内置的静态文件
+func registerSwaggerUI(pattern string) {
+	pattern = "/" + strings.Trim(pattern, "/")
+	dist, _ := fs.Sub(swaggerUIFiles, "swaggerui")
+	assets := http.StripPrefix(pattern+"/", staticCacheControl(http.FileServer(http.FS(dist))))
+	page := func(w http.ResponseWriter, r *http.Request) {
+		swaggerUIHandler(w, r, pattern)
+	}
+
+	HandlerFunc(pattern, page)
+	HandlerFunc(pattern+"/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == pattern+"/" {
+			page(w, r)
+			return
+		}
+		assets.ServeHTTP(w, r)
+	})
+}
+
+func swaggerUIHandler(w http.ResponseWriter, r *http.Request, pattern string) {
+	assetsURL := SwaggerUIAssets
+	if assetsURL == "" {
+		assetsURL = pattern
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := swaggerUITemplate.Execute(w, map[string]string{
+		"Title":  fmt.Sprintf("%s API", common.GetAppName()),
+		"Assets": strings.TrimRight(assetsURL, "/"),
+		"URL":    Config.Route.OpenAPIPath,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
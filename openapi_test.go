@@ -0,0 +1,133 @@
+package hfw
+
+import (
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSwaggerUIAssets(t *testing.T) {
+	registerSwaggerUI("/test_swagger")
+
+	for _, path := range []string{"/test_swagger", "/test_swagger/"} {
+		w := serveTest("GET", path, nil)
+		if w.Code != 200 || !strings.Contains(w.Body.String(), `href="/test_swagger/swagger-ui.css"`) {
+			t.Fatalf("%s = %d %q", path, w.Code, w.Body.String())
+		}
+	}
+	w := serveTest("GET", "/test_swagger/swagger-ui-bundle.js", nil)
+	if w.Code != 200 || w.Body.Len() == 0 {
+		t.Fatalf("bundle = %d, len %d", w.Code, w.Body.Len())
+	}
+	if w = serveTest("GET", "/test_swagger/not_exist.js", nil); w.Code != 404 {
+		t.Fatalf("not exist = %d", w.Code)
+	}
+}
+
+type testAPICtl struct {
+	Controller
+}
+
+type testAPIReq struct {
+	ID     int64                 `path:"id"`
+	Page   int                   `query:"page" validate:"min=1,max=100"`
+	Name   string                `json:"name" validate:"required,min=2,max=10"`
+	Status string                `json:"status" validate:"enum=on|off"`
+	Code   string                `json:"code" validate:"regex=^[a-z]{1,3}$"`
+	Tags   []string              `json:"tags" validate:"max=3"`
+	File   *multipart.FileHeader `form:"file"`
+}
+
+type testAPIUser struct {
+	ID      int64     `json:"id"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+}
+
+func (ctl *testAPICtl) InfoForPOST(httpCtx *HTTPContext) {}
+func (ctl *testAPICtl) UserInfo(httpCtx *HTTPContext)    {}
+
+func (ctl *testAPICtl) APIDocs() map[string]APIDoc {
+	return map[string]APIDoc{
+		"InfoForPOST": {Summary: "info", Request: testAPIReq{}, Results: []testAPIUser{}},
+	}
+}
+
+func TestOpenAPIDoc(t *testing.T) {
+	ctl := &testAPICtl{}
+	_ = Handler("/test_api", ctl)
+	Route(http.MethodPost, "/test_api/:id/info", ctl, "InfoForPOST")
+	HandlerFunc("/test_api_func", func(http.ResponseWriter, *http.Request) {})
+
+	doc := buildOpenAPI()
+	if _, ok := doc.Paths["/test_api_func"]; ok {
+		t.Fatal("HandlerFunc routes should not be in the document")
+	}
+
+	op := doc.Paths["/test_api/{id}/info"]["post"]
+	if op == nil || op.Summary != "info" || len(op.Tags) != 1 || op.Tags[0] != "testAPICtl" {
+		t.Fatalf("operation = %+v", op)
+	}
+	params := map[string]*openAPIParameter{}
+	for _, p := range op.Parameters {
+		params[p.In+":"+p.Name] = p
+	}
+	if p := params["path:id"]; p == nil || !p.Required || p.Schema.Type != "integer" || p.Schema.Format != "int64" {
+		t.Fatalf("path param = %+v", p)
+	}
+	if p := params["query:page"]; p == nil || p.Required || *p.Schema.Minimum != 1 || *p.Schema.Maximum != 100 {
+		t.Fatalf("query param = %+v", p)
+	}
+
+	if op.RequestBody == nil {
+		t.Fatal("request body is nil")
+	}
+	body := op.RequestBody.Content["application/json"].Schema
+	if body == nil || len(body.Required) != 1 || body.Required[0] != "name" {
+		t.Fatalf("json body = %+v", body)
+	}
+	if s := body.Properties["name"]; *s.MinLength != 2 || *s.MaxLength != 10 || s.Minimum != nil {
+		t.Fatalf("name = %+v", s)
+	}
+	if s := body.Properties["status"]; strings.Join(s.Enum, ",") != "on,off" {
+		t.Fatalf("status = %+v", s)
+	}
+	if s := body.Properties["code"]; s.Pattern != "^[a-z]{1,3}$" {
+		t.Fatalf("code = %+v", s)
+	}
+	if s := body.Properties["tags"]; s.Type != "array" || *s.MaxItems != 3 {
+		t.Fatalf("tags = %+v", s)
+	}
+	form := op.RequestBody.Content["multipart/form-data"].Schema
+	if form == nil || form.Properties["file"].Format != "binary" {
+		t.Fatalf("form = %+v", form)
+	}
+
+	//results是有名字的struct的数组，放到components里
+	resp := op.Responses["200"].Content["application/json"].Schema
+	if len(resp.AllOf) != 2 || resp.AllOf[0].Ref != "#/components/schemas/Response" {
+		t.Fatalf("response = %+v", resp)
+	}
+	results := resp.AllOf[1].Properties["results"]
+	if results.Type != "array" || results.Items.Ref != "#/components/schemas/testAPIUser" {
+		t.Fatalf("results = %+v", results)
+	}
+	user := doc.Components.Schemas["testAPIUser"]
+	if user == nil || user.Properties["created"].Format != "date-time" {
+		t.Fatalf("testAPIUser = %+v", user)
+	}
+
+	//同一个方法注册在多个路由上，operationId加上method和序号
+	ids := []string{
+		doc.Paths["/test_api/user_info"]["get"].OperationID,
+		doc.Paths["/test_api/user_info"]["post"].OperationID,
+		doc.Paths["/test_api/userinfo"]["get"].OperationID,
+		doc.Paths["/test_api/userinfo"]["post"].OperationID,
+	}
+	want := "testAPICtl.UserInfo,testAPICtl.UserInfo_post,testAPICtl.UserInfo_get,testAPICtl.UserInfo_post_2"
+	if strings.Join(ids, ",") != want {
+		t.Fatalf("operationIds = %v, want %s", ids, want)
+	}
+}
@@ -1,22 +1,29 @@
 package hfw
 
 import (
-	"compress/gzip"
+	"errors"
+	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"sync"
+	"text/template/parse"
+	"time"
 
 	"github.com/hsyan2008/hfw/common"
 	"github.com/hsyan2008/hfw/configs"
-	"github.com/hsyan2008/hfw/encoding"
 )
 
 //RenderResponse ..
 func (httpCtx *HTTPContext) RenderResponse() {
 	// httpCtx.Debug("RenderResponse")
-	if httpCtx.hijacked {
+	if httpCtx.hijacked || httpCtx.isStreaming {
 		return
 	}
 	httpCtx.ResponseWriter.Header().Set("Trace-Id", httpCtx.GetTraceID())
@@ -37,6 +44,9 @@ func (httpCtx *HTTPContext) RenderResponse() {
 	if httpCtx.IsJSON {
 		httpCtx.ReturnJSON()
 		return
+	} else if r, ok := GetRenderer(httpCtx.Format); ok {
+		httpCtx.returnRenderer(r)
+		return
 	} else if httpCtx.TemplateFile != "" || httpCtx.Template != "" {
 		httpCtx.Render()
 		return
@@ -46,59 +56,87 @@ func (httpCtx *HTTPContext) RenderResponse() {
 }
 
 //ReturnFileContent 下载文件服务
+//file可以是文件路径、io.ReadSeeker或者io.Reader
+//文件路径和io.ReadSeeker支持If-None-Match、If-Modified-Since(304)和Range(206)，不压缩
+//filename为空则不设置Content-Disposition，如在浏览器里直接播放视频
 func (httpCtx *HTTPContext) ReturnFileContent(contentType, filename string, file interface{}) {
 	httpCtx.IsJSON = false
 	httpCtx.Template = ""
 	httpCtx.TemplateFile = ""
-	var w io.Writer
-	var r io.Reader
-	var err error
-	if !httpCtx.IsError && httpCtx.IsZip {
-		httpCtx.ResponseWriter.Header().Del("Content-Length")
-		httpCtx.ResponseWriter.Header().Set("Content-Encoding", "gzip")
-		w = gzip.NewWriter(httpCtx.ResponseWriter)
-		defer w.(io.WriteCloser).Close()
-	} else {
-		w = httpCtx.ResponseWriter
-	}
 
 	switch t := file.(type) {
-	case string: //文件路径，http.ServeFile不自动压缩
-		f, err := filepath.Abs(file.(string))
-		httpCtx.ThrowCheck(500, err)
-		if !common.IsExist(f) {
+	case string: //文件路径
+		f, err := os.Open(t)
+		if os.IsNotExist(err) {
 			httpCtx.ThrowCheck(500, "file not exist")
 		}
-		r, err = os.Open(t)
-		defer r.(io.Closer).Close()
 		httpCtx.ThrowCheck(500, err)
-	case io.Reader: //io流，如果是文件内容，可以通过bytes.Buffer包装下
-		r = file.(io.Reader)
-		if f, ok := file.(io.Closer); ok {
-			defer f.Close()
-		}
+		defer f.Close()
+		file = f
+	case io.Closer:
+		defer t.Close()
 	}
 
 	httpCtx.ResponseWriter.Header().Set("Content-Type", contentType)
-	httpCtx.SetDownloadMode(filename)
+	if filename != "" {
+		httpCtx.SetDownloadMode(filename)
+	} else {
+		httpCtx.IsCloseRender = true
+	}
 
-	httpCtx.ResponseWriter.WriteHeader(httpCtx.HTTPStatus)
+	if rs, ok := file.(io.ReadSeeker); ok && httpCtx.HTTPStatus == http.StatusOK {
+		httpCtx.serveContent(rs)
+		return
+	}
+
+	//io流，如果是文件内容，可以通过bytes.Reader包装下
+	r, ok := file.(io.Reader)
+	if !ok {
+		httpCtx.ThrowCheck(500, "file must be path or io.Reader")
+	}
+	w, closer := httpCtx.compressWriter()
+	defer closer()
+	w.WriteHeader(httpCtx.HTTPStatus)
 
-	_, err = io.Copy(w, r)
+	_, err := io.Copy(w, r)
 	// httpCtx.ThrowCheck(500, err)
 	if err != nil {
 		httpCtx.Warn(err)
 	}
 }
 
+//有Stat方法的(如*os.File)，按修改时间和大小生成ETag和Last-Modified
+//其他的可以提前设置ETag响应头
+func (httpCtx *HTTPContext) serveContent(rs io.ReadSeeker) {
+	var modtime time.Time
+	if s, ok := rs.(interface{ Stat() (os.FileInfo, error) }); ok {
+		if fi, err := s.Stat(); err == nil {
+			modtime = fi.ModTime()
+			header := httpCtx.ResponseWriter.Header()
+			if header.Get("ETag") == "" {
+				//If-Range需要强ETag
+				header.Set("ETag", fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()))
+			}
+		}
+	}
+	http.ServeContent(httpCtx.ResponseWriter, httpCtx.Request, "", modtime, rs)
+}
+
 var templatesCache = struct {
 	list map[string]*template.Template
+	//缓存用到的模板文件
+	deps map[string][]string
 	l    *sync.RWMutex
 }{
 	list: make(map[string]*template.Template),
+	deps: make(map[string][]string),
 	l:    &sync.RWMutex{},
 }
 
+//LayoutContentBlock 布局里放页面内容的block名称
+//页面没有定义该block时，页面本身的内容作为该block
+const LayoutContentBlock = "content"
+
 //Render ..
 func (httpCtx *HTTPContext) Render() {
 	var (
@@ -111,15 +149,9 @@ func (httpCtx *HTTPContext) Render() {
 		httpCtx.ResponseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
 	}
 
-	var w io.Writer = httpCtx.ResponseWriter
-	if !httpCtx.IsError && httpCtx.IsZip {
-		httpCtx.ResponseWriter.Header().Del("Content-Length")
-		httpCtx.ResponseWriter.Header().Set("Content-Encoding", "gzip")
-		writer := gzip.NewWriter(httpCtx.ResponseWriter)
-		defer writer.Close()
-		w = writer
-	}
-	httpCtx.ResponseWriter.WriteHeader(httpCtx.HTTPStatus)
+	w, closer := httpCtx.compressWriter()
+	defer closer()
+	w.WriteHeader(httpCtx.HTTPStatus)
 	err = t.Execute(w, httpCtx)
 	// httpCtx.ThrowCheck(500, err)
 	if err != nil {
@@ -127,113 +159,264 @@ func (httpCtx *HTTPContext) Render() {
 	}
 }
 
+//RenderToString 渲染模板文件为字符串，不使用布局，用于ajax返回的局部页面、html邮件等
+//模板里的.就是data
+func (httpCtx *HTTPContext) RenderToString(templateFile string, data interface{}) (s string, err error) {
+	t, err := getTemplate("partial:"+templateFile, templateDeps(templateFile), func() (*template.Template, error) {
+		return httpCtx.parseFile(templateFile, "")
+	})
+	if err != nil {
+		return
+	}
+
+	var b strings.Builder
+	err = t.Execute(&b, data)
+
+	return b.String(), err
+}
+
 func (httpCtx *HTTPContext) render() (t *template.Template) {
 	var key string
-	var render func() *template.Template
-	var ok bool
+	var err error
 	if httpCtx.Template != "" {
 		key = httpCtx.Path
-		render = httpCtx.renderHTML
+		if httpCtx.Layout != "" {
+			key = httpCtx.Layout + ":" + key
+		}
+		t, err = getTemplate(key, templateDeps(httpCtx.Layout), httpCtx.renderHTML)
 	} else if httpCtx.TemplateFile != "" {
 		key = httpCtx.TemplateFile
-		render = httpCtx.renderFile
-	}
-
-	if Config.Template.IsCache {
-		templatesCache.l.RLock()
-		if t, ok = templatesCache.list[key]; !ok {
-			templatesCache.l.RUnlock()
-			// t = httpCtx.render()
-			t = render()
-			templatesCache.l.Lock()
-			templatesCache.list[key] = t
-			templatesCache.l.Unlock()
-		} else {
-			templatesCache.l.RUnlock()
+		if httpCtx.Layout != "" {
+			key = httpCtx.Layout + ":" + key
 		}
-	} else {
-		// t = httpCtx.render()
-		t = render()
+		t, err = getTemplate(key, templateDeps(httpCtx.Layout, httpCtx.TemplateFile), httpCtx.renderFile)
 	}
+	httpCtx.ThrowCheck(500, err)
 
 	return t
 }
 
-func (httpCtx *HTTPContext) renderHTML() (t *template.Template) {
-	if len(httpCtx.FuncMap) == 0 {
-		t = template.Must(template.New(httpCtx.Path).Parse(httpCtx.Template))
-	} else {
-		t = template.Must(template.New(httpCtx.Path).Funcs(httpCtx.FuncMap).Parse(httpCtx.Template))
+//开启缓存时，同一个key只解析一次
+//deps是用到的模板文件，文件变化时删除缓存，见watchTemplates
+func getTemplate(key string, deps []string, parse func() (*template.Template, error)) (t *template.Template, err error) {
+	if !Config.Template.IsCache {
+		return parse()
 	}
-	if len(Config.Template.WidgetsPath) > 0 {
-		t = template.Must(t.ParseGlob(Config.Template.WidgetsPath))
+
+	var ok bool
+	templatesCache.l.RLock()
+	t, ok = templatesCache.list[key]
+	templatesCache.l.RUnlock()
+	if ok {
+		return
+	}
+
+	t, err = parse()
+	if err != nil {
+		return
 	}
+	templatesCache.l.Lock()
+	templatesCache.list[key] = t
+	templatesCache.deps[key] = deps
+	templatesCache.l.Unlock()
 
 	return
 }
-func (httpCtx *HTTPContext) renderFile() (t *template.Template) {
-	var templateFilePath string
-	if common.IsExist(httpCtx.TemplateFile) {
-		templateFilePath = httpCtx.TemplateFile
-	} else {
-		templateFilePath = filepath.Join(Config.Template.HTMLPath, httpCtx.TemplateFile)
-	}
-	if !common.IsExist(templateFilePath) {
-		httpCtx.ThrowCheck(500, "template path not exist")
-	}
-	if len(httpCtx.FuncMap) == 0 {
-		t = template.Must(template.ParseFiles(templateFilePath))
-	} else {
-		t = template.Must(template.New(filepath.Base(httpCtx.TemplateFile)).Funcs(httpCtx.FuncMap).ParseFiles(templateFilePath))
-	}
-	if len(Config.Template.WidgetsPath) > 0 {
-		t = template.Must(t.ParseGlob(Config.Template.WidgetsPath))
+
+func templateDeps(files ...string) (deps []string) {
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		if path, err := templatePath(file); err == nil {
+			deps = append(deps, path)
+		}
 	}
 
 	return
 }
 
-//ReturnJSON ..
-func (httpCtx *HTTPContext) ReturnJSON() {
-	httpCtx.ResponseWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
-	if len(httpCtx.Data) > 0 && httpCtx.Results == nil {
-		httpCtx.Results = httpCtx.Data
+func (httpCtx *HTTPContext) renderHTML() (t *template.Template, err error) {
+	if httpCtx.Layout != "" {
+		return httpCtx.parseWithLayout(httpCtx.Path, httpCtx.Template)
 	}
 
-	var w io.Writer
-	if !httpCtx.IsError && httpCtx.IsZip {
-		httpCtx.ResponseWriter.Header().Del("Content-Length")
-		httpCtx.ResponseWriter.Header().Set("Content-Encoding", "gzip")
-		w = gzip.NewWriter(httpCtx.ResponseWriter)
-		defer w.(io.WriteCloser).Close()
-	} else {
-		w = httpCtx.ResponseWriter
+	t = httpCtx.newTemplate(httpCtx.Path)
+	t, err = t.Parse(httpCtx.Template)
+	if err != nil {
+		return
 	}
 
-	var err error
-	var results interface{}
-	if httpCtx.IsOnlyResults {
-		//results
-		results = httpCtx.Results
-	} else if httpCtx.HasHeader {
-		//header + response(err_no + err_msg + results)
-		results = httpCtx
-	} else {
-		//response(err_no + err_msg + results)
-		results = httpCtx.Response
+	return parseWidgets(t)
+}
+
+func (httpCtx *HTTPContext) renderFile() (t *template.Template, err error) {
+	return httpCtx.parseFile(httpCtx.TemplateFile, httpCtx.Layout)
+}
+
+func (httpCtx *HTTPContext) parseFile(templateFile, layout string) (t *template.Template, err error) {
+	templateFilePath, err := templatePath(templateFile)
+	if err != nil {
+		return
 	}
-	httpCtx.Debugf("Response: %s", func() string {
+	if layout != "" {
 		var b []byte
-		b, err = encoding.JSON.Marshal(results)
+		b, err = readTemplate(templateFilePath)
 		if err != nil {
-			return err.Error()
+			return
 		}
-		return string(b)
-	}())
-	httpCtx.ResponseWriter.WriteHeader(httpCtx.HTTPStatus)
-	err = encoding.JSONIO.Marshal(w, results)
-	// httpCtx.ThrowCheck(500, err)
+		return httpCtx.parseWithLayout(templateFilePath, string(b))
+	}
+
+	t = httpCtx.newTemplate(filepath.Base(templateFile))
+	t, err = parseTemplateFile(t, templateFilePath)
 	if err != nil {
-		httpCtx.Warn(err)
+		return
 	}
+
+	return parseWidgets(t)
+}
+
+//依次解析布局、widgets、页面，页面里define的block覆盖布局里的同名block
+//执行时从布局开始
+func (httpCtx *HTTPContext) parseWithLayout(name, text string) (t *template.Template, err error) {
+	layoutPath, err := templatePath(httpCtx.Layout)
+	if err != nil {
+		return
+	}
+	t = httpCtx.newTemplate(filepath.Base(layoutPath))
+	t, err = parseTemplateFile(t, layoutPath)
+	if err != nil {
+		return
+	}
+	t, err = parseWidgets(t)
+	if err != nil {
+		return
+	}
+
+	var content *parse.Tree
+	if c := t.Lookup(LayoutContentBlock); c != nil {
+		content = c.Tree
+	}
+	page, err := t.New(name).Parse(text)
+	if err != nil {
+		return
+	}
+	//页面没有定义content，页面本身作为content
+	if c := t.Lookup(LayoutContentBlock); (c == nil || c.Tree == content) &&
+		page.Tree != nil && !parse.IsEmptyTree(page.Tree.Root) {
+		_, err = t.AddParseTree(LayoutContentBlock, page.Tree)
+	}
+
+	return
+}
+
+var templateFuncs = struct {
+	sync.RWMutex
+	m template.FuncMap
+}{
+	m: make(template.FuncMap),
+}
+
+//AddTemplateFuncs 添加所有模板都可以用的函数，需要在解析模板前添加
+//模板会被缓存，和请求相关的函数请把httpCtx作为参数，如{{csrfField .}}
+func AddTemplateFuncs(funcs template.FuncMap) {
+	templateFuncs.Lock()
+	defer templateFuncs.Unlock()
+	for k, v := range funcs {
+		templateFuncs.m[k] = v
+	}
+}
+
+//依次使用全局的和httpCtx.FuncMap里的函数
+func (httpCtx *HTTPContext) newTemplate(name string) *template.Template {
+	t := template.New(name)
+	templateFuncs.RLock()
+	if len(templateFuncs.m) > 0 {
+		t = t.Funcs(templateFuncs.m)
+	}
+	templateFuncs.RUnlock()
+	if len(httpCtx.FuncMap) > 0 {
+		t = t.Funcs(httpCtx.FuncMap)
+	}
+
+	return t
+}
+
+var templateFS = struct {
+	fsys    fs.FS
+	widgets string
+}{}
+
+//SetTemplateFS 从fsys加载模板，如embed.FS，用于单文件部署
+//之后模板文件和布局的路径都是相对fsys根目录的路径，HTMLPath和WidgetsPath不再生效
+//widgets是fsys里widgets的匹配模式，如widgets/*.html，为空表示没有
+func SetTemplateFS(fsys fs.FS, widgets string) {
+	templateFS.fsys = fsys
+	templateFS.widgets = widgets
+	clearTemplatesCache()
+}
+
+func clearTemplatesCache() {
+	templatesCache.l.Lock()
+	templatesCache.list = make(map[string]*template.Template)
+	templatesCache.deps = make(map[string][]string)
+	templatesCache.l.Unlock()
+}
+
+//相对路径的模板在HTMLPath下查找
+func templatePath(file string) (string, error) {
+	if templateFS.fsys != nil {
+		file = strings.TrimPrefix(path.Clean("/"+file), "/")
+		if _, err := fs.Stat(templateFS.fsys, file); err != nil {
+			return "", errors.New("template path not exist: " + file)
+		}
+		return file, nil
+	}
+
+	if common.IsExist(file) {
+		return file, nil
+	}
+	file = filepath.Join(Config.Template.HTMLPath, file)
+	if !common.IsExist(file) {
+		return "", errors.New("template path not exist: " + file)
+	}
+
+	return file, nil
+}
+
+func readTemplate(file string) ([]byte, error) {
+	if templateFS.fsys != nil {
+		return fs.ReadFile(templateFS.fsys, file)
+	}
+
+	return ioutil.ReadFile(file)
+}
+
+func parseTemplateFile(t *template.Template, file string) (*template.Template, error) {
+	if templateFS.fsys != nil {
+		return t.ParseFS(templateFS.fsys, file)
+	}
+
+	return t.ParseFiles(file)
+}
+
+func parseWidgets(t *template.Template) (*template.Template, error) {
+	if templateFS.fsys != nil {
+		if templateFS.widgets == "" {
+			return t, nil
+		}
+		return t.ParseFS(templateFS.fsys, templateFS.widgets)
+	}
+	if len(Config.Template.WidgetsPath) > 0 {
+		return t.ParseGlob(Config.Template.WidgetsPath)
+	}
+
+	return t, nil
+}
+
+//ReturnJSON ..
+func (httpCtx *HTTPContext) ReturnJSON() {
+	r, _ := GetRenderer(FormatJSON)
+	httpCtx.returnRenderer(r)
 }
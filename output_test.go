@@ -0,0 +1,117 @@
+package hfw
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testFileContent = "0123456789abcdef"
+
+type testFileCtl struct {
+	Controller
+	path string
+}
+
+func (ctl *testFileCtl) Path(httpCtx *HTTPContext) {
+	httpCtx.ReturnFileContent("text/plain", "", ctl.path)
+}
+
+func (ctl *testFileCtl) Download(httpCtx *HTTPContext) {
+	httpCtx.ReturnFileContent("text/plain", "a.txt", ctl.path)
+}
+
+//没有Stat方法的，提前设置ETag
+func (ctl *testFileCtl) Seeker(httpCtx *HTTPContext) {
+	httpCtx.ResponseWriter.Header().Set("ETag", `"seeker"`)
+	httpCtx.ReturnFileContent("text/plain", "", strings.NewReader(testFileContent))
+}
+
+//io流不支持Range
+func (ctl *testFileCtl) Reader(httpCtx *HTTPContext) {
+	httpCtx.ReturnFileContent("text/plain", "", bytes.NewBufferString(testFileContent))
+}
+
+func TestReturnFileContent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hfw-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "a.txt")
+	if err = ioutil.WriteFile(path, []byte(testFileContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_ = Handler("/test_file", &testFileCtl{path: path})
+
+	w := serveTest("GET", "/test_file/path", nil)
+	etag, lastModified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	if w.Code != http.StatusOK || w.Body.String() != testFileContent || etag == "" || lastModified == "" ||
+		w.Header().Get("Accept-Ranges") != "bytes" || w.Header().Get("Content-Disposition") != "" {
+		t.Fatalf("file = %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+
+	cases := []struct {
+		path   string
+		header http.Header
+		code   int
+		body   string
+	}{
+		{"/test_file/path", http.Header{"If-None-Match": {etag}}, http.StatusNotModified, ""},
+		{"/test_file/path", http.Header{"If-None-Match": {`"other"`}}, http.StatusOK, testFileContent},
+		{"/test_file/path", http.Header{"If-Modified-Since": {lastModified}}, http.StatusNotModified, ""},
+		{"/test_file/path", http.Header{"Range": {"bytes=2-5"}}, http.StatusPartialContent, "2345"},
+		{"/test_file/path", http.Header{"Range": {"bytes=-3"}}, http.StatusPartialContent, "def"},
+		{"/test_file/path", http.Header{"Range": {"bytes=100-"}}, http.StatusRequestedRangeNotSatisfiable, ""},
+		//If-Range不匹配的返回全部
+		{"/test_file/path", http.Header{"Range": {"bytes=2-5"}, "If-Range": {`"other"`}}, http.StatusOK, testFileContent},
+		{"/test_file/path", http.Header{"Range": {"bytes=2-5"}, "If-Range": {etag}}, http.StatusPartialContent, "2345"},
+		{"/test_file/seeker", http.Header{"If-None-Match": {`"seeker"`}}, http.StatusNotModified, ""},
+		{"/test_file/seeker", http.Header{"Range": {"bytes=0-1"}}, http.StatusPartialContent, "01"},
+		{"/test_file/reader", http.Header{"Range": {"bytes=0-1"}}, http.StatusOK, testFileContent},
+	}
+	for _, c := range cases {
+		w := serveTest("GET", c.path, c.header)
+		if w.Code != c.code || (c.code != http.StatusRequestedRangeNotSatisfiable && w.Body.String() != c.body) {
+			t.Fatalf("%s %v = %d %q, want %d %q", c.path, c.header, w.Code, w.Body.String(), c.code, c.body)
+		}
+		if c.code == http.StatusPartialContent && !strings.HasPrefix(w.Header().Get("Content-Range"), "bytes ") {
+			t.Fatalf("%s %v Content-Range = %q", c.path, c.header, w.Header().Get("Content-Range"))
+		}
+	}
+
+	w = serveTest("GET", "/test_file/download", http.Header{"Range": {"bytes=0-1"}})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "01" ||
+		w.Header().Get("Content-Disposition") != `attachment;filename="a.txt"` {
+		t.Fatalf("download = %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+}
+
+func TestStaticCacheControl(t *testing.T) {
+	old := Config.Template
+	Config.Template.StaticCacheControl = "public, max-age=60"
+	Config.Template.StaticCacheControlExts = map[string]string{".html": "no-cache"}
+	defer func() { Config.Template = old }()
+	dir, err := ioutil.TempDir("", "hfw-static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a.css", "a.html"} {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	StaticStripHandler("/test_static_cache", dir)
+
+	for name, want := range map[string]string{"a.css": "public, max-age=60", "a.html": "no-cache"} {
+		w := serveTest("GET", "/test_static_cache/"+name, nil)
+		if w.Body.String() != name || w.Header().Get("Cache-Control") != want {
+			t.Fatalf("%s = %q %v", name, w.Body.String(), w.Header())
+		}
+	}
+}
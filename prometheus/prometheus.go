@@ -14,6 +14,9 @@ var (
 	conf             configs.PrometheusConfig
 	requestsTotal    *prometheus.CounterVec
 	requestsCosttime *prometheus.SummaryVec
+	limitInFlight    *prometheus.GaugeVec
+	limitWaiting     *prometheus.GaugeVec
+	limitRejected    *prometheus.CounterVec
 	float64Duration  = float64(time.Millisecond)
 )
 
@@ -38,6 +41,27 @@ func Init(c configs.PrometheusConfig) {
 		},
 		[]string{"app", "host", "path", "method"},
 	)
+	limitInFlight = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "limit_in_flight",
+			Help: "limit in flight",
+		},
+		[]string{"app", "host", "limit"},
+	)
+	limitWaiting = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "limit_waiting",
+			Help: "limit waiting",
+		},
+		[]string{"app", "host", "limit"},
+	)
+	limitRejected = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "limit_rejected_total",
+			Help: "limit rejected total",
+		},
+		[]string{"app", "host", "limit", "reason"},
+	)
 }
 
 func RequestsTotal(path, method string) {
@@ -59,3 +83,34 @@ func RequestsCosttime(path, method string, duration time.Duration) {
 		path,
 		method).Observe(float64(duration) / float64Duration)
 }
+
+//LimitInFlight 限流器正在处理的请求数
+func LimitInFlight(limit string, delta float64) {
+	if conf.IsEnable == false {
+		return
+	}
+	limitInFlight.WithLabelValues(common.GetAppName(),
+		common.GetHostName(),
+		limit).Add(delta)
+}
+
+//LimitWaiting 限流器排队中的请求数
+func LimitWaiting(limit string, delta float64) {
+	if conf.IsEnable == false {
+		return
+	}
+	limitWaiting.WithLabelValues(common.GetAppName(),
+		common.GetHostName(),
+		limit).Add(delta)
+}
+
+//LimitRejected 被限流器拒绝的请求数，reason是rate、in_flight、timeout
+func LimitRejected(limit, reason string) {
+	if conf.IsEnable == false {
+		return
+	}
+	limitRejected.WithLabelValues(common.GetAppName(),
+		common.GetHostName(),
+		limit,
+		reason).Inc()
+}
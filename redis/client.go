@@ -1,17 +1,25 @@
 package redis
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/hsyan2008/hfw/configs"
+	"github.com/hsyan2008/hfw/tracing"
 	radix "github.com/mediocregopher/radix/v3"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Client struct {
 	client radix.Client
 	prefix string
 	config configs.RedisConfig
+	//用于链路追踪，见WithContext
+	ctx context.Context
 
 	//如果以下属性是nil，则原生写入，只支持简单的数据类型
 	Marshal func(interface{}) ([]byte, error)
@@ -28,13 +36,57 @@ func (c *Client) Do(a radix.Action) error {
 		return errors.New("redis instance not init")
 	}
 
+	if c.ctx != nil && tracing.IsEnable() {
+		_, span, ok := tracing.StartChild(c.ctx, "redis "+actionName(a),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemRedis))
+		if ok {
+			err := c.client.Do(a)
+			tracing.End(span, err)
+			return err
+		}
+	}
+
 	return c.client.Do(a)
 }
 
+//WithContext 返回使用ctx的副本，ctx里有span时，每个命令会创建子span
+//如redis.DefaultIns.WithContext(httpCtx).Get(&v, key)
+func (c *Client) WithContext(ctx context.Context) *Client {
+	if c == nil {
+		return c
+	}
+	c2 := *c
+	c2.ctx = ctx
+
+	return &c2
+}
+
+//Cmd的String()是["GET" "key"]，只取命令名，不记录参数
+func actionName(a radix.Action) string {
+	if s, ok := a.(fmt.Stringer); ok {
+		str := strings.TrimPrefix(s.String(), "[")
+		if i := strings.IndexByte(str, ' '); i > 0 {
+			str = str[:i]
+		}
+		if name, err := strconv.Unquote(strings.TrimSuffix(str, "]")); err == nil {
+			return strings.ToUpper(name)
+		}
+	}
+
+	return "action"
+}
+
 func (c *Client) Close() error {
 	return closeClient(c)
 }
 
+//Ping 用于健康检查
+func (c *Client) Ping() error {
+	var s string
+	return c.Do(radix.Cmd(&s, "PING"))
+}
+
 func (c *Client) AddPrefix(s string) string {
 	return c.prefix + s
 }
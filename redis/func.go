@@ -199,3 +199,18 @@ func RPop(recv interface{}, key string) (b bool, err error) {
 func LLen(key string) (num int64, err error) {
 	return DefaultIns.LLen(key)
 }
+
+//TakeToken 从令牌桶取一个令牌，rate是每秒生成的令牌数，burst是桶的容量
+func TakeToken(key string, rate float64, burst int) (ok bool, err error) {
+	return DefaultIns.TakeToken(key, rate, burst)
+}
+
+//Acquire holder占用一个名额，已占用的达到max则返回false
+func Acquire(key, holder string, max int64, expiration int64) (ok bool, err error) {
+	return DefaultIns.Acquire(key, holder, max, expiration)
+}
+
+//Release 释放holder占用的名额，和Acquire配对使用
+func Release(key, holder string) (err error) {
+	return DefaultIns.Release(key, holder)
+}
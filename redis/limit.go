@@ -0,0 +1,74 @@
+package redis
+
+import (
+	"strconv"
+	"time"
+
+	radix "github.com/mediocregopher/radix/v3"
+)
+
+//令牌桶，令牌按时间补充，ARGV依次是每秒的令牌数、容量、当前毫秒数
+var takeTokenScript = radix.NewEvalScript(1, `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local data = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return allowed
+`)
+
+//ZSET的成员是占用者，分数是它的过期毫秒数，先清理过期的再判断上限
+//ARGV依次是上限、占用者、当前毫秒数、过期毫秒数
+var acquireScript = radix.NewEvalScript(1, `
+local now = tonumber(ARGV[3])
+local expiration = tonumber(ARGV[4])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+if redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call("ZADD", KEYS[1], now + expiration, ARGV[2])
+redis.call("PEXPIRE", KEYS[1], expiration)
+return 1
+`)
+
+//TakeToken 从令牌桶取一个令牌，rate是每秒生成的令牌数，burst是桶的容量
+func (c *Client) TakeToken(key string, rate float64, burst int) (ok bool, err error) {
+	var n int64
+	err = c.Do(takeTokenScript.Cmd(&n, c.AddPrefix(key),
+		strconv.FormatFloat(rate, 'f', -1, 64),
+		strconv.Itoa(burst),
+		strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)))
+
+	return n == 1, err
+}
+
+//Acquire holder占用一个名额，已占用的达到max则返回false，用于限制多个实例的并发数
+//holder需要唯一，expiration秒后自动失效，进程退出后没有Release的名额会被回收
+func (c *Client) Acquire(key, holder string, max int64, expiration int64) (ok bool, err error) {
+	var n int64
+	err = c.Do(acquireScript.Cmd(&n, c.AddPrefix(key),
+		strconv.FormatInt(max, 10),
+		holder,
+		strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10),
+		strconv.FormatInt(expiration*1000, 10)))
+
+	return n == 1, err
+}
+
+//Release 释放holder占用的名额，和Acquire配对使用
+func (c *Client) Release(key, holder string) (err error) {
+	return c.Do(radix.Cmd(nil, "ZREM", c.AddPrefix(key), holder))
+}
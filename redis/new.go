@@ -73,6 +73,18 @@ func newClient(redisConfig configs.RedisConfig) (c *Client, err error) {
 	return
 }
 
+//NewWithClient 使用已有的radix.Client，如测试时用radix.Stub
+func NewWithClient(client radix.Client, redisConfig configs.RedisConfig) *Client {
+	return &Client{
+		client: client,
+		config: redisConfig,
+		prefix: redisConfig.Prefix,
+
+		Marshal:   encoding.JSON.Marshal,
+		Unmarshal: encoding.JSON.Unmarshal,
+	}
+}
+
 //不能Close，会影响之前的连接
 func Clone(src ...*Client) (dst *Client, err error) {
 	c := DefaultIns
@@ -0,0 +1,227 @@
+package hfw
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hsyan2008/hfw/common"
+	"github.com/hsyan2008/hfw/encoding"
+)
+
+//Renderer 按格式输出响应
+type Renderer struct {
+	//格式名，用于url参数，如format=xml
+	Format string
+	//响应的Content-Type，去掉参数后用于Accept协商
+	ContentType string
+	//data是按IsOnlyResults、HasHeader处理后的数据
+	Render func(w io.Writer, httpCtx *HTTPContext, data interface{}) error
+}
+
+const (
+	FormatJSON     = "json"
+	FormatXML      = "xml"
+	FormatMsgPack  = "msgpack"
+	FormatProtobuf = "protobuf"
+	FormatCSV      = "csv"
+)
+
+var renderers = struct {
+	sync.RWMutex
+	list []*Renderer
+}{}
+
+func init() {
+	RegisterRenderer(&Renderer{
+		Format:      FormatJSON,
+		ContentType: "application/json; charset=utf-8",
+		Render: func(w io.Writer, httpCtx *HTTPContext, data interface{}) error {
+			return encoding.JSONIO.Marshal(w, data)
+		},
+	})
+	RegisterRenderer(&Renderer{
+		Format:      FormatXML,
+		ContentType: "application/xml; charset=utf-8",
+		Render: func(w io.Writer, httpCtx *HTTPContext, data interface{}) error {
+			return encoding.XMLIO.Marshal(w, data)
+		},
+	})
+	RegisterRenderer(&Renderer{
+		Format:      FormatMsgPack,
+		ContentType: "application/msgpack",
+		Render: func(w io.Writer, httpCtx *HTTPContext, data interface{}) error {
+			return encoding.MsgPackIO.Marshal(w, data)
+		},
+	})
+	RegisterRenderer(&Renderer{
+		Format:      FormatProtobuf,
+		ContentType: "application/x-protobuf",
+		Render:      renderProtobuf,
+	})
+	RegisterRenderer(&Renderer{
+		Format:      FormatCSV,
+		ContentType: "text/csv; charset=utf-8",
+		Render:      renderCSV,
+	})
+}
+
+//RegisterRenderer 注册输出格式，Format相同的会覆盖
+func RegisterRenderer(r *Renderer) {
+	if r == nil || r.Format == "" || r.ContentType == "" || r.Render == nil {
+		panic("RegisterRenderer: invalid renderer")
+	}
+	renderers.Lock()
+	defer renderers.Unlock()
+	for k, v := range renderers.list {
+		if v.Format == r.Format {
+			renderers.list[k] = r
+			return
+		}
+	}
+	renderers.list = append(renderers.list, r)
+}
+
+//GetRenderer 按格式名获取
+func GetRenderer(format string) (*Renderer, bool) {
+	renderers.RLock()
+	defer renderers.RUnlock()
+	for _, v := range renderers.list {
+		if v.Format == format {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+//按url参数format或者Accept选择输出格式
+//Accept优先html(即模板或者默认的json)时返回空
+func negotiateFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		if _, ok := GetRenderer(format); ok {
+			return format
+		}
+	}
+
+	renderers.RLock()
+	offers := make([]string, 0, len(renderers.list)+1)
+	offers = append(offers, "text/html")
+	formats := make(map[string]string, len(renderers.list))
+	for _, v := range renderers.list {
+		contentType := strings.TrimSpace(strings.Split(v.ContentType, ";")[0])
+		offers = append(offers, contentType)
+		formats[contentType] = v.Format
+	}
+	renderers.RUnlock()
+	//兼容旧的写法
+	offers = append(offers, "application/x-msgpack", "text/xml")
+	formats["application/x-msgpack"] = FormatMsgPack
+	formats["text/xml"] = FormatXML
+
+	return formats[common.NegotiateContentType(r.Header.Get("Accept"), offers)]
+}
+
+//需要渲染的数据
+func (httpCtx *HTTPContext) responseData() interface{} {
+	if len(httpCtx.Data) > 0 && httpCtx.Results == nil {
+		httpCtx.Results = httpCtx.Data
+	}
+
+	if httpCtx.IsOnlyResults {
+		//results
+		return httpCtx.Results
+	} else if httpCtx.HasHeader {
+		//header + response(err_no + err_msg + results)
+		return responseWithHeader{
+			Response: httpCtx.Response,
+			Header:   httpCtx.Header,
+		}
+	}
+
+	//response(err_no + err_msg + results)
+	return httpCtx.Response
+}
+
+type responseWithHeader struct {
+	common.Response `json:"response"`
+	Header          interface{} `json:"header"`
+}
+
+//ReturnFormat 按注册的格式输出，格式不存在则输出json
+func (httpCtx *HTTPContext) ReturnFormat(format string) {
+	r, ok := GetRenderer(format)
+	if !ok {
+		httpCtx.ReturnJSON()
+		return
+	}
+	httpCtx.returnRenderer(r)
+}
+
+func (httpCtx *HTTPContext) returnRenderer(r *Renderer) {
+	httpCtx.ResponseWriter.Header().Set("Content-Type", r.ContentType)
+	data := httpCtx.responseData()
+	httpCtx.Debugf("Response: %s", func() string {
+		b, err := encoding.JSON.Marshal(data)
+		if err != nil {
+			return err.Error()
+		}
+		return string(b)
+	}())
+
+	//先渲染到buf，Render里可以修改响应头
+	buf := new(bytes.Buffer)
+	err := r.Render(buf, httpCtx, data)
+	// httpCtx.ThrowCheck(500, err)
+	if err != nil {
+		httpCtx.Warn(err)
+	}
+
+	w, closer := httpCtx.compressWriter()
+	defer closer()
+	w.WriteHeader(httpCtx.HTTPStatus)
+	_, err = buf.WriteTo(w)
+	if err != nil {
+		httpCtx.Warn(err)
+	}
+}
+
+//protobuf和csv只输出Results，错误码放在响应头
+func setErrHeader(httpCtx *HTTPContext) {
+	header := httpCtx.ResponseWriter.Header()
+	header.Set("Err-No", strconv.FormatInt(httpCtx.ErrNo, 10))
+	if httpCtx.ErrMsg != "" {
+		header.Set("Err-Msg", httpCtx.ErrMsg)
+	}
+}
+
+//Results不是proto.Message的，改为输出json
+func renderProtobuf(w io.Writer, httpCtx *HTTPContext, data interface{}) error {
+	m, ok := httpCtx.Results.(proto.Message)
+	if !ok {
+		httpCtx.ResponseWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
+		return encoding.JSONIO.Marshal(w, data)
+	}
+	httpCtx.ResponseWriter.Header().Set("Content-Type", "application/x-protobuf")
+	setErrHeader(httpCtx)
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+
+	return err
+}
+
+func renderCSV(w io.Writer, httpCtx *HTTPContext, data interface{}) error {
+	setErrHeader(httpCtx)
+	if !strings.Contains(httpCtx.ResponseWriter.Header().Get("Content-Disposition"), "filename") {
+		httpCtx.ResponseWriter.Header().Set("Content-Disposition", `attachment;filename="results.csv"`)
+	}
+
+	return encoding.CSVMarshal(w, httpCtx.Results)
+}
@@ -0,0 +1,119 @@
+package hfw
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/hsyan2008/hfw/common"
+	"github.com/hsyan2008/hfw/reporter"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+//上报panic，同一个请求不再按状态码上报
+func reportPanic(httpCtx *HTTPContext, source string, err interface{}, stack []byte) {
+	if !reporter.IsEnable() {
+		return
+	}
+	httpCtx.isReported = true
+	reporter.Report(&reporter.Event{
+		Source:  source,
+		Kind:    reporter.KindPanic,
+		Message: fmt.Sprint(err),
+		Stack:   string(stack),
+		TraceID: httpCtx.GetTraceID(),
+		Request: requestSnapshot(httpCtx),
+	})
+}
+
+//请求结束后，按状态码和err_no上报
+func reportError(httpCtx *HTTPContext) {
+	if !reporter.IsEnable() || httpCtx.isReported {
+		return
+	}
+
+	statusCode, errNo := accessStatus(httpCtx)
+	source, httpStatus, message := "http", statusCode, httpCtx.ErrMsg
+	if httpCtx.Request == nil {
+		err := httpCtx.access.err
+		if err == nil {
+			err = httpCtx.abortErr
+		}
+		source, httpStatus = "grpc", grpcHTTPStatus(codes.Code(statusCode))
+		if err != nil {
+			message = err.Error()
+		}
+	}
+	if !reporter.IsReportStatus(httpStatus) && !reporter.IsReportErrNo(errNo) {
+		return
+	}
+	if message == "" {
+		message = http.StatusText(httpStatus)
+	}
+
+	httpCtx.isReported = true
+	reporter.Report(&reporter.Event{
+		Source:  source,
+		Kind:    reporter.KindError,
+		Message: message,
+		Status:  statusCode,
+		ErrNo:   errNo,
+		TraceID: httpCtx.GetTraceID(),
+		Request: requestSnapshot(httpCtx),
+	})
+}
+
+//上报cron返回的err，只按err_no判断
+func reportCronError(httpCtx *HTTPContext, err error) {
+	if !reporter.IsEnable() {
+		return
+	}
+	e, ok := err.(*common.RespErr)
+	if !ok || !reporter.IsReportErrNo(e.ErrNo()) {
+		return
+	}
+	reporter.Report(&reporter.Event{
+		Source:  "cron",
+		Kind:    reporter.KindError,
+		Message: e.ErrMsg(),
+		ErrNo:   e.ErrNo(),
+		TraceID: httpCtx.GetTraceID(),
+	})
+}
+
+//grpc的状态码对应的http状态码，用于判断Statuses
+func grpcHTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Unknown, codes.Internal, codes.DataLoss:
+		return http.StatusInternalServerError
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+
+	return http.StatusBadRequest
+}
+
+func requestSnapshot(httpCtx *HTTPContext) *reporter.Request {
+	path, method := httpCtx.pathAndMethod()
+	if path == "" {
+		return nil
+	}
+	req := &reporter.Request{
+		Method: method,
+		Path:   path,
+		Route:  httpCtx.Route,
+	}
+	if httpCtx.Request != nil {
+		req.Header = reporter.RedactHeader(httpCtx.Request.Header)
+	} else if md, ok := metadata.FromIncomingContext(httpCtx.Ctx); ok {
+		req.Header = reporter.RedactHeader(md)
+	}
+
+	return req
+}
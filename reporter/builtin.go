@@ -0,0 +1,78 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hsyan2008/hfw/curl"
+)
+
+//fileReporter 每行一条json
+type fileReporter struct {
+	mt sync.Mutex
+	f  *os.File
+}
+
+//NewFileReporter 追加写入path
+func NewFileReporter(path string) (Reporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileReporter{f: f}, nil
+}
+
+func (r *fileReporter) Report(e *Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	r.mt.Lock()
+	defer r.mt.Unlock()
+	_, err = r.f.Write(append(b, '\n'))
+
+	return err
+}
+
+func (r *fileReporter) close() {
+	r.mt.Lock()
+	defer r.mt.Unlock()
+	_ = r.f.Close()
+}
+
+//webhookReporter POST json，非2xx的响应算失败
+type webhookReporter struct {
+	url     string
+	timeout time.Duration
+}
+
+//NewWebhookReporter ..
+func NewWebhookReporter(url string, timeout time.Duration) Reporter {
+	return &webhookReporter{url: url, timeout: timeout}
+}
+
+func (r *webhookReporter) Report(e *Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	c := curl.NewPost(context.Background(), r.url)
+	c.Headers.Set("Content-Type", "application/json")
+	c.PostBytes = b
+	c.SetTimeoutMS(int(r.timeout / time.Millisecond))
+	rs, err := c.Request()
+	if err != nil {
+		return err
+	}
+	defer rs.Close()
+	if rs.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook %s StatusCode:%d", r.url, rs.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,319 @@
+//Package reporter 错误上报，panic和配置的状态码、err_no会调用注册的Reporter
+//相同指纹的错误在Window内只上报第一次，Window结束时再上报累计的次数
+package reporter
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	logger "github.com/hsyan2008/go-logger"
+	"github.com/hsyan2008/hfw/common"
+	"github.com/hsyan2008/hfw/configs"
+)
+
+//事件的类型
+const (
+	KindPanic = "panic"
+	KindError = "error"
+)
+
+//Event 上报的错误
+type Event struct {
+	Time time.Time `json:"time"`
+	//http、grpc、grpc_client、cron
+	Source string `json:"source"`
+	//panic或者error
+	Kind string `json:"kind"`
+	//panic的值或者错误信息
+	Message string `json:"message"`
+	Stack   string `json:"stack,omitempty"`
+	//http的状态码，grpc是grpc的状态码
+	Status  int      `json:"status,omitempty"`
+	ErrNo   int64    `json:"err_no,omitempty"`
+	TraceID string   `json:"trace_id"`
+	Request *Request `json:"request,omitempty"`
+	//相同指纹的错误会聚合
+	Fingerprint string `json:"fingerprint"`
+	//Window内出现的次数，第一次上报时是1
+	Count int `json:"count"`
+}
+
+//Request 请求的快照，header已脱敏
+type Request struct {
+	Method string              `json:"method"`
+	Path   string              `json:"path"`
+	Route  string              `json:"route,omitempty"`
+	Header map[string][]string `json:"header,omitempty"`
+}
+
+//Reporter 在单独的goroutine里调用，不影响请求
+type Reporter interface {
+	Report(e *Event) error
+}
+
+//ReporterFunc ..
+type ReporterFunc func(e *Event) error
+
+//Report ..
+func (f ReporterFunc) Report(e *Event) error {
+	return f(e)
+}
+
+var (
+	isEnable bool
+	config   configs.ReporterConfig
+	statuses []func(int) bool
+	redacts  = map[string]bool{}
+
+	mt        sync.RWMutex
+	reporters []Reporter
+	//Init按配置创建的，重新Init时替换
+	builtins []Reporter
+
+	agg = &aggregator{events: make(map[string]*aggEvent)}
+)
+
+//Init 按配置初始化，并注册File和Webhook，重新加载配置时可以再次调用
+//Register注册的Reporter保留
+func Init(conf configs.ReporterConfig) (err error) {
+	if !conf.IsEnable {
+		isEnable = false
+		return
+	}
+	if len(conf.Statuses) == 0 {
+		conf.Statuses = []string{"5xx"}
+	}
+	if conf.Window <= 0 {
+		conf.Window = time.Minute
+	}
+	if len(conf.RedactHeaders) == 0 {
+		conf.RedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Csrf-Token"}
+	}
+	if conf.WebhookTimeout <= 0 {
+		conf.WebhookTimeout = 5 * time.Second
+	}
+
+	statuses = nil
+	for _, v := range conf.Statuses {
+		f, err := parseStatus(v)
+		if err != nil {
+			return err
+		}
+		statuses = append(statuses, f)
+	}
+	redacts = map[string]bool{}
+	for _, v := range conf.RedactHeaders {
+		redacts[http.CanonicalHeaderKey(v)] = true
+	}
+
+	var rs []Reporter
+	if conf.File != "" {
+		r, err := NewFileReporter(conf.File)
+		if err != nil {
+			return err
+		}
+		rs = append(rs, r)
+	}
+	if conf.Webhook != "" {
+		rs = append(rs, NewWebhookReporter(conf.Webhook, conf.WebhookTimeout))
+	}
+	mt.Lock()
+	old := builtins
+	builtins = rs
+	mt.Unlock()
+	for _, r := range old {
+		if fr, ok := r.(*fileReporter); ok {
+			fr.close()
+		}
+	}
+
+	config = conf
+	isEnable = true
+
+	return
+}
+
+//如5xx、503
+func parseStatus(s string) (func(int) bool, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) == 3 && strings.HasSuffix(s, "xx") && s[0] >= '1' && s[0] <= '5' {
+		class := int(s[0] - '0')
+		return func(status int) bool { return status/100 == class }, nil
+	}
+	code, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, fmt.Errorf("reporter: error status %s", s)
+	}
+
+	return func(status int) bool { return status == code }, nil
+}
+
+//IsEnable ..
+func IsEnable() bool {
+	return isEnable
+}
+
+//Register 注册Reporter，可以多个
+func Register(r Reporter) {
+	mt.Lock()
+	defer mt.Unlock()
+	reporters = append(reporters, r)
+}
+
+//IsReportStatus http的状态码是否需要上报
+func IsReportStatus(status int) bool {
+	for _, f := range statuses {
+		if f(status) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//IsReportErrNo err_no是否需要上报
+func IsReportErrNo(errNo int64) bool {
+	if errNo == 0 {
+		return false
+	}
+	for _, v := range config.ErrNos {
+		if v == errNo {
+			return true
+		}
+	}
+
+	return false
+}
+
+//RedactHeader 复制header，需要脱敏的值替换为***
+func RedactHeader(h map[string][]string) map[string][]string {
+	if len(h) == 0 {
+		return nil
+	}
+	header := make(map[string][]string, len(h))
+	for k, v := range h {
+		if redacts[http.CanonicalHeaderKey(k)] {
+			header[k] = []string{"***"}
+			continue
+		}
+		header[k] = append([]string(nil), v...)
+	}
+
+	return header
+}
+
+//Report 上报，没有开启时忽略
+func Report(e *Event) {
+	if !isEnable || e == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Fingerprint == "" {
+		e.Fingerprint = Fingerprint(e)
+	}
+	if agg.add(e, config.Window) {
+		e.Count = 1
+		dispatch(e)
+	}
+}
+
+//Fingerprint 按来源、路由、错误信息和出错的代码位置计算
+func Fingerprint(e *Event) string {
+	var path string
+	if e.Request != nil {
+		path = e.Request.Route
+		if path == "" {
+			path = e.Request.Path
+		}
+	}
+
+	return common.Md5(strings.Join([]string{e.Source, e.Kind, path, e.Message, stackFrames(e.Stack, 3)}, "|"))
+}
+
+//panic的栈取runtime/panic.go之后的num个位置，不含参数和偏移
+func stackFrames(stack string, num int) string {
+	lines := strings.Split(stack, "\n")
+	start := 0
+	for i, line := range lines {
+		if strings.Contains(line, "runtime/panic.go") {
+			start = i + 1
+		}
+	}
+	var frames []string
+	for _, line := range lines[start:] {
+		if !strings.HasPrefix(line, "\t") {
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if i := strings.LastIndex(line, " +0x"); i > 0 {
+			line = line[:i]
+		}
+		frames = append(frames, line)
+		if len(frames) >= num {
+			break
+		}
+	}
+
+	return strings.Join(frames, ",")
+}
+
+func dispatch(e *Event) {
+	mt.RLock()
+	rs := append(append([]Reporter(nil), builtins...), reporters...)
+	mt.RUnlock()
+	for _, r := range rs {
+		go func(r Reporter) {
+			defer func() {
+				if err := recover(); err != nil {
+					logger.Warn("reporter panic:", err)
+				}
+			}()
+			if err := r.Report(e); err != nil {
+				logger.Warn("reporter:", err)
+			}
+		}(r)
+	}
+}
+
+type aggEvent struct {
+	count int
+	last  *Event
+}
+
+//aggregator Window内相同指纹的错误只计数
+type aggregator struct {
+	sync.Mutex
+	events map[string]*aggEvent
+}
+
+//第一次出现返回true，Window结束时如果有重复的，再上报一次累计的次数
+func (a *aggregator) add(e *Event, window time.Duration) bool {
+	a.Lock()
+	defer a.Unlock()
+	if v, ok := a.events[e.Fingerprint]; ok {
+		v.count++
+		v.last = e
+		return false
+	}
+	a.events[e.Fingerprint] = &aggEvent{count: 1, last: e}
+
+	time.AfterFunc(window, func() {
+		a.Lock()
+		v := a.events[e.Fingerprint]
+		delete(a.events, e.Fingerprint)
+		a.Unlock()
+		if v != nil && v.count > 1 {
+			last := *v.last
+			last.Count = v.count
+			dispatch(&last)
+		}
+	})
+
+	return true
+}
@@ -0,0 +1,150 @@
+package reporter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hsyan2008/hfw/configs"
+)
+
+func TestInitReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hfw-reporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "error.log")
+	events := make(chan *Event, 10)
+	Register(ReporterFunc(func(e *Event) error {
+		events <- e
+		return nil
+	}))
+	defer func() {
+		reporters, builtins = nil, nil
+		isEnable = false
+	}()
+
+	conf := configs.ReporterConfig{IsEnable: true, File: file}
+	for i := 0; i < 2; i++ {
+		if err = Init(conf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(builtins) != 1 || len(reporters) != 1 {
+		t.Fatalf("builtins = %d, reporters = %d", len(builtins), len(reporters))
+	}
+
+	Report(&Event{Source: "http", Kind: KindError, Message: "reload"})
+	select {
+	case <-events:
+	case <-time.After(time.Second):
+		t.Fatal("Register reporter not called after reload")
+	}
+	//重新Init后只写入一次
+	var b []byte
+	for i := 0; i < 100; i++ {
+		if b, _ = ioutil.ReadFile(file); len(b) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := strings.Count(string(b), "\n"); n != 1 {
+		t.Fatalf("file has %d lines: %s", n, b)
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	events := make(chan *Event, 10)
+	Register(ReporterFunc(func(e *Event) error {
+		events <- e
+		return nil
+	}))
+	defer func() { reporters = nil }()
+
+	a := &aggregator{events: make(map[string]*aggEvent)}
+	window := 50 * time.Millisecond
+	if !a.add(&Event{Fingerprint: "a", Message: "1"}, window) {
+		t.Fatal("first event should be reported")
+	}
+	if a.add(&Event{Fingerprint: "a", Message: "2"}, window) || a.add(&Event{Fingerprint: "a", Message: "3"}, window) {
+		t.Fatal("repeated event in window should not be reported")
+	}
+	if !a.add(&Event{Fingerprint: "b"}, window) {
+		t.Fatal("event with other fingerprint should be reported")
+	}
+
+	//Window结束时上报最后一次和累计的次数，只出现一次的不再上报
+	select {
+	case e := <-events:
+		if e.Fingerprint != "a" || e.Count != 3 || e.Message != "3" {
+			t.Fatalf("aggregated event = %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("aggregated event not reported")
+	}
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event %+v", e)
+	case <-time.After(2 * window):
+	}
+	if !a.add(&Event{Fingerprint: "a"}, window) {
+		t.Fatal("event after window should be reported")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	stack := func(offset string) string {
+		return "goroutine 1 [running]:\nruntime/debug.Stack()\n\t/go/src/runtime/debug/stack.go:24 +0x65\npanic({0x1, 0x2})\n\t/go/src/runtime/panic.go:838 +0x207\n" +
+			"main.(*Ctl).Index(0xc000010000)\n\t/app/ctl.go:10 " + offset + "\nmain.call()\n\t/app/main.go:20 +0x1f\n"
+	}
+	newEvent := func(route, path, offset string) *Event {
+		return &Event{Source: "http", Kind: KindPanic, Message: "boom", Stack: stack(offset),
+			Request: &Request{Route: route, Path: path}}
+	}
+
+	base := Fingerprint(newEvent("/users/:id", "/users/1", "+0x1d"))
+	//路由相同，path和偏移不同的是同一个错误
+	if fp := Fingerprint(newEvent("/users/:id", "/users/2", "+0x2e")); fp != base {
+		t.Fatal("same route and frames should have same fingerprint")
+	}
+	if fp := Fingerprint(newEvent("/orders/:id", "/orders/1", "+0x1d")); fp == base {
+		t.Fatal("different route should have different fingerprint")
+	}
+	//没有路由的用path
+	if Fingerprint(newEvent("", "/a", "+0x1d")) == Fingerprint(newEvent("", "/b", "+0x1d")) {
+		t.Fatal("different path should have different fingerprint")
+	}
+	if got := stackFrames(stack("+0x1d"), 3); got != "/app/ctl.go:10,/app/main.go:20" {
+		t.Fatalf("stackFrames = %s", got)
+	}
+}
+
+func TestRedactHeader(t *testing.T) {
+	defer func() { isEnable = false }()
+	if err := Init(configs.ReporterConfig{IsEnable: true}); err != nil {
+		t.Fatal(err)
+	}
+	h := map[string][]string{"Authorization": {"token"}, "cookie": {"a=1"}, "Accept": {"*/*"}}
+	got := RedactHeader(h)
+	if got["Authorization"][0] != "***" || got["cookie"][0] != "***" || got["Accept"][0] != "*/*" {
+		t.Fatalf("RedactHeader = %v", got)
+	}
+	//复制，不修改原来的
+	got["Accept"][0] = "text/html"
+	if h["Accept"][0] != "*/*" || h["Authorization"][0] != "token" {
+		t.Fatalf("header changed: %v", h)
+	}
+
+	//重新Init后使用新的配置
+	if err := Init(configs.ReporterConfig{IsEnable: true, RedactHeaders: []string{"X-Token"}}); err != nil {
+		t.Fatal(err)
+	}
+	got = RedactHeader(map[string][]string{"Authorization": {"token"}, "X-Token": {"t"}})
+	if got["Authorization"][0] != "token" || got["X-Token"][0] != "***" {
+		t.Fatalf("RedactHeader after reload = %v", got)
+	}
+}
@@ -2,22 +2,20 @@ package hfw
 
 //手动匹配路由
 import (
-	"errors"
 	"fmt"
+	"io/fs"
 	"net/http"
 	_ "net/http/pprof"
+	"path"
 	"path/filepath"
 	"reflect"
 	"strings"
-	"sync/atomic"
-	"time"
 
 	_ "github.com/mkevac/debugcharts"
 
 	logger "github.com/hsyan2008/go-logger"
 	"github.com/hsyan2008/hfw/common"
 	"github.com/hsyan2008/hfw/grpc/server"
-	"github.com/hsyan2008/hfw/prometheus"
 )
 
 //Router 写测试用例会调用
@@ -35,52 +33,100 @@ func Router(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	//没有版本前缀的，按Accept-Version补上，补上后是HandlerFunc注册的路由则交给它
+	if negotiateVersion(r) {
+		if h, pattern := http.DefaultServeMux.Handler(r); pattern != "" && pattern != "/" {
+			h.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	//跨域，预检请求直接返回
+	if handleCors(w, r) {
+		return
+	}
+
+	//带参数的路由优先
+	rt, params := matchRoute(r)
+	if rt != nil && rt.handler != nil {
+		rt.handler(w, withParams(r, params))
+		return
+	}
+
 	//初始化httpCtx
 	httpCtx := initCtx(w, r)
 	defer httpCtx.Cancel()
+	httpCtx.params = params
+	defer startHTTPSpan(httpCtx)()
 
 	//如果用户关闭连接
 	go closeNotify(httpCtx)
 
-	prometheus.RequestsTotal(r.URL.Path, r.Method)
-	defer func(path, method string, startTime time.Time) {
-		costTime := time.Since(startTime)
-		httpCtx.Mixf("Path:%s Method:%s CostTime:%s", path, method, costTime)
-		prometheus.RequestsCosttime(path, method, costTime)
-	}(r.URL.Path, r.Method, time.Now())
-
-	onlineNum := atomic.AddUint32(&online, 1)
-	httpCtx.Mixf("From:%s Path:%s Online:%d", r.RemoteAddr, r.URL.String(), onlineNum)
-	defer func() {
-		atomic.AddUint32(&online, ^uint32(0))
-	}()
-	err := checkConcurrence(onlineNum)
-	if err != nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		httpCtx.Warn(err)
+	if rt == nil && defaultInstance == nil {
+		runMiddlewares(httpCtx, buildMiddlewares(r.URL.Path, nil), func() {
+			httpCtx.Warn(httpCtx.Request.URL.Path, "nil routeMap or routeMapMethod")
+			(&Controller{}).NotFound(httpCtx)
+		})
 		return
 	}
 
-	if len(routeMap) == 0 && len(routeMapMethod) == 0 {
-		httpCtx.Warn(httpCtx.Request.URL.Path, "nil routeMap or routeMapMethod")
-		(&Controller{}).NotFound(httpCtx)
-		return
+	var instance *instance
+	var methodName string
+	if rt != nil {
+		instance, methodName = rt.instance, rt.instance.methodName
+		httpCtx.Controller, httpCtx.Action = instance.controllerName, methodName
+		httpCtx.Route = rt.pattern
+	} else {
+		instance, methodName = findInstanceByPath(httpCtx)
 	}
+	httpCtx.Debugf("Path:%s -> Call:%s/%s", httpCtx.Request.URL.Path, httpCtx.Controller, httpCtx.Action)
 
+	//NotFound不执行路由上的中间件
+	var mws []Middleware
+	if methodName != NotFound {
+		mws = instance.middlewares
+	}
+	runMiddlewares(httpCtx, buildMiddlewares(r.URL.Path, mws), func() {
+		callInstance(httpCtx, instance, methodName)
+	})
+}
+
+func callInstance(httpCtx *HTTPContext, instance *instance, methodName string) {
 	initValue := []reflect.Value{
 		reflect.ValueOf(httpCtx),
 	}
-
-	instance, methodName := findInstanceByPath(httpCtx)
-	httpCtx.Debugf("Path:%s -> Call:%s/%s", httpCtx.Request.URL.Path, httpCtx.Controller, httpCtx.Action)
 	reflectVal := instance.reflectVal
 
 	//注意方法必须是大写开头，否则无法调用
 	reflectVal.MethodByName("Init").Call(initValue)
+	//设置了超时的，超时后不等action执行结束
+	if httpCtx.timeoutCancel != nil {
+		httpCtx.runWithTimeout(func() {
+			callAction(httpCtx, reflectVal, initValue, methodName)
+		})
+		return
+	}
+	callAction(httpCtx, reflectVal, initValue, methodName)
+}
+
+func callAction(httpCtx *HTTPContext, reflectVal reflect.Value, initValue []reflect.Value, methodName string) {
 	defer reflectVal.MethodByName("Finish").Call(initValue)
+	//在recoverPanic之后执行
+	defer httpCtx.checkTimeout()
 
 	defer recoverPanic(httpCtx, reflectVal, initValue)
 
+	//默认布局，Before和action里可以修改
+	if l, ok := reflectVal.Interface().(LayoutInterface); ok {
+		httpCtx.Layout = l.DefaultLayout()
+	} else {
+		httpCtx.Layout = Config.Template.Layout
+	}
+
+	//multipart的请求限制请求体大小，要在checkCsrf解析表单之前
+	httpCtx.checkUploadBody()
+	httpCtx.checkCsrf()
+
 	reflectVal.MethodByName("Before").Call(initValue)
 	defer reflectVal.MethodByName("After").Call(initValue)
 
@@ -94,7 +140,9 @@ func recoverPanic(httpCtx *HTTPContext, reflectVal reflect.Value, initValue []re
 		if err == ErrStopRun {
 			return
 		}
-		httpCtx.Fatal(err, string(common.GetStack()))
+		stack := common.GetStack()
+		httpCtx.Fatal(err, string(stack))
+		reportPanic(httpCtx, "http", err, stack)
 
 		reflectVal.MethodByName("ServerError").Call(initValue)
 	}
@@ -108,33 +156,27 @@ func closeNotify(httpCtx *HTTPContext) {
 	httpCtx.Cancel()
 }
 
-var online uint32
-
-func checkConcurrence(onlineNum uint32) (err error) {
-	if common.IsGoTest() || Config.Server.Concurrence <= 0 {
-		return nil
-	}
-
-	if onlineNum > uint32(Config.Server.Concurrence) {
-		return errors.New("checkConcurrence: too many concurrence")
-	}
-	return nil
-}
-
 func init() {
 	http.HandleFunc("/logger/adjust", loggerAdjust)
 }
 
 var isInit bool
 
-//Handler 暂时只支持2段
-func Handler(pattern string, handler ControllerInterface) (err error) {
+func initRouter() {
 	if isInit == false {
 		isInit = true
 		http.HandleFunc("/", Router)
 	}
+}
+
+//Handler 不带参数的pattern暂时只支持2段
+//带参数的pattern，如/users/:id，注册到路由树，action拼接在pattern后面
+//middlewares是该controller的中间件
+func Handler(pattern string, handler ControllerInterface, middlewares ...Middleware) (err error) {
+	initRouter()
 
 	controllerPath := completeURL(pattern)
+	isPattern := isPatternRoute(pattern)
 
 	reflectVal := reflect.ValueOf(handler)
 	rt := reflectVal.Type()
@@ -154,18 +196,23 @@ func Handler(pattern string, handler ControllerInterface) (err error) {
 	for i := 0; i < numMethod; i++ {
 		m := rt.Method(i).Name
 		switch m {
-		case "Init", "Before", "After", "Finish", "NotFound", "ServerError":
+		case "Init", "Before", "After", "Finish", "NotFound", "ServerError", "DefaultLayout", "APIDocs":
 		default:
 			actions, method, isMethod := getActionsAndMethod(m)
 			value := &instance{
 				reflectVal:     reflectVal,
 				controllerName: controllerName,
 				methodName:     rt.Method(i).Name,
+				middlewares:    middlewares,
 			}
 			if defaultInstance == nil {
 				defaultInstance = value
 			}
 			for _, action := range actions {
+				if isPattern {
+					handlePatternAction(pattern, action, method, value)
+					continue
+				}
 				if isMethod {
 					path := fmt.Sprintf("%s/%sfor%s", controllerPath, action, method)
 					if _, ok := routeMapMethod[path]; ok {
@@ -188,73 +235,129 @@ func Handler(pattern string, handler ControllerInterface) (err error) {
 	return
 }
 
+func handlePatternAction(pattern, action, method string, value *instance) {
+	path := strings.TrimRight(pattern, "/") + "/" + action
+	routeTree.add(method, path, &route{instance: value})
+	logger.Infof("pattern: %s method: %s register in routeTree: %s", pattern, method, path)
+	if action == Config.Route.DefaultAction {
+		routeTree.add(method, pattern, &route{instance: value})
+		logger.Infof("pattern: %s method: %s register in routeTree: %s", pattern, method, pattern)
+	}
+}
+
 //HandlerFunc register HandlerFunc
-func HandlerFunc(pattern string, h http.HandlerFunc) {
+//带参数的pattern注册到路由树，参数通过PathParam获取
+//m是该pattern的中间件
+func HandlerFunc(pattern string, h http.HandlerFunc, m ...Middleware) {
 	logger.Infof("HandlerFunc: %s", pattern)
 	if pattern == "/" || pattern == "/logger/adjust" {
 		panic("http: multiple registrations for " + pattern)
 	}
-	http.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+	f := wrapMiddlewares(pattern, h, m)
+	if isPatternRoute(pattern) {
+		initRouter()
+		routeTree.add("", pattern, &route{handler: f})
+		return
+	}
+	handlerFuncRoutes = append(handlerFuncRoutes, RouteInfo{Path: pattern, Kind: RouteKindHandlerFunc})
+	http.HandleFunc(pattern, f)
+}
+
+func wrapMiddlewares(pattern string, h http.HandlerFunc, m []Middleware) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if handleCors(w, r) {
+			return
+		}
 		httpCtx := initCtx(w, r)
 		defer httpCtx.Cancel()
+		httpCtx.Route = pattern
+		defer startHTTPSpan(httpCtx)()
 
-		prometheus.RequestsTotal(r.URL.Path, r.Method)
-		defer func(path, method string, startTime time.Time) {
-			costTime := time.Since(startTime)
-			httpCtx.Mixf("Path:%s Method:%s CostTime:%s", path, method, costTime)
-			prometheus.RequestsCosttime(path, method, costTime)
-		}(r.URL.Path, r.Method, time.Now())
-
-		onlineNum := atomic.AddUint32(&online, 1)
-		httpCtx.Mixf("From:%s Path:%s Online:%d", r.RemoteAddr, r.URL.String(), onlineNum)
-		defer func() {
-			atomic.AddUint32(&online, ^uint32(0))
-			if err := recover(); err != nil {
-				if err == ErrStopRun {
-					return
-				}
-				httpCtx.Fatal(err, string(common.GetStack()))
+		runMiddlewares(httpCtx, buildMiddlewares(r.URL.Path, m), func() {
+			if !httpCtx.checkHandlerCsrf() {
+				return
 			}
-		}()
-		err := checkConcurrence(onlineNum)
-		if err != nil {
-			w.WriteHeader(http.StatusServiceUnavailable)
-			httpCtx.Warn(err)
-			return
-		}
-		h(w, r)
-	})
+			h(&handlerResponseWriter{ResponseWriter: httpCtx.ResponseWriter, httpCtx: httpCtx}, r)
+		})
+	}
 }
 
 //Handle register Handle
-func Handle(pattern string, h http.Handler) {
-	HandlerFunc(pattern, h.ServeHTTP)
+func Handle(pattern string, h http.Handler, m ...Middleware) {
+	HandlerFunc(pattern, h.ServeHTTP, m...)
 }
 
 //StaticHandler ...
 //如pattern=css,dir=./static，则css在./static下
 func StaticHandler(pattern string, dir string) {
-	if !filepath.IsAbs(dir) {
-		dir = filepath.Join(common.GetAppPath(), dir)
-	}
-	if pattern != "/" {
-		pattern = "/" + strings.Trim(pattern, "/") + "/"
-	}
-	logger.Info("StaticHandler", pattern, dir)
-	http.Handle(pattern, http.FileServer(http.Dir(dir)))
+	staticHandler("", pattern, dir, false, nil)
 }
 
 //StaticStripHandler ...
 //如pattern=css,dir=./static/css，则css就是./static/css
 func StaticStripHandler(pattern string, dir string) {
+	staticHandler("", pattern, dir, true, nil)
+}
+
+//StaticFSHandler 从fsys提供静态文件，如embed.FS，用于单文件部署
+//和StaticStripHandler一样，url去掉pattern后就是文件在fsys里的路径
+//如pattern=static，则/static/css/a.css对应fsys里的css/a.css，可以用fs.Sub调整根目录
+func StaticFSHandler(pattern string, fsys fs.FS) {
+	staticFileSystem("", pattern, http.FS(fsys), fmt.Sprintf("%T", fsys), true, nil)
+}
+
+//prefix是分组的前缀，查找文件时会去掉
+func staticHandler(prefix, pattern, dir string, isStrip bool, m []Middleware) {
 	if !filepath.IsAbs(dir) {
 		dir = filepath.Join(common.GetAppPath(), dir)
 	}
+	staticFileSystem(prefix, pattern, http.Dir(dir), dir, isStrip, m)
+}
+
+//dir用于日志和路由表
+func staticFileSystem(prefix, pattern string, fsys http.FileSystem, dir string, isStrip bool, m []Middleware) {
+	pattern = prefix + "/" + strings.Trim(pattern, "/")
 	if pattern != "/" {
-		pattern = "/" + strings.Trim(pattern, "/") + "/"
+		pattern = strings.TrimRight(pattern, "/") + "/"
 	}
-	logger.Info("StaticStripHandler", pattern, dir)
-	http.Handle(pattern, http.StripPrefix(pattern, http.FileServer(http.Dir(dir))))
+
+	var h http.Handler = staticCacheControl(http.FileServer(fsys))
+	if isStrip {
+		logger.Info("StaticStripHandler", pattern, dir)
+		h = http.StripPrefix(pattern, h)
+	} else {
+		logger.Info("StaticHandler", pattern, dir)
+		if prefix != "" {
+			h = http.StripPrefix(prefix, h)
+		}
+	}
+
+	staticRoutes = append(staticRoutes, RouteInfo{Path: pattern, Method: http.MethodGet, Kind: RouteKindStatic, Dir: dir})
+	if len(m) == 0 {
+		//没有中间件的也要处理跨域
+		http.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			if handleCors(w, r) {
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+		return
+	}
+	http.HandleFunc(pattern, wrapMiddlewares(pattern, h.ServeHTTP, m))
+}
+
+//按Template.StaticCacheControl配置设置Cache-Control
+func staticCacheControl(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cacheControl := Config.Template.StaticCacheControl
+		if v, ok := Config.Template.StaticCacheControlExts[strings.ToLower(path.Ext(r.URL.Path))]; ok {
+			cacheControl = v
+		}
+		if cacheControl != "" {
+			w.Header().Set("Cache-Control", cacheControl)
+		}
+		h.ServeHTTP(w, r)
+	})
 }
 
 //调整logger的设置
@@ -14,6 +14,8 @@ type instance struct {
 	controllerName string
 	//方法名字
 	methodName string
+	//controller的中间件
+	middlewares []Middleware
 }
 
 const (
@@ -35,6 +37,9 @@ func findInstanceByPath(httpCtx *HTTPContext) (instance *instance, action string
 	defer func() {
 		httpCtx.Controller = instance.controllerName
 		httpCtx.Action = action
+		if action != NotFound {
+			httpCtx.Route = "/" + httpCtx.Path
+		}
 	}()
 
 	var inputPath string
@@ -44,33 +49,35 @@ func findInstanceByPath(httpCtx *HTTPContext) (instance *instance, action string
 		inputPath = httpCtx.Path
 	}
 
+	if instance, httpCtx.Path, ok = lookupInstance(inputPath, httpCtx.Request.Method); ok {
+		return instance, instance.methodName
+	}
+
+	//defaultInstance可能是nil，但Router已有判断
+	return defaultInstance, NotFound
+}
+
+//按url查找controller，不修改httpCtx，path是controller/action，找不到时是最后尝试的
+func lookupInstance(inputPath, method string) (instance *instance, path string, ok bool) {
 	//假设url上没有action
 	controllerPath := completeURL(inputPath)
-	httpCtx.Action = Config.Route.DefaultAction
-	httpCtx.Path = fmt.Sprintf("%s/%s", controllerPath, httpCtx.Action)
-	if instance, ok = routeMapMethod[httpCtx.Path+"for"+httpCtx.Request.Method]; ok {
-		return instance, instance.methodName
+	path = fmt.Sprintf("%s/%s", controllerPath, Config.Route.DefaultAction)
+	if instance, ok = routeMapMethod[path+"for"+method]; ok {
+		return
 	}
-	if instance, ok = routeMap[httpCtx.Path]; ok {
-		return instance, instance.methodName
+	if instance, ok = routeMap[path]; ok {
+		return
 	}
 
 	//假设url上最后一段是action
 	tmp := strings.Split(controllerPath, "/")
-	controllerPath = strings.Join(tmp[:len(tmp)-1], "/")
-	httpCtx.Action = tmp[len(tmp)-1]
-	httpCtx.Path = fmt.Sprintf("%s/%s", controllerPath, httpCtx.Action)
-	if instance, ok = routeMapMethod[httpCtx.Path+"for"+httpCtx.Request.Method]; ok {
-		return instance, instance.methodName
-	}
-	if instance, ok = routeMap[httpCtx.Path]; ok {
-		return instance, instance.methodName
+	path = fmt.Sprintf("%s/%s", strings.Join(tmp[:len(tmp)-1], "/"), tmp[len(tmp)-1])
+	if instance, ok = routeMapMethod[path+"for"+method]; ok {
+		return
 	}
+	instance, ok = routeMap[path]
 
-	httpCtx.Action = strings.ToLower(NotFound)
-
-	//defaultInstance可能是nil，但Router已有判断
-	return defaultInstance, NotFound
+	return
 }
 
 func completeURL(url string) string {
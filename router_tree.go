@@ -0,0 +1,237 @@
+package hfw
+
+//按段匹配的路由树，支持命名参数和通配参数
+//如 /users/:id/orders/:oid 和 /files/*filepath
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	logger "github.com/hsyan2008/go-logger"
+)
+
+//Param 路由参数
+type Param struct {
+	Key   string
+	Value string
+}
+
+//Params 路由参数列表，按在路径中出现的顺序排列
+type Params []Param
+
+//Get 获取指定名称的参数值，不存在返回空
+func (ps Params) Get(name string) string {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value
+		}
+	}
+
+	return ""
+}
+
+type route struct {
+	pattern string
+	method  string
+	//controller的action
+	instance *instance
+	//HandlerFunc注册的
+	handler http.HandlerFunc
+}
+
+type routeNode struct {
+	//静态子节点，key为小写
+	children map[string]*routeNode
+	//:name
+	param *routeNode
+	//*name
+	wildcard *routeNode
+	//参数名，只有param和wildcard节点有
+	paramName string
+	//key是http method，空表示任意method
+	routes map[string]*route
+}
+
+func newRouteNode() *routeNode {
+	return &routeNode{
+		children: make(map[string]*routeNode),
+	}
+}
+
+var routeTree = newRouteNode()
+
+//是否带有参数的路由
+func isPatternRoute(pattern string) bool {
+	return strings.Contains(pattern, "/:") || strings.Contains(pattern, "/*") ||
+		strings.HasPrefix(pattern, ":") || strings.HasPrefix(pattern, "*")
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+
+	return strings.Split(path, "/")
+}
+
+func (n *routeNode) add(method, pattern string, r *route) {
+	node := n
+	segments := splitPath(pattern)
+	for i, seg := range segments {
+		//如 /a//b，不能用seg[0]判断
+		if seg == "" {
+			panic(pattern + " has empty segment")
+		}
+		switch seg[0] {
+		case ':':
+			name := seg[1:]
+			if name == "" {
+				panic(pattern + " param name is empty")
+			}
+			if node.param == nil {
+				node.param = newRouteNode()
+				node.param.paramName = name
+			} else if node.param.paramName != name {
+				panic(fmt.Sprintf("%s conflict with param :%s", pattern, node.param.paramName))
+			}
+			node = node.param
+		case '*':
+			if i != len(segments)-1 {
+				panic(pattern + " wildcard must be the last segment")
+			}
+			name := seg[1:]
+			if node.wildcard == nil {
+				node.wildcard = newRouteNode()
+				node.wildcard.paramName = name
+			} else if node.wildcard.paramName != name {
+				panic(fmt.Sprintf("%s conflict with wildcard *%s", pattern, node.wildcard.paramName))
+			}
+			node = node.wildcard
+		default:
+			seg = strings.ToLower(seg)
+			child, ok := node.children[seg]
+			if !ok {
+				child = newRouteNode()
+				node.children[seg] = child
+			}
+			node = child
+		}
+	}
+
+	if node.routes == nil {
+		node.routes = make(map[string]*route)
+	}
+	if _, ok := node.routes[method]; ok {
+		panic(fmt.Sprintf("%s %s has exist", method, pattern))
+	}
+	r.pattern = "/" + strings.Join(segments, "/")
+	r.method = method
+	node.routes[method] = r
+}
+
+//优先级：静态 > 命名参数 > 通配参数
+func (n *routeNode) match(method string, segments []string, params Params) (*route, Params) {
+	if len(segments) == 0 {
+		if r := n.getRoute(method); r != nil {
+			return r, params
+		}
+		//通配参数可以匹配空
+		if n.wildcard != nil {
+			if r := n.wildcard.getRoute(method); r != nil {
+				return r, append(params, Param{Key: n.wildcard.paramName})
+			}
+		}
+		return nil, params
+	}
+
+	seg := segments[0]
+	if child, ok := n.children[strings.ToLower(seg)]; ok {
+		if r, ps := child.match(method, segments[1:], params); r != nil {
+			return r, ps
+		}
+	}
+	if n.param != nil {
+		if r, ps := n.param.match(method, segments[1:], append(params, Param{Key: n.param.paramName, Value: seg})); r != nil {
+			return r, ps
+		}
+	}
+	if n.wildcard != nil {
+		if r := n.wildcard.getRoute(method); r != nil {
+			return r, append(params, Param{Key: n.wildcard.paramName, Value: strings.Join(segments, "/")})
+		}
+	}
+
+	return nil, params
+}
+
+func (n *routeNode) getRoute(method string) *route {
+	if len(n.routes) == 0 {
+		return nil
+	}
+	if r, ok := n.routes[method]; ok {
+		return r
+	}
+
+	return n.routes[""]
+}
+
+func matchRoute(r *http.Request) (*route, Params) {
+	if routeTree.isEmpty() {
+		return nil, nil
+	}
+	return routeTree.match(r.Method, splitPath(r.URL.Path), nil)
+}
+
+func (n *routeNode) isEmpty() bool {
+	return len(n.children) == 0 && n.param == nil && n.wildcard == nil && len(n.routes) == 0
+}
+
+type paramsKey struct{}
+
+func withParams(r *http.Request, params Params) *http.Request {
+	if len(params) == 0 {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), paramsKey{}, params))
+}
+
+//GetParams 获取HandlerFunc里的路由参数
+func GetParams(r *http.Request) Params {
+	if ps, ok := r.Context().Value(paramsKey{}).(Params); ok {
+		return ps
+	}
+
+	return nil
+}
+
+//PathParam 获取HandlerFunc里指定名称的路由参数
+func PathParam(r *http.Request, name string) string {
+	return GetParams(r).Get(name)
+}
+
+//Route 把pattern直接注册到controller的指定方法上
+//method为空表示任意http method，m是该路由的中间件
+//如Route("GET", "/users/:id/orders/:oid", &User{}, "Order")
+func Route(method, pattern string, handler ControllerInterface, methodName string, m ...Middleware) {
+	initRouter()
+
+	reflectVal := reflect.ValueOf(handler)
+	if !reflectVal.MethodByName(methodName).IsValid() {
+		panic(fmt.Sprintf("%s has no method %s", reflectVal.Type(), methodName))
+	}
+	value := &instance{
+		reflectVal:     reflectVal,
+		controllerName: reflect.Indirect(reflectVal).Type().Name(),
+		methodName:     methodName,
+		middlewares:    m,
+	}
+	if defaultInstance == nil {
+		defaultInstance = value
+	}
+	method = strings.ToUpper(method)
+	routeTree.add(method, pattern, &route{instance: value})
+	logger.Infof("pattern: %s method: %s register in routeTree: %s", pattern, method, methodName)
+}
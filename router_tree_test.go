@@ -0,0 +1,102 @@
+package hfw
+
+import (
+	"net/http"
+	"testing"
+)
+
+func newTestTree(patterns ...string) *routeNode {
+	n := newRouteNode()
+	for _, pattern := range patterns {
+		n.add("", pattern, &route{})
+	}
+
+	return n
+}
+
+func TestRouteTreePriority(t *testing.T) {
+	n := newTestTree("/users/new", "/users/:id", "/users/*path", "/files/*path")
+	cases := []struct {
+		path    string
+		pattern string
+		params  Params
+	}{
+		{"/users/new", "/users/new", nil},
+		{"/users/NEW", "/users/new", nil},
+		{"/users/1", "/users/:id", Params{{"id", "1"}}},
+		{"/users/1/orders", "/users/*path", Params{{"path", "1/orders"}}},
+		//通配参数可以匹配空
+		{"/files", "/files/*path", Params{{"path", ""}}},
+		{"/files/", "/files/*path", Params{{"path", ""}}},
+		{"/files/a/b", "/files/*path", Params{{"path", "a/b"}}},
+	}
+	for _, c := range cases {
+		r, ps := n.match("GET", splitPath(c.path), nil)
+		if r == nil || r.pattern != c.pattern {
+			t.Fatalf("%s matched %v, want %s", c.path, r, c.pattern)
+		}
+		if len(ps) != len(c.params) {
+			t.Fatalf("%s params = %v, want %v", c.path, ps, c.params)
+		}
+		for i := range ps {
+			if ps[i] != c.params[i] {
+				t.Fatalf("%s params = %v, want %v", c.path, ps, c.params)
+			}
+		}
+	}
+	if r, _ := n.match("GET", splitPath("/other"), nil); r != nil {
+		t.Fatalf("/other matched %s", r.pattern)
+	}
+}
+
+func TestRouteTreeMethod(t *testing.T) {
+	n := newRouteNode()
+	n.add(http.MethodPost, "/users/:id", &route{handler: func(http.ResponseWriter, *http.Request) {}})
+	n.add("", "/users/:id", &route{})
+	n.add(http.MethodGet, "/items/:id", &route{})
+
+	if r, _ := n.match(http.MethodPost, splitPath("/users/1"), nil); r == nil || r.method != http.MethodPost {
+		t.Fatalf("POST matched %v, want POST route", r)
+	}
+	//没有对应method的，用任意method的
+	if r, _ := n.match(http.MethodGet, splitPath("/users/1"), nil); r == nil || r.method != "" {
+		t.Fatalf("GET matched %v, want any method route", r)
+	}
+	if r, _ := n.match(http.MethodPost, splitPath("/items/1"), nil); r != nil {
+		t.Fatalf("POST /items/1 matched %s %s", r.method, r.pattern)
+	}
+}
+
+func TestRouteTreeAddPanic(t *testing.T) {
+	cases := []struct {
+		exist   string
+		pattern string
+	}{
+		{"/users/:id", "/users/:uid/orders"},
+		{"/files/*path", "/files/*name"},
+		{"", "/files/*path/a"},
+		{"", "/a//b"},
+		{"", "/users/:"},
+		{"/users/:id", "/users/:id"},
+	}
+	for _, c := range cases {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("add %s after %s should panic", c.pattern, c.exist)
+				}
+			}()
+			n := newRouteNode()
+			if c.exist != "" {
+				n.add("", c.exist, &route{})
+			}
+			n.add("", c.pattern, &route{})
+		}()
+	}
+
+	//末尾的/会被去掉
+	n := newTestTree("/users/:id/")
+	if r, _ := n.match("GET", splitPath("/users/1"), nil); r == nil || r.pattern != "/users/:id" {
+		t.Fatalf("trailing slash matched %v", r)
+	}
+}
@@ -0,0 +1,147 @@
+package hfw
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	logger "github.com/hsyan2008/go-logger"
+	"github.com/hsyan2008/hfw/encoding"
+)
+
+//路由的来源
+const (
+	RouteKindRouteMap       = "routeMap"
+	RouteKindRouteMapMethod = "routeMapMethod"
+	RouteKindRouteTree      = "routeTree"
+	RouteKindHandlerFunc    = "handlerFunc"
+	RouteKindStatic         = "static"
+)
+
+//RouteInfo 路由信息
+type RouteInfo struct {
+	Path string `json:"path"`
+	//空表示任意http method
+	Method string `json:"method"`
+	//对应的struct名称
+	Controller string `json:"controller,omitempty"`
+	//对应的struct方法的名称
+	Action string `json:"action,omitempty"`
+	Kind   string `json:"kind"`
+	//静态文件的目录
+	Dir string `json:"dir,omitempty"`
+
+	//controller的路由，用于生成OpenAPI
+	instance *instance
+}
+
+var (
+	handlerFuncRoutes []RouteInfo
+	staticRoutes      []RouteInfo
+)
+
+//Routes 返回所有已注册的路由，按Path和Method排序
+func Routes() (list []RouteInfo) {
+	for path, ins := range routeMap {
+		list = append(list, RouteInfo{
+			Path:       "/" + path,
+			Controller: ins.controllerName,
+			Action:     ins.methodName,
+			Kind:       RouteKindRouteMap,
+			instance:   ins,
+		})
+	}
+	for path, ins := range routeMapMethod {
+		idx := strings.LastIndex(path, "for")
+		list = append(list, RouteInfo{
+			Path:       "/" + path[:idx],
+			Method:     path[idx+3:],
+			Controller: ins.controllerName,
+			Action:     ins.methodName,
+			Kind:       RouteKindRouteMapMethod,
+			instance:   ins,
+		})
+	}
+	list = routeTree.walk(list)
+	list = append(list, handlerFuncRoutes...)
+	list = append(list, staticRoutes...)
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Path == list[j].Path {
+			return list[i].Method < list[j].Method
+		}
+		return list[i].Path < list[j].Path
+	})
+
+	return
+}
+
+func (n *routeNode) walk(list []RouteInfo) []RouteInfo {
+	for _, r := range n.routes {
+		info := RouteInfo{
+			Path:   r.pattern,
+			Method: r.method,
+		}
+		if r.instance != nil {
+			info.Controller = r.instance.controllerName
+			info.Action = r.instance.methodName
+			info.Kind = RouteKindRouteTree
+			info.instance = r.instance
+		} else {
+			info.Kind = RouteKindHandlerFunc
+		}
+		list = append(list, info)
+	}
+	for _, child := range n.children {
+		list = child.walk(list)
+	}
+	if n.param != nil {
+		list = n.param.walk(list)
+	}
+	if n.wildcard != nil {
+		list = n.wildcard.walk(list)
+	}
+
+	return list
+}
+
+//输出路由表，默认json，format=text或者Accept是text/plain则输出文本
+func routesHandler(w http.ResponseWriter, r *http.Request) {
+	list := Routes()
+	if r.FormValue("format") == "text" || strings.Contains(r.Header.Get("Accept"), "text/plain") {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		writeRoutes(w, list)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = encoding.JSONIO.Marshal(w, list)
+}
+
+//启动时在日志里输出路由表
+func logRoutes() {
+	buf := &bytes.Buffer{}
+	writeRoutes(buf, Routes())
+	logger.Info("routes:\n" + buf.String())
+}
+
+func writeRoutes(w io.Writer, list []RouteInfo) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tMETHOD\tCONTROLLER\tACTION\tKIND")
+	for _, v := range list {
+		method := v.Method
+		if method == "" {
+			method = "*"
+		}
+		action := v.Action
+		if v.Kind == RouteKindStatic {
+			action = v.Dir
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", v.Path, method, v.Controller, action, v.Kind)
+	}
+	_ = tw.Flush()
+}
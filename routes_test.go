@@ -0,0 +1,70 @@
+package hfw
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type testRoutesCtl struct {
+	Controller
+}
+
+func (ctl *testRoutesCtl) Index(httpCtx *HTTPContext) {}
+func (ctl *testRoutesCtl) Info(httpCtx *HTTPContext)  {}
+
+func (ctl *testRoutesCtl) SaveForPOST(httpCtx *HTTPContext) {}
+
+func TestRoutes(t *testing.T) {
+	ctl := &testRoutesCtl{}
+	_ = Handler("/test_routes", ctl)
+	Route(http.MethodGet, "/test_routes/:id", ctl, "Info")
+	HandlerFunc("/test_routes_func", func(http.ResponseWriter, *http.Request) {})
+	HandlerFunc("/test_routes_func/*path", func(http.ResponseWriter, *http.Request) {})
+	StaticHandler("/test_routes_static", "static")
+
+	want := []RouteInfo{
+		{Path: "/test_routes/index", Controller: "testRoutesCtl", Action: "Index", Kind: RouteKindRouteMap},
+		{Path: "/test_routes/save", Method: http.MethodPost, Controller: "testRoutesCtl", Action: "SaveForPOST", Kind: RouteKindRouteMapMethod},
+		{Path: "/test_routes/:id", Method: http.MethodGet, Controller: "testRoutesCtl", Action: "Info", Kind: RouteKindRouteTree},
+		{Path: "/test_routes_func", Kind: RouteKindHandlerFunc},
+		{Path: "/test_routes_func/*path", Kind: RouteKindHandlerFunc},
+		{Path: "/test_routes_static/", Method: http.MethodGet, Kind: RouteKindStatic},
+	}
+	list := Routes()
+	for _, w := range want {
+		var found bool
+		for _, v := range list {
+			if v.Path == w.Path && v.Method == w.Method {
+				found = true
+				v.Dir, v.instance = "", nil
+				if v != w {
+					t.Fatalf("route %s = %+v, want %+v", w.Path, v, w)
+				}
+			}
+		}
+		if !found {
+			t.Fatalf("route %s %s not found in %+v", w.Method, w.Path, list)
+		}
+	}
+	for i := 1; i < len(list); i++ {
+		if list[i-1].Path > list[i].Path {
+			t.Fatalf("routes not sorted: %s > %s", list[i-1].Path, list[i].Path)
+		}
+	}
+
+	HandlerFunc("/test_routes_debug", routesHandler)
+	w := serveTest("GET", "/test_routes_debug", nil)
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") ||
+		!strings.Contains(w.Body.String(), `"path":"/test_routes/:id","method":"GET","controller":"testRoutesCtl","action":"Info","kind":"routeTree"`) {
+		t.Fatalf("json = %s", w.Body.String())
+	}
+	w = serveTest("GET", "/test_routes_debug?format=text", nil)
+	text := &bytes.Buffer{}
+	writeRoutes(text, Routes())
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") || w.Body.String() != text.String() ||
+		!strings.Contains(text.String(), "PATH") {
+		t.Fatalf("text = %s", w.Body.String())
+	}
+}
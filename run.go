@@ -12,6 +12,7 @@ import (
 func Run() (err error) {
 
 	signalContext := signal.GetSignalContext()
+	signalContext.SetShutdownConfig(Config.Shutdown)
 
 	signalContext.Mix("Starting ...")
 	defer signalContext.Mix("Shutdowned!")
@@ -29,6 +30,10 @@ func Run() (err error) {
 		go deploy.HotDeploy(Config.HotDeploy)
 	}
 
+	if Config.Template.IsWatch && templateFS.fsys == nil {
+		go watchTemplates()
+	}
+
 	if len(Config.Server.Address) == 0 {
 		signalContext.Fatal("server address is nil")
 		<-signal.GetSignalContext().Ctx.Done()
@@ -37,6 +42,7 @@ func Run() (err error) {
 
 	//启动http
 	signalContext.IsHTTP = true
+	logRoutes()
 
 	err = StartHTTP(Config.Server)
 
@@ -2,17 +2,13 @@ package hfw
 
 import (
 	"context"
-	"errors"
 	"net"
-	"sync/atomic"
-	"time"
 
 	logger "github.com/hsyan2008/go-logger"
 	"github.com/hsyan2008/hfw/common"
 	"github.com/hsyan2008/hfw/configs"
 	"github.com/hsyan2008/hfw/grpc/discovery"
 	"github.com/hsyan2008/hfw/grpc/server"
-	"github.com/hsyan2008/hfw/prometheus"
 	"github.com/hsyan2008/hfw/signal"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -23,6 +19,7 @@ import (
 //如果是https+证书grpc，请配置好Server并使用NewGrpcServer+hfw.Run
 //如果是grpc，请配置好Server和GrpcServer并使用NewGrpcServer+hfw.RunGrpc
 //如果是grpc+http，请配置好Server和GrpcServer并使用NewGrpcServer+hfw.RunGrpc+hfw.Run
+//如果是grpc+http且没有证书，也可以配置Server.IsH2C并使用NewGrpcServer+hfw.Run，共用一个端口
 
 func NewGrpcServer(config configs.AllConfig) (s *grpc.Server, err error) {
 	return server.NewServer(config.Server.ServerConfig, grpc.UnaryInterceptor(UnaryServerInterceptor),
@@ -38,6 +35,7 @@ func GetGrpcServerListener() net.Listener {
 func RunGrpc(s *grpc.Server, config configs.GrpcServerConfig) (err error) {
 	//监听信号
 	signalContext := signal.GetSignalContext()
+	signalContext.SetShutdownConfig(Config.Shutdown)
 	go signalContext.Listen()
 
 	signalContext.Mix("grpc server Starting ...")
@@ -62,19 +60,32 @@ func RunGrpc(s *grpc.Server, config configs.GrpcServerConfig) (err error) {
 		return err
 	}
 	if r != nil {
-		defer r.UnRegister()
+		//退出时先摘除
+		deregister := signalContext.AddDeregister(func() {
+			if err := r.UnRegister(); err != nil {
+				signalContext.Warn("UnRegister:", err)
+			}
+		})
+		defer deregister()
 	}
 
-	go func() {
-		signalContext.WgAdd()
-		defer signalContext.WgDone()
-		select {
-		case <-signalContext.Ctx.Done():
-			signalContext.Info("grpc server stoping...")
-			defer signalContext.Info("grpc server stoped")
+	//等处理中的请求结束，超时后强制关闭
+	signalContext.AddDrainer("grpc server", func(ctx context.Context) error {
+		signalContext.Info("grpc server stoping...")
+		defer signalContext.Info("grpc server stoped")
+		stopped := make(chan struct{})
+		go func() {
 			s.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			s.Stop()
+			return ctx.Err()
 		}
-	}()
+	})
 
 	logger.Mix("Listen on grpc:", grpcListener.Addr().String())
 
@@ -87,93 +98,80 @@ func UnaryServerInterceptor(
 	ctx context.Context, req interface{},
 	info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
 ) (resp interface{}, err error) {
+	return NewUnaryServerInterceptor(DefaultMiddlewares()...)(ctx, req, info, handler)
+}
 
-	httpCtx := NewHTTPContextWithGrpcIncomingCtx(ctx)
-	defer httpCtx.Cancel()
-	httpCtx.AppendPrefix("Path:" + info.FullMethod)
-
-	httpCtx.Debug("Req:", req)
-	defer func() {
-		if err == nil {
-			httpCtx.Debug("Res:", resp)
-		} else if status.Code(err) == codes.Canceled {
-			httpCtx.Warn("Err:", err)
-		} else {
-			httpCtx.Warn("Req:", req, "Err:", err)
-		}
-	}()
-
-	startTime := time.Now()
-	prometheus.RequestsTotal(info.FullMethod, "GRPC")
-	defer func() {
-		costTime := time.Since(startTime)
-		httpCtx.Mixf("Method:%s CostTime:%s", "GRPC", costTime)
-		prometheus.RequestsCosttime(info.FullMethod, "GRPC", costTime)
-	}()
-
-	onlineNum := atomic.AddUint32(&online, 1)
-	httpCtx.Mixf("Online:%d", onlineNum)
-	defer func() {
-		atomic.AddUint32(&online, ^uint32(0))
-		if e := recover(); e != nil {
-			if e == ErrStopRun {
-				return
+//NewUnaryServerInterceptor 用中间件组装grpc的UnaryServerInterceptor
+//中间件里httpCtx.Request是nil，中止请求的原因会作为err返回
+func NewUnaryServerInterceptor(mws ...Middleware) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{},
+		info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+	) (resp interface{}, err error) {
+
+		httpCtx := NewHTTPContextWithGrpcIncomingCtx(ctx)
+		defer httpCtx.Cancel()
+		httpCtx.AppendPrefix("Path:" + info.FullMethod)
+		httpCtx.Path, httpCtx.Route, httpCtx.method = info.FullMethod, info.FullMethod, "GRPC"
+		defer startGrpcSpan(httpCtx, info.FullMethod)(&err)
+
+		httpCtx.Debug("Req:", req)
+		defer func() {
+			if err == nil {
+				httpCtx.Debug("Res:", resp)
+			} else if status.Code(err) == codes.Canceled {
+				httpCtx.Warn("Err:", err)
+			} else {
+				httpCtx.Warn("Req:", req, "Err:", err)
 			}
-			err = errors.New("panic")
-			httpCtx.Fatal(err, string(common.GetStack()))
+		}()
+
+		runMiddlewares(httpCtx, mws, func() {
+			resp, err = handler(httpCtx, req)
+			httpCtx.setGrpcAccessInfo(req, resp, err)
+		})
+		if err == nil && httpCtx.abortErr != nil {
+			resp, err = nil, httpCtx.abortErr
 		}
-	}()
 
-	err = checkConcurrence(onlineNum)
-	if err != nil {
 		return
 	}
-
-	return handler(httpCtx, req)
 }
 
 func StreamServerInterceptor(
 	srv interface{}, ss grpc.ServerStream,
 	info *grpc.StreamServerInfo, handler grpc.StreamHandler,
 ) (err error) {
+	return NewStreamServerInterceptor(DefaultMiddlewares()...)(srv, ss, info, handler)
+}
 
-	httpCtx := NewHTTPContextWithGrpcIncomingCtx(ss.Context())
-	defer httpCtx.Cancel()
-	httpCtx.AppendPrefix("Path:" + info.FullMethod)
-
-	defer func() {
-		if err != nil {
-			httpCtx.Warn("Err:", err)
-		}
-	}()
-
-	startTime := time.Now()
-	prometheus.RequestsTotal(info.FullMethod, "Stream")
-	defer func() {
-		costTime := time.Since(startTime)
-		httpCtx.Mixf("Method:%s CostTime:%s", "Stream", costTime)
-		prometheus.RequestsCosttime(info.FullMethod, "Stream", costTime)
-	}()
-
-	onlineNum := atomic.AddUint32(&online, 1)
-	httpCtx.Mixf("Online:%d", onlineNum)
-	defer func() {
-		atomic.AddUint32(&online, ^uint32(0))
-		if e := recover(); e != nil {
-			if e == ErrStopRun {
-				return
+//NewStreamServerInterceptor 用中间件组装grpc的StreamServerInterceptor
+func NewStreamServerInterceptor(mws ...Middleware) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream,
+		info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+	) (err error) {
+
+		httpCtx := NewHTTPContextWithGrpcIncomingCtx(ss.Context())
+		defer httpCtx.Cancel()
+		httpCtx.AppendPrefix("Path:" + info.FullMethod)
+		httpCtx.Path, httpCtx.Route, httpCtx.method = info.FullMethod, info.FullMethod, "Stream"
+		defer startGrpcSpan(httpCtx, info.FullMethod)(&err)
+
+		defer func() {
+			if err != nil {
+				httpCtx.Warn("Err:", err)
 			}
-			err = errors.New("panic")
-			httpCtx.Fatal(err, string(common.GetStack()))
+		}()
+
+		runMiddlewares(httpCtx, mws, func() {
+			err = handler(srv, WarpServerStream(ss, httpCtx))
+			httpCtx.access.err = err
+		})
+		if err == nil && httpCtx.abortErr != nil {
+			err = httpCtx.abortErr
 		}
-	}()
 
-	err = checkConcurrence(onlineNum)
-	if err != nil {
 		return
 	}
-
-	return handler(srv, WarpServerStream(ss, httpCtx))
 }
 
 func WarpServerStream(ss grpc.ServerStream, httpCtx *HTTPContext) *GrpcServerStream {
@@ -3,8 +3,13 @@
 package hfw
 
 import (
+	"bufio"
+	"context"
+	"fmt"
 	"net"
 	"net/http"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,6 +18,9 @@ import (
 	"github.com/hsyan2008/hfw/common"
 	"github.com/hsyan2008/hfw/configs"
 	"github.com/hsyan2008/hfw/grpc/discovery"
+	"github.com/hsyan2008/hfw/signal"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 var listener net.Listener
@@ -61,7 +69,16 @@ func newHTTPServer(config configs.HTTPServerConfig) (err error) {
 			}
 			readTimeout := config.ReadTimeout * time.Second
 			writeTimeout := config.WriteTimeout * time.Second
-			s = gracehttp.NewServer(addr, new(newMux), readTimeout, writeTimeout)
+			gs := gracehttp.NewServer(addr, new(newMux), readTimeout, writeTimeout)
+			if config.IsH2C && !isTLS(config) {
+				h2s := &http2.Server{}
+				//Shutdown时通知h2c连接退出
+				if err = http2.ConfigureServer(gs.Server, h2s); err != nil {
+					return err
+				}
+				gs.Handler = newH2CHandler(gs.Handler, h2s)
+			}
+			s = gs
 		}
 		if listener == nil {
 			listener, err = s.InitListener()
@@ -74,28 +91,143 @@ func newHTTPServer(config configs.HTTPServerConfig) (err error) {
 	return
 }
 
+func isTLS(config configs.HTTPServerConfig) bool {
+	return common.IsExist(config.CertFile) && common.IsExist(config.KeyFile)
+}
+
+//newH2CHandler 支持h2c的prior knowledge和Upgrade两种方式
+//转为HTTP/2后连接不再由http.Server管理，Shutdown只发送GOAWAY，不等待请求结束
+//所以退出时按请求等待，空闲的连接不影响退出
+func newH2CHandler(h http.Handler, h2s *http2.Server) http.Handler {
+	h2cHandler := h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 {
+			signalContext := signal.GetSignalContext()
+			signalContext.WgAdd()
+			defer signalContext.WgDone()
+		}
+		h.ServeHTTP(w, r)
+	}), h2s)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isH2CRequest(r) {
+			w = &h2cResponseWriter{w}
+		}
+		h2cHandler.ServeHTTP(w, r)
+	})
+}
+
+func isH2CRequest(r *http.Request) bool {
+	if r.Method == "PRI" && r.Proto == "HTTP/2.0" {
+		return true
+	}
+
+	return strings.EqualFold(r.Header.Get("Upgrade"), "h2c")
+}
+
+//h2cResponseWriter 转为HTTP/2连接后，清除ReadTimeout和WriteTimeout设置的超时
+//否则grpc的流和长连接会被断开
+type h2cResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *h2cResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := hj.Hijack()
+	if err == nil {
+		_ = conn.SetDeadline(time.Time{})
+	}
+
+	return conn, rw, err
+}
+
 func StartHTTP(config configs.HTTPServerConfig) (err error) {
 	err = newHTTPServer(config)
 	if err != nil {
 		return
 	}
 
+	signalContext := signal.GetSignalContext()
+
 	//注册服务
 	r, err := discovery.RegisterServer(config.ServerConfig, common.GetServerAddr(listener.Addr().String(), config.Address))
 	if err != nil {
 		return err
 	}
 	if r != nil {
-		defer r.UnRegister()
+		//退出时先摘除
+		deregister := signalContext.AddDeregister(func() {
+			if err := r.UnRegister(); err != nil {
+				signalContext.Warn("UnRegister:", err)
+			}
+		})
+		defer deregister()
 	}
 
-	if common.IsExist(config.CertFile) && common.IsExist(config.KeyFile) {
+	if isTLS(config) {
 		logger.Mix("Listen on https:", listener.Addr().String())
-		err = s.ListenAndServeTLS(config.CertFile, config.KeyFile)
+	} else if config.IsH2C {
+		logger.Mix("Listen on http(h2c):", listener.Addr().String())
 	} else {
 		logger.Mix("Listen on http:", listener.Addr().String())
+	}
+
+	if signalContext.ShutdownConfig().IsEnable {
+		err = serveWithShutdown(config)
+	} else if isTLS(config) {
+		err = s.ListenAndServeTLS(config.CertFile, config.KeyFile)
+	} else {
 		err = s.ListenAndServe()
 	}
 
 	return
 }
+
+//serveWithShutdown 不使用gracehttp的信号处理，由signal在PreStopDelay之后关闭，等请求处理完才取消Ctx
+//kill -TERM时先启动新进程，新进程继承监听的fd
+func serveWithShutdown(config configs.HTTPServerConfig) (err error) {
+	signalContext := signal.GetSignalContext()
+	signalContext.AddDrainer("http server", func(ctx context.Context) error {
+		if signalContext.IsRestart() {
+			if pid, err := restartHTTPServer(); err != nil {
+				signalContext.Warn("start new process failed:", err)
+			} else {
+				signalContext.Mix("start new process, the new pid is", pid)
+			}
+		}
+		signalContext.Mix("http server stoping...")
+		defer signalContext.Mix("http server stoped")
+		//停止接收请求，并等待处理中的请求结束
+		return s.Shutdown(ctx)
+	})
+
+	if isTLS(config) {
+		return s.Server.ServeTLS(listener, config.CertFile, config.KeyFile)
+	}
+
+	return s.Server.Serve(listener)
+}
+
+//fd 3是监听的socket，新进程里gracehttp.NewServer按环境变量使用它
+func restartHTTPServer() (pid int, err error) {
+	tl, ok := listener.(*net.TCPListener)
+	if !ok {
+		return 0, fmt.Errorf("listener %T is not *net.TCPListener", listener)
+	}
+	f, err := tl.File()
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	env := []string{gracehttp.GRACEFUL_ENVIRON_STRING}
+	for _, v := range os.Environ() {
+		if v != gracehttp.GRACEFUL_ENVIRON_STRING {
+			env = append(env, v)
+		}
+	}
+
+	return signal.StartNewProcess(env, f)
+}
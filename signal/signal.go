@@ -3,18 +3,28 @@
 //kill -TERM pid 重启
 //需要调用Wg.Add()
 //需要监听Shutdown通道
+//退出分为几个阶段：标记未就绪并从注册中心摘除、等待PreStopDelay、停止接收请求并等待处理中的请求结束、
+//取消Ctx通知业务方并等待Wg、按顺序执行清理函数
 package signal
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/hsyan2008/go-logger"
 	"github.com/hsyan2008/hfw/common"
+	"github.com/hsyan2008/hfw/configs"
+)
+
+const (
+	defaultShutdownTimeout = 30 * time.Second
+	defaultHookTimeout     = 5 * time.Second
 )
 
 type signalContext struct {
@@ -22,11 +32,25 @@ type signalContext struct {
 	//Wg 业务方调用此变量注册工作
 	Wg *sync.WaitGroup `json:"-"`
 	//done 业务方调用Shutdowned函数获取所有任务已经退出的通知
-	done chan bool
+	done     chan bool
+	doneOnce sync.Once
+	//PreStopDelay结束，开始计算Timeout
+	draining chan struct{}
 
 	mu    *sync.Mutex
 	doing bool
 
+	//未就绪时健康检查应该返回失败
+	notReady int32
+	//收到kill -TERM
+	restart int32
+
+	hookMu      sync.Mutex
+	deregisters []func()
+	drainers    []shutdownHook
+	hooks       []shutdownHook
+	conf        *configs.ShutdownConfig
+
 	//Shutdown 业务方手动监听此通道获知通知
 	Ctx    context.Context    `json:"-"`
 	Cancel context.CancelFunc `json:"-"`
@@ -39,14 +63,21 @@ type signalContext struct {
 var scx *signalContext
 
 func init() {
-	scx = &signalContext{
-		Wg:   new(sync.WaitGroup),
-		done: make(chan bool),
-		mu:   new(sync.Mutex),
+	scx = newSignalContext()
+}
+
+func newSignalContext() *signalContext {
+	ctx := &signalContext{
+		Wg:       new(sync.WaitGroup),
+		done:     make(chan bool),
+		draining: make(chan struct{}),
+		mu:       new(sync.Mutex),
 	}
-	scx.Logger = logger.NewLogger()
-	scx.Logger.SetTraceID("PRIME")
-	scx.Ctx, scx.Cancel = context.WithCancel(context.Background())
+	ctx.Logger = logger.NewLogger()
+	ctx.Logger.SetTraceID("PRIME")
+	ctx.Ctx, ctx.Cancel = context.WithCancel(context.Background())
+
+	return ctx
 }
 
 //GetSignalContext 一般用于其他包或者非http程序
@@ -85,21 +116,19 @@ func (ctx *signalContext) Listen() {
 	case s = <-c:
 		ctx.Mix("recv signal:", s)
 	}
+	if s == syscall.SIGTERM {
+		atomic.StoreInt32(&ctx.restart, 1)
+	}
 
 	go ctx.doShutdownDone()
 	if ctx.IsHTTP {
 		ctx.Mix("Stopping http server")
-		//已有第三方处理
+		//已有第三方处理，开启Shutdown.IsEnable时由StartHTTP关闭和重启
 	} else {
 		ctx.Mix("Stopping console server")
 		switch s {
 		case syscall.SIGTERM:
-			execSpec := &syscall.ProcAttr{
-				Env:   os.Environ(),
-				Files: []uintptr{os.Stdin.Fd(), os.Stdout.Fd(), os.Stderr.Fd()},
-			}
-			_, _, err := syscall.StartProcess(os.Args[0], os.Args, execSpec)
-			if err != nil {
+			if _, err := StartNewProcess(os.Environ()); err != nil {
 				ctx.Errorf("failed to forkexec: %v", err)
 			}
 		case syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGINT:
@@ -107,6 +136,21 @@ func (ctx *signalContext) Listen() {
 	}
 }
 
+//StartNewProcess 用相同的参数启动新进程，files依次作为新进程的fd 3、4...
+func StartNewProcess(env []string, files ...*os.File) (pid int, err error) {
+	fds := []uintptr{os.Stdin.Fd(), os.Stdout.Fd(), os.Stderr.Fd()}
+	for _, f := range files {
+		fds = append(fds, f.Fd())
+	}
+	execSpec := &syscall.ProcAttr{
+		Env:   env,
+		Files: fds,
+	}
+	pid, _, err = syscall.StartProcess(os.Args[0], os.Args, execSpec)
+
+	return
+}
+
 func (ctx *signalContext) doShutdownDone() {
 	ctx.mu.Lock()
 	defer ctx.mu.Unlock()
@@ -120,26 +164,62 @@ func (ctx *signalContext) doShutdownDone() {
 
 	go ctx.waitDone()
 
-	timeout := 30
+	//PreStopDelay不计入超时时间
 	select {
-	case <-time.After(time.Duration(timeout) * time.Second):
-		ctx.Warnf("doShutdownDone %ds timeout", timeout)
-		close(ctx.done)
+	case <-ctx.draining:
+	case <-ctx.done:
+		return
+	}
+	timeout := ctx.ShutdownConfig().Timeout
+	select {
+	case <-time.After(timeout):
+		ctx.Warnf("doShutdownDone %s timeout", timeout)
+		//还没有退出的业务方也要收到通知
+		ctx.Cancel()
+		ctx.closeDone()
 	case <-ctx.done:
 	}
 }
 
-//通知业务方，并等待业务方结束
+//按阶段退出，并等待业务方结束
 func (ctx *signalContext) waitDone() {
+	conf := ctx.ShutdownConfig()
+
+	//健康检查失败，并从注册中心摘除
+	ctx.Mix("signal ctx mark not ready")
+	atomic.StoreInt32(&ctx.notReady, 1)
+	ctx.runDeregisters()
+	if conf.IsEnable && conf.PreStopDelay > 0 {
+		ctx.Mixf("signal ctx wait %s before stop", conf.PreStopDelay)
+		time.Sleep(conf.PreStopDelay)
+	}
+	close(ctx.draining)
+
+	//停止接收请求，等待处理中的请求结束，这时Ctx还没有取消
+	ctx.runDrainers(conf.Timeout)
+
 	//context包来取消，以通知业务方
 	ctx.Mix("signal ctx cancel")
 	ctx.Cancel()
 	//等待业务方完成退出
 	ctx.Mix("signal ctx waitgroup wait done start")
 	ctx.WgWait()
-	//表示全部完成
 	ctx.Mix("signal ctx waitgroup wait done end")
-	close(ctx.done)
+
+	ctx.runHooks(conf.HookTimeout)
+	//表示全部完成
+	ctx.closeDone()
+}
+
+func (ctx *signalContext) closeDone() {
+	ctx.doneOnce.Do(func() {
+		close(ctx.done)
+	})
+}
+
+//IsRestart 退出是否因为kill -TERM，需要启动新进程
+func (ctx *signalContext) IsRestart() bool {
+	return atomic.LoadInt32(&ctx.restart) == 1
 }
 
 //Shutdowned 获取是否已经全部结束，暂时只有run.go里用到
@@ -159,3 +239,142 @@ func (ctx *signalContext) WgDone() {
 func (ctx *signalContext) WgWait() {
 	ctx.Wg.Wait()
 }
+
+//SetShutdownConfig 设置退出的配置，不设置则使用configs.Config.Shutdown
+func (ctx *signalContext) SetShutdownConfig(conf configs.ShutdownConfig) {
+	if conf.Timeout <= 0 {
+		conf.Timeout = defaultShutdownTimeout
+	}
+	if conf.HookTimeout <= 0 {
+		conf.HookTimeout = defaultHookTimeout
+	}
+	ctx.hookMu.Lock()
+	ctx.conf = &conf
+	ctx.hookMu.Unlock()
+}
+
+//ShutdownConfig ..
+func (ctx *signalContext) ShutdownConfig() configs.ShutdownConfig {
+	ctx.hookMu.Lock()
+	conf := ctx.conf
+	ctx.hookMu.Unlock()
+	if conf == nil {
+		ctx.SetShutdownConfig(configs.Config.Shutdown)
+		return ctx.ShutdownConfig()
+	}
+
+	return *conf
+}
+
+//IsReady 开始退出后返回false，用于健康检查
+func (ctx *signalContext) IsReady() bool {
+	return atomic.LoadInt32(&ctx.notReady) == 0
+}
+
+//AddDeregister 退出的第一阶段执行f，用于从注册中心摘除
+//返回的函数可以提前执行f，f只会执行一次
+func (ctx *signalContext) AddDeregister(f func()) (deregister func()) {
+	once := new(sync.Once)
+	deregister = func() {
+		once.Do(f)
+	}
+	ctx.hookMu.Lock()
+	ctx.deregisters = append(ctx.deregisters, deregister)
+	ctx.hookMu.Unlock()
+
+	return deregister
+}
+
+func (ctx *signalContext) runDeregisters() {
+	ctx.hookMu.Lock()
+	deregisters := ctx.deregisters
+	ctx.hookMu.Unlock()
+	for _, f := range deregisters {
+		f()
+	}
+}
+
+//AddDrainer PreStopDelay之后并发执行f，用于停止接收请求并等待处理中的请求结束，如http.Server.Shutdown
+//都返回后才取消Ctx，ctx在Shutdown.Timeout后超时
+func (ctx *signalContext) AddDrainer(name string, f func(context.Context) error) {
+	ctx.hookMu.Lock()
+	ctx.drainers = append(ctx.drainers, shutdownHook{name: name, f: f})
+	ctx.hookMu.Unlock()
+}
+
+func (ctx *signalContext) runDrainers(timeout time.Duration) {
+	ctx.hookMu.Lock()
+	drainers := ctx.drainers
+	ctx.hookMu.Unlock()
+	if len(drainers) == 0 {
+		return
+	}
+
+	c, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	wg := new(sync.WaitGroup)
+	for _, h := range drainers {
+		wg.Add(1)
+		go func(h shutdownHook) {
+			defer wg.Done()
+			ctx.Mix("shutdown drain start:", h.name)
+			if err := ctx.callHook(c, h); err != nil {
+				ctx.Warnf("shutdown drain %s error: %v", h.name, err)
+			}
+		}(h)
+	}
+	wg.Wait()
+}
+
+type shutdownHook struct {
+	name    string
+	timeout time.Duration
+	f       func(context.Context) error
+}
+
+//AddShutdownHook 业务方都退出后，按添加的顺序执行清理函数，如关闭数据库连接、刷新日志
+//timeout为0时使用Shutdown.HookTimeout，超时后不再等待，继续执行下一个
+func (ctx *signalContext) AddShutdownHook(name string, timeout time.Duration, f func(context.Context) error) {
+	ctx.hookMu.Lock()
+	ctx.hooks = append(ctx.hooks, shutdownHook{name: name, timeout: timeout, f: f})
+	ctx.hookMu.Unlock()
+}
+
+func (ctx *signalContext) runHooks(defaultTimeout time.Duration) {
+	ctx.hookMu.Lock()
+	hooks := ctx.hooks
+	ctx.hookMu.Unlock()
+	for _, h := range hooks {
+		timeout := h.timeout
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+		ctx.Mix("shutdown hook start:", h.name)
+		c, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := ctx.callHook(c, h); c.Err() != nil {
+			ctx.Warnf("shutdown hook %s %s timeout", h.name, timeout)
+		} else if err != nil {
+			ctx.Warnf("shutdown hook %s error: %v", h.name, err)
+		}
+		cancel()
+	}
+}
+
+//c超时后不再等待f
+func (ctx *signalContext) callHook(c context.Context, h shutdownHook) error {
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if e := recover(); e != nil {
+				errCh <- fmt.Errorf("panic: %v", e)
+			}
+		}()
+		errCh <- h.f(c)
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-c.Done():
+		return c.Err()
+	}
+}
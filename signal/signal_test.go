@@ -0,0 +1,120 @@
+package signal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hsyan2008/hfw/configs"
+)
+
+type recorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *recorder) add(s string) {
+	r.mu.Lock()
+	r.calls = append(r.calls, s)
+	r.mu.Unlock()
+}
+
+func (r *recorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.calls, ",")
+}
+
+func TestShutdownPhases(t *testing.T) {
+	ctx := newSignalContext()
+	ctx.SetShutdownConfig(configs.ShutdownConfig{IsEnable: true, PreStopDelay: 10 * time.Millisecond})
+	r := new(recorder)
+
+	deregister := ctx.AddDeregister(func() { r.add("deregister") })
+	//提前执行的不会重复执行
+	deregister()
+	ctx.AddDrainer("server", func(c context.Context) error {
+		if ctx.IsReady() {
+			t.Error("should not be ready while draining")
+		}
+		//处理中的请求不能被取消
+		if ctx.Ctx.Err() != nil {
+			t.Error("ctx canceled before drain")
+		}
+		r.add("drain")
+		return nil
+	})
+	ctx.WgAdd()
+	go func() {
+		defer ctx.WgDone()
+		<-ctx.Ctx.Done()
+		r.add("worker")
+	}()
+	ctx.AddShutdownHook("first", 0, func(context.Context) error {
+		r.add("hook1")
+		return errors.New("ignored")
+	})
+	ctx.AddShutdownHook("panic", 0, func(context.Context) error {
+		panic("hook panic")
+	})
+	//超时后继续执行下一个
+	ctx.AddShutdownHook("slow", 10*time.Millisecond, func(context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	ctx.AddShutdownHook("last", 0, func(context.Context) error {
+		r.add("hook2")
+		return nil
+	})
+
+	ctx.Shutdowned()
+	if want := "deregister,drain,worker,hook1,hook2"; r.String() != want {
+		t.Fatalf("calls = %s, want %s", r, want)
+	}
+}
+
+//Timeout从PreStopDelay结束后开始计算
+func TestShutdownTimeoutAfterPreStop(t *testing.T) {
+	ctx := newSignalContext()
+	ctx.SetShutdownConfig(configs.ShutdownConfig{
+		IsEnable:     true,
+		PreStopDelay: 100 * time.Millisecond,
+		Timeout:      100 * time.Millisecond,
+	})
+	r := new(recorder)
+	ctx.AddDrainer("slow", func(c context.Context) error {
+		time.Sleep(50 * time.Millisecond)
+		r.add("drain")
+		return nil
+	})
+	ctx.AddShutdownHook("hook", 0, func(context.Context) error {
+		r.add("hook")
+		return nil
+	})
+
+	ctx.Shutdowned()
+	if want := "drain,hook"; r.String() != want {
+		t.Fatalf("calls = %s, want %s", r, want)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	ctx := newSignalContext()
+	ctx.SetShutdownConfig(configs.ShutdownConfig{Timeout: 50 * time.Millisecond})
+	ctx.AddDrainer("blocked", func(c context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	start := time.Now()
+	ctx.Shutdowned()
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("shutdown took %s", d)
+	}
+	if ctx.Ctx.Err() == nil {
+		t.Fatal("ctx should be canceled after timeout")
+	}
+}
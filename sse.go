@@ -0,0 +1,199 @@
+package hfw
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hsyan2008/hfw/configs"
+	"github.com/hsyan2008/hfw/encoding"
+)
+
+//SSEHeartbeat 没有事件时发送心跳的间隔，防止被代理断开，0表示不发送
+var SSEHeartbeat = 15 * time.Second
+
+//ErrSSEClosed 流已经关闭
+var ErrSSEClosed = errors.New("sse stream closed")
+
+//SSEvent Server-Sent Events的一个事件
+type SSEvent struct {
+	ID    string
+	Event string
+	//客户端断开后重连的间隔
+	Retry time.Duration
+	//string和[]byte原样发送，其他的转为json
+	Data interface{}
+}
+
+//SSEStream 见HTTPContext.SSE
+type SSEStream struct {
+	httpCtx *HTTPContext
+	flusher http.Flusher
+
+	mu     sync.Mutex
+	closed bool
+	done   chan struct{}
+}
+
+//SSE 开始Server-Sent Events流，之后不再执行Finish里的渲染，也不压缩
+//httpCtx.Ctx被取消(客户端断开或者服务关闭)后，Send返回错误，可以通过Done判断
+//如
+//
+//	stream, err := httpCtx.SSE()
+//	httpCtx.ThrowCheck(500, err)
+//	defer stream.Close()
+//	for v := range ch {
+//		if err = stream.Send(hfw.SSEvent{Event: "progress", Data: v}); err != nil {
+//			return
+//		}
+//	}
+func (httpCtx *HTTPContext) SSE() (stream *SSEStream, err error) {
+	flusher, ok := httpCtx.ResponseWriter.(http.Flusher)
+	if !ok {
+		return nil, errors.New("webserver doesn't support flushing")
+	}
+
+	httpCtx.isStreaming = true
+	httpCtx.IsZip = false
+	//流式输出不受Server.HandlerTimeout限制，路由上设置的超时仍然有效
+	if httpCtx.isDefaultTimeout {
+		httpCtx.SetTimeout(0)
+	}
+
+	header := httpCtx.ResponseWriter.Header()
+	header.Del("Content-Length")
+	header.Del("Content-Encoding")
+	header.Set("Content-Type", "text/event-stream; charset=utf-8")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	//nginx不缓冲
+	header.Set("X-Accel-Buffering", "no")
+	header.Set("Trace-Id", httpCtx.GetTraceID())
+	//Finish里不再处理，需要在输出前写入session的cookie
+	if (configs.Config.EnableSession || configs.Config.Session.IsEnable) && httpCtx.Session != nil {
+		httpCtx.Session.Close(httpCtx.Request, httpCtx.ResponseWriter)
+	}
+	httpCtx.ResponseWriter.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	stream = &SSEStream{
+		httpCtx: httpCtx,
+		flusher: flusher,
+		done:    make(chan struct{}),
+	}
+	//不依赖心跳，SSEHeartbeat为0时Done也能在断开时关闭
+	go stream.watch()
+	if SSEHeartbeat > 0 {
+		go stream.heartbeat()
+	}
+
+	return
+}
+
+func (stream *SSEStream) watch() {
+	select {
+	case <-stream.httpCtx.Ctx.Done():
+		stream.Close()
+	case <-stream.done:
+	}
+}
+
+func (stream *SSEStream) heartbeat() {
+	ticker := time.NewTicker(SSEHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stream.done:
+			return
+		case <-ticker.C:
+			//冒号开头的是注释，客户端会忽略
+			if err := stream.write(": ping\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+//Send 发送事件
+func (stream *SSEStream) Send(ev SSEvent) (err error) {
+	var b strings.Builder
+	if ev.ID != "" {
+		b.WriteString("id: " + singleLine(ev.ID) + "\n")
+	}
+	if ev.Event != "" {
+		b.WriteString("event: " + singleLine(ev.Event) + "\n")
+	}
+	if ev.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(ev.Retry.Milliseconds(), 10) + "\n")
+	}
+
+	var data string
+	switch v := ev.Data.(type) {
+	case nil:
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		var buf []byte
+		buf, err = encoding.JSON.Marshal(v)
+		if err != nil {
+			return
+		}
+		data = string(buf)
+	}
+	//多行数据每行都要有data:
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+
+	return stream.write(b.String())
+}
+
+//SendData 只发送数据
+func (stream *SSEStream) SendData(data interface{}) error {
+	return stream.Send(SSEvent{Data: data})
+}
+
+func (stream *SSEStream) write(s string) (err error) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if stream.closed {
+		return ErrSSEClosed
+	}
+	if err = stream.httpCtx.Ctx.Err(); err != nil {
+		return
+	}
+	_, err = fmt.Fprint(stream.httpCtx.ResponseWriter, s)
+	if err != nil {
+		return
+	}
+	stream.flusher.Flush()
+
+	return
+}
+
+//Done 流关闭或者httpCtx.Ctx被取消
+func (stream *SSEStream) Done() <-chan struct{} {
+	return stream.done
+}
+
+//Close 停止心跳，之后Send返回ErrSSEClosed
+func (stream *SSEStream) Close() {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if stream.closed {
+		return
+	}
+	stream.closed = true
+	close(stream.done)
+}
+
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
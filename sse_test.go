@@ -0,0 +1,38 @@
+package hfw
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSEDone(t *testing.T) {
+	old := SSEHeartbeat
+	SSEHeartbeat = 0
+	defer func() { SSEHeartbeat = old }()
+
+	w := httptest.NewRecorder()
+	httpCtx := initCtx(w, httptest.NewRequest("GET", "/test_sse", nil))
+	stream, err := httpCtx.SSE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = stream.Send(SSEvent{Event: "msg", Data: "a\nb"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "event: msg\ndata: a\ndata: b\n\n"; w.Body.String() != want {
+		t.Fatalf("body = %q, want %q", w.Body.String(), want)
+	}
+
+	//没有心跳时，取消后Done也要关闭
+	httpCtx.Cancel()
+	select {
+	case <-stream.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done not closed after cancel")
+	}
+	if err = stream.SendData("c"); err != ErrSSEClosed || strings.Contains(w.Body.String(), "c") {
+		t.Fatalf("Send after cancel = %v", err)
+	}
+}
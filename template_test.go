@@ -0,0 +1,267 @@
+package hfw
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var testTemplates = map[string]string{
+	"layout.html": `<html>{{block "title" .}}default{{end}}|{{block "content" .}}{{end}}</html>`,
+	"other.html":  `<div>{{block "content" .}}{{end}}</div>`,
+	"page1.html":  `{{define "title"}}T1{{end}}{{define "content"}}C1 {{.Data.name}}{{end}}`,
+	"page2.html":  `plain {{.Data.name}}`,
+}
+
+// 模板写入临时目录，设置为HTMLPath
+func setupTemplates(t *testing.T, files map[string]string) (dir string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "hfw-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := Config.Template
+	Config.Template.HTMLPath = dir
+	clearTemplatesCache()
+
+	return dir, func() {
+		Config.Template = old
+		clearTemplatesCache()
+		os.RemoveAll(dir)
+	}
+}
+
+func renderTest(layout, templateFile string) string {
+	w := httptest.NewRecorder()
+	httpCtx := initCtx(w, httptest.NewRequest("GET", "/", nil))
+	defer httpCtx.Cancel()
+	httpCtx.Layout = layout
+	httpCtx.TemplateFile = templateFile
+	httpCtx.Data["name"] = "hfw"
+	httpCtx.Render()
+
+	return w.Body.String()
+}
+
+type testLayoutCtl struct {
+	Controller
+}
+
+func (ctl *testLayoutCtl) DefaultLayout() string {
+	return "layout.html"
+}
+
+func (ctl *testLayoutCtl) Index(httpCtx *HTTPContext) {
+	httpCtx.Data["name"] = "ctl"
+	httpCtx.TemplateFile = "page1.html"
+}
+
+func (ctl *testLayoutCtl) NoLayout(httpCtx *HTTPContext) {
+	httpCtx.Data["name"] = "ctl"
+	httpCtx.Layout = ""
+	httpCtx.TemplateFile = "page2.html"
+}
+
+func TestLayout(t *testing.T) {
+	_, cleanup := setupTemplates(t, testTemplates)
+	defer cleanup()
+
+	cases := []struct {
+		layout, file, want string
+	}{
+		//页面define的block覆盖布局的
+		{"layout.html", "page1.html", "<html>T1|C1 hfw</html>"},
+		//页面没有define content的，页面本身作为content
+		{"layout.html", "page2.html", "<html>default|plain hfw</html>"},
+		{"other.html", "page2.html", "<div>plain hfw</div>"},
+		{"", "page2.html", "plain hfw"},
+	}
+	for _, c := range cases {
+		if got := renderTest(c.layout, c.file); got != c.want {
+			t.Fatalf("%s %s = %q, want %q", c.layout, c.file, got, c.want)
+		}
+	}
+
+	//RenderToString不使用布局
+	httpCtx := initCtx(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	defer httpCtx.Cancel()
+	httpCtx.Layout = "layout.html"
+	s, err := httpCtx.RenderToString("page2.html", map[string]string{})
+	if err != nil || s != "plain " {
+		t.Fatalf("RenderToString = %q %v", s, err)
+	}
+}
+
+func TestControllerLayout(t *testing.T) {
+	_, cleanup := setupTemplates(t, testTemplates)
+	defer cleanup()
+	_ = Handler("/test_layout", &testLayoutCtl{})
+
+	accept := http.Header{"Accept": {"text/html"}}
+	for path, want := range map[string]string{
+		"/test_layout/index":    "<html>T1|C1 ctl</html>",
+		"/test_layout/nolayout": "plain ctl",
+	} {
+		if w := serveTest("GET", path, accept); w.Body.String() != want {
+			t.Fatalf("%s = %d %q, want %q", path, w.Code, w.Body.String(), want)
+		}
+	}
+}
+
+func TestTemplateCacheKey(t *testing.T) {
+	_, cleanup := setupTemplates(t, testTemplates)
+	defer cleanup()
+	Config.Template.IsCache = true
+
+	//同一个页面用不同的布局，分别缓存
+	for i := 0; i < 2; i++ {
+		if got := renderTest("layout.html", "page2.html"); got != "<html>default|plain hfw</html>" {
+			t.Fatalf("layout = %q", got)
+		}
+		if got := renderTest("other.html", "page2.html"); got != "<div>plain hfw</div>" {
+			t.Fatalf("other layout = %q", got)
+		}
+		if got := renderTest("", "page2.html"); got != "plain hfw" {
+			t.Fatalf("no layout = %q", got)
+		}
+	}
+	templatesCache.l.RLock()
+	defer templatesCache.l.RUnlock()
+	for _, key := range []string{"layout.html:page2.html", "other.html:page2.html", "page2.html"} {
+		if _, ok := templatesCache.list[key]; !ok {
+			t.Fatalf("cache %s not found in %v", key, templatesCache.list)
+		}
+	}
+}
+
+func TestInvalidateTemplate(t *testing.T) {
+	dir, cleanup := setupTemplates(t, testTemplates)
+	defer cleanup()
+	Config.Template.IsCache = true
+
+	renderTest("layout.html", "page2.html")
+	renderTest("", "page2.html")
+	renderTest("layout.html", "page1.html")
+	//依赖布局和页面文件
+	templatesCache.l.RLock()
+	deps := templatesCache.deps["layout.html:page2.html"]
+	templatesCache.l.RUnlock()
+	if len(deps) != 2 || deps[0] != filepath.Join(dir, "layout.html") || deps[1] != filepath.Join(dir, "page2.html") {
+		t.Fatalf("deps = %v", deps)
+	}
+
+	page2 := filepath.Join(dir, "page2.html")
+	if err := ioutil.WriteFile(page2, []byte(`new {{.Data.name}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := renderTest("", "page2.html"); got != "plain hfw" {
+		t.Fatalf("cached = %q", got)
+	}
+	//只删除用到该文件的缓存
+	invalidateTemplate(page2)
+	templatesCache.l.RLock()
+	_, ok := templatesCache.list["layout.html:page1.html"]
+	n := len(templatesCache.list)
+	templatesCache.l.RUnlock()
+	if !ok || n != 1 {
+		t.Fatalf("cache after invalidate = %d %v", n, ok)
+	}
+	if got := renderTest("layout.html", "page2.html"); got != "<html>default|new hfw</html>" {
+		t.Fatalf("after invalidate = %q", got)
+	}
+
+	//布局变化的，用到该布局的都删除
+	invalidateTemplate(filepath.Join(dir, "layout.html"))
+	templatesCache.l.RLock()
+	n = len(templatesCache.list)
+	templatesCache.l.RUnlock()
+	if n != 0 {
+		t.Fatalf("cache after layout invalidate = %d", n)
+	}
+
+	//widgets变化的清空缓存
+	if err := os.Mkdir(filepath.Join(dir, "widgets"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "widgets", "a.html"), []byte(`{{define "a"}}{{end}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	Config.Template.WidgetsPath = filepath.Join(dir, "widgets", "*.html")
+	renderTest("", "page1.html")
+	invalidateTemplate(filepath.Join(dir, "widgets", "a.html"))
+	templatesCache.l.RLock()
+	n = len(templatesCache.list)
+	templatesCache.l.RUnlock()
+	if n != 0 {
+		t.Fatalf("cache after widgets invalidate = %d", n)
+	}
+}
+
+func TestAddTemplateWatch(t *testing.T) {
+	dir, cleanup := setupTemplates(t, nil)
+	defer cleanup()
+	for _, sub := range []string{"sub", ".git"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+	if err = addTemplateWatch(watcher, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	//隐藏目录不监听，子目录监听
+	for _, name := range []string{".git/a.html", "sub/a.html"} {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte("a"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case event := <-watcher.Events:
+		if event.Name != filepath.Join(dir, "sub", "a.html") {
+			t.Fatalf("event = %v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event for sub/a.html")
+	}
+}
+
+func TestSetTemplateFS(t *testing.T) {
+	_, cleanup := setupTemplates(t, testTemplates)
+	defer cleanup()
+	defer SetTemplateFS(nil, "")
+	Config.Template.IsCache = true
+	if got := renderTest("layout.html", "page2.html"); got != "<html>default|plain hfw</html>" {
+		t.Fatalf("dir = %q", got)
+	}
+
+	//切换后清空缓存，路径相对fsys的根目录
+	SetTemplateFS(fstest.MapFS{
+		"views/layout.html":   {Data: []byte(`<main>{{block "content" .}}{{end}}{{template "footer"}}</main>`)},
+		"views/page2.html":    {Data: []byte(`fs {{.Data.name}}`)},
+		"widgets/footer.html": {Data: []byte(`{{define "footer"}}|footer{{end}}`)},
+	}, "widgets/*.html")
+	if got := renderTest("/views/layout.html", "views/page2.html"); got != "<main>fs hfw|footer</main>" {
+		t.Fatalf("fs = %q", got)
+	}
+	httpCtx := initCtx(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	defer httpCtx.Cancel()
+	if _, err := httpCtx.RenderToString("page2.html", nil); err == nil {
+		t.Fatal("template not in fs should fail")
+	}
+}
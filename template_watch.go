@@ -0,0 +1,107 @@
+package hfw
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/hsyan2008/hfw/signal"
+)
+
+//监听HTMLPath和WidgetsPath所在目录，文件变化时删除用到该文件的模板缓存
+//widgets变化时清空所有缓存
+func watchTemplates() {
+	signalContext := signal.GetSignalContext()
+
+	signalContext.WgAdd()
+	defer signalContext.WgDone()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		signalContext.Warn("watch templates failed:", err)
+		return
+	}
+	defer watcher.Close()
+
+	var dirs []string
+	if Config.Template.HTMLPath != "" {
+		dirs = append(dirs, Config.Template.HTMLPath)
+	}
+	if Config.Template.WidgetsPath != "" {
+		dirs = append(dirs, filepath.Dir(Config.Template.WidgetsPath))
+	}
+	for _, dir := range dirs {
+		if err = addTemplateWatch(watcher, dir); err != nil {
+			signalContext.Warn("watch templates failed:", err)
+			return
+		}
+	}
+	signalContext.Info("watch templates:", dirs)
+
+	for {
+		select {
+		case <-signalContext.Ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if strings.HasPrefix(filepath.Base(event.Name), ".") {
+				continue
+			}
+			//新建的目录也要监听
+			if event.Op&fsnotify.Create == fsnotify.Create {
+				if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() {
+					_ = addTemplateWatch(watcher, event.Name)
+				}
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+				invalidateTemplate(event.Name)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			signalContext.Warn("watch templates error:", err)
+		}
+	}
+}
+
+func addTemplateWatch(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != dir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
+
+//删除用到file的缓存
+func invalidateTemplate(file string) {
+	if Config.Template.WidgetsPath != "" {
+		if ok, _ := filepath.Match(Config.Template.WidgetsPath, file); ok {
+			clearTemplatesCache()
+			return
+		}
+	}
+
+	file, _ = filepath.Abs(file)
+	templatesCache.l.Lock()
+	defer templatesCache.l.Unlock()
+	for key, deps := range templatesCache.deps {
+		for _, dep := range deps {
+			if dep, _ = filepath.Abs(dep); dep == file {
+				delete(templatesCache.list, key)
+				delete(templatesCache.deps, key)
+				break
+			}
+		}
+	}
+}
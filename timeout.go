@@ -0,0 +1,226 @@
+package hfw
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//超时返回的错误码和错误
+var (
+	TimeoutErrNo int64 = 504
+	ErrTimeout         = errors.New("request timeout")
+)
+
+//Timeout 超时中间件，用于路由、分组或者controller，如Group("/export", Timeout(time.Minute))
+//后执行的覆盖先执行的，所以路由上的覆盖分组上的，分组上的覆盖Server.HandlerTimeout配置
+func Timeout(d time.Duration) Middleware {
+	return func(httpCtx *HTTPContext, next func()) {
+		httpCtx.SetTimeout(d)
+		next()
+	}
+}
+
+//SetTimeout 设置httpCtx.Ctx的超时时间，从现在开始计算，d<=0表示不限制
+//覆盖之前设置的，用httpCtx.Ctx的curl、api.Call、grpc的client.Do、db的WithContext都会被取消
+//超时后返回TimeoutErrNo，见CheckTimeout
+//在Before或者action之前设置的，超时后立即返回，不等action执行结束
+func (httpCtx *HTTPContext) SetTimeout(d time.Duration) {
+	httpCtx.isDefaultTimeout = false
+	if httpCtx.timeoutCancel != nil {
+		httpCtx.timeoutCancel()
+		httpCtx.timeoutCancel = nil
+	} else {
+		httpCtx.timeoutParent = httpCtx.Ctx
+	}
+	if d <= 0 {
+		httpCtx.Ctx = httpCtx.timeoutParent
+		return
+	}
+	httpCtx.Ctx, httpCtx.timeoutCancel = context.WithTimeout(httpCtx.timeoutParent, d)
+}
+
+//IsTimeout 是否已经超时
+func (httpCtx *HTTPContext) IsTimeout() bool {
+	return errors.Is(httpCtx.Ctx.Err(), context.DeadlineExceeded)
+}
+
+//CheckTimeout 已经超时则中止，用于耗时的循环里
+func (httpCtx *HTTPContext) CheckTimeout() {
+	if httpCtx.IsTimeout() {
+		httpCtx.ThrowCheck(TimeoutErrNo, ErrTimeout)
+	}
+}
+
+//业务逻辑执行完后，如果已经超时，不管结果如何都返回超时错误
+func (httpCtx *HTTPContext) checkTimeout() {
+	if httpCtx.hijacked || httpCtx.isStreaming || !httpCtx.IsTimeout() {
+		return
+	}
+	httpCtx.HTTPStatus = http.StatusGatewayTimeout
+	httpCtx.IsError = true
+	httpCtx.Results = nil
+	httpCtx.setErr(2, TimeoutErrNo, ErrTimeout)
+}
+
+//Server.HandlerTimeout配置的超时，只用于http请求
+func timeoutMiddleware(httpCtx *HTTPContext, next func()) {
+	if httpCtx.Request != nil && Config.Server.HandlerTimeout > 0 {
+		httpCtx.SetTimeout(Config.Server.HandlerTimeout)
+		httpCtx.isDefaultTimeout = true
+	}
+
+	next()
+}
+
+//f在新的goroutine里执行，超时后先返回TimeoutErrNo，之后的输出被丢弃
+//httpCtx.Ctx已经取消，用它的curl、db等会尽快返回，等f执行结束后才返回，中间件不会和f同时修改httpCtx
+func (httpCtx *HTTPContext) runWithTimeout(f func()) {
+	ctx := httpCtx.Ctx
+	tw := &timeoutWriter{
+		ResponseWriter: httpCtx.ResponseWriter,
+		header:         httpCtx.ResponseWriter.Header().Clone(),
+	}
+	//提前准备，超时的时候不读取f正在修改的httpCtx
+	timeoutCtx := &HTTPContext{
+		Ctx:            ctx,
+		HTTPStatus:     http.StatusGatewayTimeout,
+		ResponseWriter: tw.ResponseWriter,
+		Request:        httpCtx.Request,
+		IsJSON:         httpCtx.IsJSON,
+		Format:         httpCtx.Format,
+		Logger:         httpCtx.Logger,
+	}
+	httpCtx.ResponseWriter = tw
+
+	var p interface{}
+	done := make(chan struct{})
+	go func() {
+		defer func() {
+			p = recover()
+			close(done)
+		}()
+		f()
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		//SetTimeout修改了超时、客户端断开或者服务关闭的，等待f执行结束
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			tw.timeout(timeoutCtx)
+		}
+		<-done
+	}
+
+	httpCtx.ResponseWriter = tw.ResponseWriter
+	//交给recover中间件处理
+	if p != nil {
+		panic(p)
+	}
+}
+
+//timeoutWriter 超时后丢弃输出，响应头在输出时才写入，避免和超时的输出冲突
+type timeoutWriter struct {
+	http.ResponseWriter
+	header http.Header
+
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeader(status)
+}
+
+func (tw *timeoutWriter) writeHeader(status int) {
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	header := tw.ResponseWriter.Header()
+	for k := range header {
+		delete(header, k)
+	}
+	for k, v := range tw.header {
+		header[k] = v
+	}
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeader(http.StatusOK)
+
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.writeHeader(http.StatusOK)
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	hj, ok := tw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T doesn't support hijacking", tw.ResponseWriter)
+	}
+	//已经接管连接，超时不再输出
+	tw.wroteHeader = true
+
+	return hj.Hijack()
+}
+
+func (tw *timeoutWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := tw.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+
+	return http.ErrNotSupported
+}
+
+//已经开始输出的，如SSE，不再修改
+func (tw *timeoutWriter) timeout(httpCtx *HTTPContext) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.timedOut = true
+	if tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+
+	httpCtx.IsError = true
+	httpCtx.setErr(2, TimeoutErrNo, ErrTimeout)
+	httpCtx.RenderResponse()
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,129 @@
+package hfw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testTimeoutCtl struct {
+	Controller
+}
+
+//返回剩余的超时时间，单位秒
+func (ctl *testTimeoutCtl) Deadline(httpCtx *HTTPContext) {
+	dl, ok := httpCtx.Ctx.Deadline()
+	if !ok {
+		httpCtx.Results = "none"
+		return
+	}
+	httpCtx.Results = strconv.Itoa(int(time.Until(dl).Round(time.Second).Seconds()))
+}
+
+//不检查Ctx
+func (ctl *testTimeoutCtl) Slow(httpCtx *HTTPContext) {
+	time.Sleep(200 * time.Millisecond)
+	httpCtx.Results = "done"
+}
+
+func (ctl *testTimeoutCtl) Wait(httpCtx *HTTPContext) {
+	<-httpCtx.Ctx.Done()
+	httpCtx.Results = "done"
+}
+
+func (ctl *testTimeoutCtl) Stream(httpCtx *HTTPContext) {
+	stream, err := httpCtx.SSE()
+	httpCtx.ThrowCheck(500, err)
+	defer stream.Close()
+	time.Sleep(60 * time.Millisecond)
+	httpCtx.ThrowCheck(500, stream.SendData("ok"))
+}
+
+//记录开始输出的时间
+type timeoutRecorder struct {
+	*httptest.ResponseRecorder
+	wroteAt time.Time
+}
+
+func (w *timeoutRecorder) WriteHeader(code int) {
+	if w.wroteAt.IsZero() {
+		w.wroteAt = time.Now()
+	}
+	w.ResponseRecorder.WriteHeader(code)
+}
+
+func TestTimeoutPrecedence(t *testing.T) {
+	Config.Server.HandlerTimeout = 10 * time.Second
+	defer func() { Config.Server.HandlerTimeout = 0 }()
+
+	ctl := &testTimeoutCtl{}
+	Route("GET", "/test_timeout_global", ctl, "Deadline")
+	g := Group("/test_timeout", Timeout(20*time.Second))
+	g.Route("GET", "/group", ctl, "Deadline")
+	g.Route("GET", "/route", ctl, "Deadline", Timeout(30*time.Second))
+	g.Route("GET", "/none", ctl, "Deadline", Timeout(0))
+
+	for path, want := range map[string]string{
+		"/test_timeout_global": `"10"`,
+		"/test_timeout/group":  `"20"`,
+		"/test_timeout/route":  `"30"`,
+		"/test_timeout/none":   `"none"`,
+	} {
+		w := serveTest("GET", path, nil)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), want) {
+			t.Fatalf("%s = %d %q, want %s", path, w.Code, w.Body.String(), want)
+		}
+	}
+}
+
+func TestTimeoutResponse(t *testing.T) {
+	ctl := &testTimeoutCtl{}
+	Route("GET", "/test_timeout_slow", ctl, "Slow", Timeout(20*time.Millisecond))
+	Route("GET", "/test_timeout_wait", ctl, "Wait", Timeout(20*time.Millisecond))
+
+	//action不检查Ctx的，超时后立即返回，不等action执行结束
+	w := &timeoutRecorder{ResponseRecorder: httptest.NewRecorder()}
+	start := time.Now()
+	http.DefaultServeMux.ServeHTTP(w, httptest.NewRequest("GET", "/test_timeout_slow", nil))
+	if w.Code != http.StatusGatewayTimeout || !strings.Contains(w.Body.String(), `"err_no":504`) || strings.Contains(w.Body.String(), "done") {
+		t.Fatalf("slow = %d %q", w.Code, w.Body.String())
+	}
+	if d := w.wroteAt.Sub(start); d > 150*time.Millisecond {
+		t.Fatalf("slow responded after %s", d)
+	}
+
+	//action检查Ctx的，结果被替换为超时错误
+	rec := serveTest("GET", "/test_timeout_wait", nil)
+	if rec.Code != http.StatusGatewayTimeout || !strings.Contains(rec.Body.String(), `"err_no":504`) || strings.Contains(rec.Body.String(), "done") {
+		t.Fatalf("wait = %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestTimeoutStream(t *testing.T) {
+	old := SSEHeartbeat
+	SSEHeartbeat = 0
+	Config.Server.HandlerTimeout = 20 * time.Millisecond
+	defer func() {
+		SSEHeartbeat = old
+		Config.Server.HandlerTimeout = 0
+	}()
+
+	ctl := &testTimeoutCtl{}
+	Route("GET", "/test_timeout_stream", ctl, "Stream")
+	Route("GET", "/test_timeout_stream_route", ctl, "Stream", Timeout(20*time.Millisecond))
+
+	//Server.HandlerTimeout不限制SSE
+	w := serveTest("GET", "/test_timeout_stream", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "data: ok") {
+		t.Fatalf("stream = %d %q", w.Code, w.Body.String())
+	}
+
+	//路由上设置的超时仍然有效，已经开始输出的不再修改
+	w = serveTest("GET", "/test_timeout_stream_route", nil)
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "data: ok") || strings.Contains(w.Body.String(), "err_no") {
+		t.Fatalf("stream with route timeout = %d %q", w.Code, w.Body.String())
+	}
+}
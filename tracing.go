@@ -0,0 +1,51 @@
+package hfw
+
+import (
+	"github.com/hsyan2008/hfw/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+//链路里记录原来的trace_id，没有上游traceparent时两者一致
+const traceIDAttribute = attribute.Key("hfw.trace_id")
+
+//创建http请求的span，并替换httpCtx.Ctx，返回的函数在请求结束时调用
+func startHTTPSpan(httpCtx *HTTPContext) (end func()) {
+	if !tracing.IsEnable() {
+		return func() {}
+	}
+
+	ctx, span := tracing.StartHTTPServer(httpCtx.Ctx, httpCtx.Request, httpCtx.GetTraceID())
+	httpCtx.Ctx = ctx
+	if tracing.TraceID(ctx) != httpCtx.GetTraceID() {
+		span.SetAttributes(traceIDAttribute.String(httpCtx.GetTraceID()))
+	}
+
+	return func() {
+		if httpCtx.Route != "" {
+			span.SetName(httpCtx.Request.Method + " " + httpCtx.Route)
+		}
+		//HandlerFunc直接写ResponseWriter，拿不到状态码
+		var status int
+		if httpCtx.Controller != "" {
+			status = httpCtx.HTTPStatus
+		}
+		tracing.EndHTTP(span, status, httpCtx.abortErr)
+	}
+}
+
+//创建grpc请求的span，err是拦截器最终返回的
+func startGrpcSpan(httpCtx *HTTPContext, fullMethod string) (end func(err *error)) {
+	if !tracing.IsEnable() {
+		return func(*error) {}
+	}
+
+	ctx, span := tracing.StartGrpcServer(httpCtx.Ctx, fullMethod, httpCtx.GetTraceID())
+	httpCtx.Ctx = ctx
+	if tracing.TraceID(ctx) != httpCtx.GetTraceID() {
+		span.SetAttributes(traceIDAttribute.String(httpCtx.GetTraceID()))
+	}
+
+	return func(err *error) {
+		tracing.EndGrpc(span, *err)
+	}
+}
@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+//MetadataCarrier 用grpc的metadata传递traceparent
+type MetadataCarrier metadata.MD
+
+//Get ..
+func (c MetadataCarrier) Get(key string) string {
+	v := metadata.MD(c).Get(key)
+	if len(v) == 0 {
+		return ""
+	}
+
+	return v[0]
+}
+
+//Set ..
+func (c MetadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+//Keys ..
+func (c MetadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+//StartGrpcServer 从incoming metadata读取上游的链路，创建服务端span
+func StartGrpcServer(ctx context.Context, fullMethod, traceID string) (context.Context, trace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = Extract(ctx, MetadataCarrier(md), traceID)
+
+	return Start(ctx, strings.TrimPrefix(fullMethod, "/"),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(grpcAttributes(fullMethod)...))
+}
+
+//StartGrpcClient 创建客户端span，并把traceparent写入outgoing metadata
+func StartGrpcClient(ctx context.Context, fullMethod string) (context.Context, trace.Span) {
+	ctx, span := Start(ctx, strings.TrimPrefix(fullMethod, "/"),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(grpcAttributes(fullMethod)...))
+
+	//复制一份，不修改调用方的metadata
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	Inject(ctx, MetadataCarrier(md))
+
+	return metadata.NewOutgoingContext(ctx, md), span
+}
+
+//EndGrpc 记录grpc的状态码并结束span
+func EndGrpc(span trace.Span, err error) {
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(status.Code(err))))
+	End(span, err)
+}
+
+func grpcAttributes(fullMethod string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{semconv.RPCSystemKey.String("grpc")}
+	//格式是/package.service/method
+	name := strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		attrs = append(attrs, semconv.RPCServiceKey.String(name[:i]), semconv.RPCMethodKey.String(name[i+1:]))
+	}
+
+	return attrs
+}
@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//StartHTTPServer 从header读取上游的链路，创建服务端span，名称在结束时按路由修改
+func StartHTTPServer(ctx context.Context, r *http.Request, traceID string) (context.Context, trace.Span) {
+	ctx = Extract(ctx, propagation.HeaderCarrier(r.Header), traceID)
+
+	return Start(ctx, r.Method+" "+r.URL.Path,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("", "", r)...))
+}
+
+//StartHTTPClient 创建客户端span，并把traceparent写入r.Header
+func StartHTTPClient(ctx context.Context, r *http.Request) (context.Context, trace.Span) {
+	ctx, span := Start(ctx, "HTTP "+r.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPClientAttributesFromHTTPRequest(r)...))
+	Inject(ctx, propagation.HeaderCarrier(r.Header))
+
+	return ctx, span
+}
+
+//EndHTTP 记录状态码并结束span，status是0表示没有拿到响应
+func EndHTTP(span trace.Span, status int, err error) {
+	if status > 0 {
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+		if err == nil && status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,213 @@
+//Package tracing 基于OpenTelemetry的链路追踪，使用W3C的traceparent、tracestate传递
+//没有上游的traceparent时，使用原来的trace_id作为TraceID，日志里的trace_id和链路一致
+package tracing
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hsyan2008/hfw/common"
+	"github.com/hsyan2008/hfw/configs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//InstrumentationName tracer的名称
+const InstrumentationName = "github.com/hsyan2008/hfw"
+
+var (
+	isEnable bool
+	provider *sdktrace.TracerProvider
+)
+
+func init() {
+	//没有开启也按W3C格式传递，不影响上下游的链路
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+//Init 按配置初始化，退出前需要调用Shutdown把未导出的span发送出去
+func Init(conf configs.TracingConfig) (err error) {
+	if !conf.IsEnable {
+		return
+	}
+	if conf.ServiceName == "" {
+		conf.ServiceName = common.GetAppName()
+	}
+	if len(conf.Exporters) == 0 {
+		conf.Exporters = []string{"stdout"}
+	}
+	if conf.SampleRatio <= 0 || conf.SampleRatio > 1 {
+		conf.SampleRatio = 1
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceNameKey.String(conf.ServiceName),
+			semconv.ServiceVersionKey.String(common.GetVersion()),
+			semconv.DeploymentEnvironmentKey.String(common.GetEnv()),
+		)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.SampleRatio))),
+		sdktrace.WithIDGenerator(newIDGenerator()),
+	}
+	for _, name := range conf.Exporters {
+		exporter, err := newExporter(conf, name)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+
+	provider = sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	isEnable = true
+
+	return
+}
+
+func newExporter(conf configs.TracingConfig, name string) (sdktrace.SpanExporter, error) {
+	switch strings.ToLower(name) {
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "file":
+		if conf.File == "" {
+			return nil, errors.New("tracing: file exporter need File")
+		}
+		f, err := os.OpenFile(conf.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return stdouttrace.New(stdouttrace.WithWriter(f))
+	case "otlp":
+		opts := []otlptracehttp.Option{otlptracehttp.WithInsecure()}
+		if conf.OTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(conf.OTLPEndpoint))
+		}
+		return otlptracehttp.New(context.Background(), opts...)
+	}
+
+	return nil, fmt.Errorf("tracing: unsupported exporter %s", name)
+}
+
+//IsEnable 没有开启时不创建span
+func IsEnable() bool {
+	return isEnable
+}
+
+//Shutdown 导出剩余的span
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+
+	return provider.Shutdown(ctx)
+}
+
+//Tracer ..
+func Tracer() trace.Tracer {
+	return otel.Tracer(InstrumentationName)
+}
+
+//Start 创建span，没有开启时返回的是不记录的span
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, opts...)
+}
+
+//StartChild 只在ctx里已经有span时创建，用于redis、sql这类调用很多的地方，避免产生大量单独的链路
+func StartChild(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span, bool) {
+	if !isEnable || ctx == nil || !trace.SpanFromContext(ctx).IsRecording() {
+		return ctx, trace.SpanFromContext(ctx), false
+	}
+	ctx, span := Tracer().Start(ctx, name, opts...)
+
+	return ctx, span, true
+}
+
+//End 记录err并结束span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+//Inject 写入traceparent、tracestate
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+}
+
+//Extract 读取上游的traceparent、tracestate
+//没有时，如果traceID是32位的十六进制，新的链路使用它作为TraceID
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier, traceID string) context.Context {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	if tid, err := trace.TraceIDFromHex(traceID); err == nil {
+		ctx = context.WithValue(ctx, traceIDKey{}, tid)
+	}
+
+	return ctx
+}
+
+//TraceID span的TraceID，无效时返回空
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ""
+	}
+
+	return sc.TraceID().String()
+}
+
+type traceIDKey struct{}
+
+//idGenerator 优先使用Extract放到ctx里的TraceID
+type idGenerator struct {
+	sync.Mutex
+	randSource *rand.Rand
+}
+
+func newIDGenerator() *idGenerator {
+	var seed int64
+	_ = binary.Read(crand.Reader, binary.LittleEndian, &seed)
+
+	return &idGenerator{randSource: rand.New(rand.NewSource(seed))}
+}
+
+func (g *idGenerator) NewIDs(ctx context.Context) (tid trace.TraceID, sid trace.SpanID) {
+	g.Lock()
+	defer g.Unlock()
+	if v, ok := ctx.Value(traceIDKey{}).(trace.TraceID); ok && v.IsValid() {
+		tid = v
+	} else {
+		_, _ = io.ReadFull(g.randSource, tid[:])
+	}
+	_, _ = io.ReadFull(g.randSource, sid[:])
+
+	return
+}
+
+func (g *idGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) (sid trace.SpanID) {
+	g.Lock()
+	defer g.Unlock()
+	_, _ = io.ReadFull(g.randSource, sid[:])
+
+	return
+}
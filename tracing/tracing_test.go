@@ -0,0 +1,153 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testParent      = "00f067aa0ba902b7"
+	testPureTrace   = "0123456789abcdef0123456789abcdef"
+	testTraceparent = "00-" + testTraceID + "-" + testParent + "-01"
+)
+
+//用SpanRecorder代替exporter
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	sr := tracetest.NewSpanRecorder()
+	old := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(sr), sdktrace.WithIDGenerator(newIDGenerator())))
+	isEnable = true
+	t.Cleanup(func() {
+		otel.SetTracerProvider(old)
+		isEnable = false
+	})
+
+	return sr
+}
+
+func TestHTTPServerSpan(t *testing.T) {
+	sr := setupRecorder(t)
+
+	//有上游的traceparent，使用上游的链路
+	r := httptest.NewRequest("GET", "/users/1", nil)
+	r.Header.Set("traceparent", testTraceparent)
+	ctx, span := StartHTTPServer(context.Background(), r, testPureTrace)
+	if TraceID(ctx) != testTraceID {
+		t.Fatalf("TraceID = %s, want %s", TraceID(ctx), testTraceID)
+	}
+	EndHTTP(span, http.StatusInternalServerError, nil)
+
+	//没有上游的，使用原来的trace_id
+	r = httptest.NewRequest("POST", "/users", nil)
+	ctx, span = StartHTTPServer(context.Background(), r, testPureTrace)
+	if TraceID(ctx) != testPureTrace {
+		t.Fatalf("TraceID = %s, want %s", TraceID(ctx), testPureTrace)
+	}
+	EndHTTP(span, http.StatusOK, nil)
+
+	//trace_id不是32位十六进制的，随机生成
+	ctx, span = StartHTTPServer(context.Background(), r, "not-hex")
+	if tid := TraceID(ctx); len(tid) != 32 || tid == testPureTrace {
+		t.Fatalf("TraceID = %s", tid)
+	}
+	EndHTTP(span, http.StatusOK, errors.New("abort"))
+
+	ended := sr.Ended()
+	if len(ended) != 3 {
+		t.Fatalf("ended %d spans", len(ended))
+	}
+	if s := ended[0]; s.Name() != "GET /users/1" || s.SpanKind() != trace.SpanKindServer ||
+		s.Parent().SpanID().String() != testParent || !s.Parent().IsRemote() || s.Status().Code != codes.Error {
+		t.Fatalf("span = %s %s %v %v", s.Name(), s.SpanKind(), s.Parent(), s.Status())
+	}
+	if s := ended[1]; s.Parent().IsValid() || s.Status().Code == codes.Error {
+		t.Fatalf("root span = %v %v", s.Parent(), s.Status())
+	}
+	if s := ended[2]; s.Status().Code != codes.Error || s.Status().Description != "abort" {
+		t.Fatalf("span with err = %v", s.Status())
+	}
+}
+
+func TestHTTPClientPropagation(t *testing.T) {
+	sr := setupRecorder(t)
+
+	ctx, parent := Start(context.Background(), "parent")
+	r := httptest.NewRequest("GET", "http://example.com/a", nil)
+	_, span := StartHTTPClient(ctx, r)
+	EndHTTP(span, http.StatusOK, nil)
+	parent.End()
+
+	//下游收到的traceparent是客户端span
+	sc := span.SpanContext()
+	want := "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-01"
+	if got := r.Header.Get("traceparent"); got != want {
+		t.Fatalf("traceparent = %s, want %s", got, want)
+	}
+	if sc.TraceID() != parent.SpanContext().TraceID() {
+		t.Fatal("client span should be in the parent trace")
+	}
+	if s := sr.Ended()[0]; s.Name() != "HTTP GET" || s.SpanKind() != trace.SpanKindClient {
+		t.Fatalf("client span = %s %s", s.Name(), s.SpanKind())
+	}
+}
+
+func TestGrpcPropagation(t *testing.T) {
+	setupRecorder(t)
+
+	//客户端写入outgoing metadata，不修改调用方的
+	md := metadata.Pairs("k", "v")
+	ctx, parent := Start(metadata.NewOutgoingContext(context.Background(), md), "parent")
+	ctx, client := StartGrpcClient(ctx, "/pkg.Service/Method")
+	defer parent.End()
+	out, _ := metadata.FromOutgoingContext(ctx)
+	if len(out.Get("traceparent")) != 1 || out.Get("k")[0] != "v" || len(md.Get("traceparent")) != 0 {
+		t.Fatalf("outgoing = %v, caller = %v", out, md)
+	}
+
+	//服务端从incoming metadata读取
+	ctx, server := StartGrpcServer(metadata.NewIncomingContext(context.Background(), out), "/pkg.Service/Method", testPureTrace)
+	EndGrpc(server, nil)
+	EndGrpc(client, nil)
+	if TraceID(ctx) != client.SpanContext().TraceID().String() {
+		t.Fatalf("server TraceID = %s", TraceID(ctx))
+	}
+	if p := server.(sdktrace.ReadOnlySpan).Parent(); p.SpanID() != client.SpanContext().SpanID() {
+		t.Fatalf("server parent = %v", p)
+	}
+}
+
+func TestStartChild(t *testing.T) {
+	sr := setupRecorder(t)
+
+	//没有父span的不创建
+	if _, _, ok := StartChild(context.Background(), "redis"); ok {
+		t.Fatal("StartChild without parent should not create span")
+	}
+	ctx, parent := Start(context.Background(), "parent")
+	_, span, ok := StartChild(ctx, "redis")
+	if !ok {
+		t.Fatal("StartChild with parent should create span")
+	}
+	End(span, nil)
+	parent.End()
+	if n := len(sr.Ended()); n != 2 {
+		t.Fatalf("ended %d spans", n)
+	}
+
+	isEnable = false
+	if _, _, ok = StartChild(ctx, "redis"); ok {
+		t.Fatal("StartChild should not create span when disabled")
+	}
+}
@@ -0,0 +1,48 @@
+package hfw
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hsyan2008/hfw/configs"
+	"github.com/hsyan2008/hfw/tracing"
+)
+
+func TestHTTPSpan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hfw-tracing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "trace.json")
+	if err = tracing.Init(configs.TracingConfig{IsEnable: true, Exporters: []string{"file"}, File: file}); err != nil {
+		t.Fatal(err)
+	}
+	HandlerFunc("/test_trace/:id", func(w http.ResponseWriter, r *http.Request) {})
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	w := serveTest("GET", "/test_trace/1", http.Header{"Traceparent": {"00-" + traceID + "-00f067aa0ba902b7-01"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("code = %d", w.Code)
+	}
+	//导出剩余的span
+	if err = tracing.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//span的名称是路由，沿用上游的TraceID，原来的trace_id记录在属性里
+	for _, want := range []string{`"Name":"GET /test_trace/:id"`, `"TraceID":"` + traceID + `"`,
+		`"SpanID":"00f067aa0ba902b7"`, `"hfw.trace_id"`} {
+		if !strings.Contains(string(b), want) {
+			t.Fatalf("span not contains %s: %s", want, b)
+		}
+	}
+}
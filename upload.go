@@ -0,0 +1,386 @@
+package hfw
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hsyan2008/hfw/common"
+)
+
+//ErrUploadTooLarge 请求体或者文件超过Upload配置的大小，可以修改错误码和错误信息
+var ErrUploadTooLarge = common.NewRespErr(413, "upload too large")
+
+//ErrUploadType 文件类型不在Upload.AllowTypes里，可以修改错误码和错误信息
+var ErrUploadType = common.NewRespErr(415, "upload type not allowed")
+
+//嗅探类型需要的字节数，见http.DetectContentType
+const uploadSniffLen = 512
+
+//UploadFile 上传的文件
+type UploadFile struct {
+	//表单字段
+	Field    string
+	Filename string
+	//按内容嗅探的类型，不是客户端传的Content-Type
+	ContentType string
+	Size        int64
+	//TempFileSink保存的路径，请求结束后会删除，需要保留请用MoveTo
+	Path string
+}
+
+//MoveTo 把临时文件移动到dst，目录不存在会创建
+func (f *UploadFile) MoveTo(dst string) (err error) {
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return
+	}
+	//跨设备时rename会失败，改为复制
+	if err = os.Rename(f.Path, dst); err != nil {
+		if err = copyFile(f.Path, dst); err != nil {
+			return
+		}
+		_ = os.Remove(f.Path)
+	}
+	f.Path = dst
+
+	return
+}
+
+//UploadSink 保存上传的文件，如写入磁盘或者对象存储，r读到EOF即文件结束
+type UploadSink interface {
+	Save(file *UploadFile, r io.Reader) error
+}
+
+//UploadSinkFunc ..
+type UploadSinkFunc func(file *UploadFile, r io.Reader) error
+
+//Save ..
+func (f UploadSinkFunc) Save(file *UploadFile, r io.Reader) error {
+	return f(file, r)
+}
+
+type tempFileSink struct {
+	dir string
+}
+
+//TempFileSink 写入dir下的临时文件，dir为空时用Upload.TempDir
+func TempFileSink(dir string) UploadSink {
+	return &tempFileSink{dir: dir}
+}
+
+func (s *tempFileSink) Save(file *UploadFile, r io.Reader) (err error) {
+	dir := s.dir
+	if dir == "" {
+		dir = Config.Upload.TempDir
+	}
+	f, err := os.CreateTemp(dir, "hfw-upload-")
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(f, r)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return
+	}
+	file.Path = f.Name()
+
+	return
+}
+
+//写入指定的路径，用于SaveUploadedFile
+type pathSink string
+
+func (s pathSink) Save(file *UploadFile, r io.Reader) (err error) {
+	dst := string(s)
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(f, r)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		_ = os.Remove(dst)
+		return
+	}
+	file.Path = dst
+
+	return
+}
+
+//Upload 流式读取multipart的请求，不在内存里缓存文件，sink为nil时写入临时文件
+//文件按AllowTypes检查嗅探的类型、按MaxFileSize限制大小后交给sink，普通字段放到Request.PostForm和Form
+//如果请求已经被ParseMultipartForm解析过(如Bind)，则从MultipartForm里读取
+//错误返回*common.RespErr，超过大小是ErrUploadTooLarge，类型不允许是ErrUploadType，同时设置了HTTPStatus，可以直接ThrowCheck
+func (httpCtx *HTTPContext) Upload(sink UploadSink) (files []*UploadFile, err error) {
+	if sink == nil {
+		sink = TempFileSink("")
+	}
+	r := httpCtx.Request
+	if err = httpCtx.limitUploadBody(); err != nil {
+		return nil, httpCtx.uploadErr(http.StatusBadRequest, err)
+	}
+
+	if r.MultipartForm != nil {
+		names := make([]string, 0, len(r.MultipartForm.File))
+		for name := range r.MultipartForm.File {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, fh := range r.MultipartForm.File[name] {
+				file := &UploadFile{Field: name, Filename: fh.Filename}
+				f, err := fh.Open()
+				if err != nil {
+					return files, httpCtx.uploadErr(http.StatusInternalServerError, err)
+				}
+				err = httpCtx.saveUploadFile(sink, file, f)
+				f.Close()
+				if err != nil {
+					return files, httpCtx.uploadErr(http.StatusInternalServerError, err)
+				}
+				files = append(files, file)
+			}
+		}
+		return
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, httpCtx.uploadErr(http.StatusBadRequest, err)
+	}
+	postForm := make(url.Values)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return files, httpCtx.uploadErr(http.StatusBadRequest, err)
+		}
+		name := part.FormName()
+		if part.FileName() == "" {
+			//普通字段，总大小受MaxBodySize限制
+			b, err := io.ReadAll(part)
+			part.Close()
+			if err != nil {
+				return files, httpCtx.uploadErr(http.StatusBadRequest, err)
+			}
+			postForm.Add(name, string(b))
+			continue
+		}
+		file := &UploadFile{Field: name, Filename: part.FileName()}
+		err = httpCtx.saveUploadFile(sink, file, part)
+		part.Close()
+		if err != nil {
+			return files, httpCtx.uploadErr(http.StatusInternalServerError, err)
+		}
+		files = append(files, file)
+	}
+
+	//和ParseMultipartForm一样，Form里表单的值在url参数之前
+	if r.Form == nil {
+		r.Form = make(url.Values)
+		for k, v := range r.URL.Query() {
+			r.Form[k] = v
+		}
+	}
+	for k, v := range postForm {
+		r.Form[k] = append(append([]string(nil), v...), r.Form[k]...)
+	}
+	r.PostForm = postForm
+
+	return
+}
+
+//SaveUploadedFile 保存Bind或者FormFile得到的文件到dst，同样检查MaxFileSize和AllowTypes，错误同Upload
+func (httpCtx *HTTPContext) SaveUploadedFile(fh *multipart.FileHeader, dst string) error {
+	f, err := fh.Open()
+	if err != nil {
+		return httpCtx.uploadErr(http.StatusInternalServerError, err)
+	}
+	defer f.Close()
+
+	err = httpCtx.saveUploadFile(pathSink(dst), &UploadFile{Filename: fh.Filename}, f)
+	if err != nil {
+		return httpCtx.uploadErr(http.StatusInternalServerError, err)
+	}
+
+	return nil
+}
+
+//嗅探类型并限制大小后交给sink，临时文件在请求结束后删除
+func (httpCtx *HTTPContext) saveUploadFile(sink UploadSink, file *UploadFile, r io.Reader) (err error) {
+	maxSize := Config.Upload.MaxFileSize
+	if maxSize <= 0 {
+		maxSize = math.MaxInt64 - 1
+	}
+	lr := &uploadLimitReader{r: r, n: maxSize}
+
+	buf := make([]byte, uploadSniffLen)
+	n, err := io.ReadFull(lr, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return
+	}
+	file.ContentType, _, _ = mime.ParseMediaType(http.DetectContentType(buf[:n]))
+	if !isAllowUploadType(file.ContentType) {
+		httpCtx.Warnf("upload %s: type %s not allowed", file.Filename, file.ContentType)
+		return ErrUploadType
+	}
+
+	err = sink.Save(file, io.MultiReader(bytes.NewReader(buf[:n]), lr))
+	if err != nil {
+		return
+	}
+	file.Size = lr.size
+	if _, ok := sink.(*tempFileSink); ok && file.Path != "" {
+		httpCtx.uploadFiles = append(httpCtx.uploadFiles, file.Path)
+	}
+
+	return
+}
+
+func isAllowUploadType(contentType string) bool {
+	if len(Config.Upload.AllowTypes) == 0 {
+		return true
+	}
+	for _, v := range Config.Upload.AllowTypes {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v == contentType {
+			return true
+		}
+		if strings.HasSuffix(v, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(v, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//超过大小返回ErrUploadTooLarge，类型不允许返回ErrUploadType，其他的按errNo包装，并设置HTTPStatus
+func (httpCtx *HTTPContext) uploadErr(errNo int64, err error) error {
+	switch {
+	case errors.Is(err, ErrUploadTooLarge):
+		httpCtx.HTTPStatus = http.StatusRequestEntityTooLarge
+		return ErrUploadTooLarge
+	case errors.Is(err, ErrUploadType):
+		httpCtx.HTTPStatus = http.StatusUnsupportedMediaType
+		return ErrUploadType
+	}
+	httpCtx.HTTPStatus = int(errNo)
+
+	return common.NewRespErr(errNo, err)
+}
+
+func isMultipart(r *http.Request) bool {
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return contentType == "multipart/form-data"
+}
+
+//multipart的请求按MaxBodySize限制请求体，Content-Length超出时直接返回ErrUploadTooLarge
+func (httpCtx *HTTPContext) limitUploadBody() error {
+	r := httpCtx.Request
+	maxSize := Config.Upload.MaxBodySize
+	if maxSize <= 0 || !isMultipart(r) {
+		return nil
+	}
+	if r.ContentLength > maxSize {
+		return ErrUploadTooLarge
+	}
+	if !httpCtx.isUploadLimited && r.Body != nil && r.Body != http.NoBody {
+		httpCtx.isUploadLimited = true
+		r.Body = &uploadBody{uploadLimitReader: &uploadLimitReader{r: r.Body, n: maxSize}, Closer: r.Body}
+	}
+
+	return nil
+}
+
+//在checkCsrf解析表单之前，超出时返回413
+func (httpCtx *HTTPContext) checkUploadBody() {
+	if err := httpCtx.limitUploadBody(); err != nil {
+		httpCtx.HTTPStatus = http.StatusRequestEntityTooLarge
+		httpCtx.ThrowCheck(ErrUploadTooLarge.ErrNo(), err)
+	}
+}
+
+//请求结束时删除Upload写入的临时文件，已经MoveTo的会失败，忽略
+func (httpCtx *HTTPContext) removeUploadFiles() {
+	for _, path := range httpCtx.uploadFiles {
+		_ = os.Remove(path)
+	}
+	httpCtx.uploadFiles = nil
+}
+
+//uploadLimitReader 读取超过n字节时返回ErrUploadTooLarge
+type uploadLimitReader struct {
+	r        io.Reader
+	n        int64
+	size     int64
+	exceeded bool
+}
+
+func (l *uploadLimitReader) Read(p []byte) (n int, err error) {
+	if l.exceeded {
+		return 0, ErrUploadTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err = l.r.Read(p)
+	if int64(n) > l.n {
+		n = int(l.n)
+		l.size += l.n
+		l.n = 0
+		l.exceeded = true
+		return n, ErrUploadTooLarge
+	}
+	l.n -= int64(n)
+	l.size += int64(n)
+
+	return
+}
+
+type uploadBody struct {
+	*uploadLimitReader
+	io.Closer
+}
+
+//已经读取的部分放回请求体
+type prefixBody struct {
+	io.Reader
+	io.Closer
+}
+
+func copyFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(out, in)
+	if e := out.Close(); err == nil {
+		err = e
+	}
+
+	return
+}
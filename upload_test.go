@@ -0,0 +1,130 @@
+package hfw
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hsyan2008/hfw/configs"
+)
+
+type testUploadCtl struct {
+	Controller
+}
+
+func (ctl *testUploadCtl) Index(httpCtx *HTTPContext) {
+	//csrf没有解析整个请求体
+	isParsed := httpCtx.Request.MultipartForm != nil
+	files, err := httpCtx.Upload(nil)
+	httpCtx.ThrowCheck(500, err)
+	var names []string
+	for _, f := range files {
+		if _, err := os.Stat(f.Path); err != nil {
+			httpCtx.ThrowCheck(500, err)
+		}
+		names = append(names, f.Field+":"+f.ContentType)
+	}
+	httpCtx.Results = struct {
+		IsParsed bool
+		Files    string
+		Title    string
+	}{isParsed, strings.Join(names, ","), httpCtx.GetForm("title")}
+}
+
+var testPNG = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{1}, 100)...)
+
+func newUploadRequest(token string, file []byte) *http.Request {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	if token != "" {
+		_ = w.WriteField("_csrf", token)
+	}
+	_ = w.WriteField("title", "hi")
+	fw, _ := w.CreateFormFile("file", "a.png")
+	_, _ = fw.Write(file)
+	_ = w.Close()
+
+	r := httptest.NewRequest("POST", "/test_upload", body)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	r.AddCookie(&http.Cookie{Name: "csrf_token", Value: "tk"})
+
+	return r
+}
+
+func TestUploadWithCsrf(t *testing.T) {
+	old := Config
+	defer func() { Config = old }()
+	Config.Csrf.IsEnable = true
+	Config.Csrf.FieldName = "_csrf"
+	Config.Csrf.HeaderName = "X-CSRF-Token"
+	Config.Csrf.CookieName = "csrf_token"
+	Config.Upload.AllowTypes = []string{"image/*"}
+	_ = Handler("/test_upload", &testUploadCtl{})
+
+	w := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(w, newUploadRequest("tk", testPNG))
+	want := `"results":{"IsParsed":false,"Files":"file:image/png","Title":"hi"}`
+	if w.Code != 200 || !strings.Contains(w.Body.String(), want) {
+		t.Fatalf("upload = %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(w, newUploadRequest("bad", testPNG))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("bad csrf = %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(w, newUploadRequest("tk", []byte("hello")))
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("text file = %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUploadLimit(t *testing.T) {
+	old := Config
+	defer func() { Config = old }()
+	_ = Handler("/test_upload", &testUploadCtl{})
+
+	//默认限制32M，按Content-Length直接返回413
+	if Config.Upload.MaxBodySize != configs.DefaultMaxBodySize {
+		t.Fatalf("default MaxBodySize = %d", Config.Upload.MaxBodySize)
+	}
+	r := newUploadRequest("", testPNG)
+	r.ContentLength = configs.DefaultMaxBodySize + 1
+	w := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("default limit = %d %s", w.Code, w.Body.String())
+	}
+
+	//配置为0不限制
+	Config.Upload.MaxBodySize = 0
+	big := append(testPNG, bytes.Repeat([]byte{2}, 64<<10)...)
+	w = httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(w, newUploadRequest("", big))
+	if w.Code != 200 {
+		t.Fatalf("no limit = %d %s", w.Code, w.Body.String())
+	}
+
+	Config.Upload.MaxBodySize = 1 << 10
+	w = httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(w, newUploadRequest("", big))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("body limit = %d %s", w.Code, w.Body.String())
+	}
+
+	Config.Upload.MaxBodySize = 0
+	Config.Upload.MaxFileSize = 1 << 10
+	r = newUploadRequest("", big)
+	r.ContentLength = -1
+	w = httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("file limit = %d %s", w.Code, w.Body.String())
+	}
+}